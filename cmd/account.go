@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-secure-stdlib/password"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spf13/cobra"
+
+	"github.com/spacemeshos/smcli/wallet"
+)
+
+var (
+	// accountName is the display name of a new account.
+	accountName string
+
+	// accountIndex is the index of a new account.
+	accountIndex uint32
+
+	// hdAccount and hdChain are the BIP-44 account and chain new accounts are derived in.
+	hdAccount, hdChain uint32
+
+	// hdPath is the HD path of the chain or account new accounts are derived at.
+	hdPath string
+)
+
+// accountCmd represents the wallet account command.
+var accountCmd = &cobra.Command{
+	Use:   "account",
+	Short: "Manage the accounts in a wallet file",
+	Long: `Add, remove and rename the accounts in an existing wallet file.
+
+Each account is identified by its index, the last segment of its HD path (m/44'/540'/0'/0'/index'),
+or by its full HD path if the wallet contains accounts with the same index in different BIP-44 accounts
+or chains. Indices don't change when other accounts are removed. The wallet file is re-encrypted with
+the same password and replaced atomically.`,
+}
+
+// accountAddCmd derives a new account.
+var accountAddCmd = &cobra.Command{
+	Use:   "add [wallet file] [--name name] [--index n] [--account n] [--chain n] [--path path]",
+	Short: "Derive a new account and add it to the wallet",
+	Long: `Derive a new account from the wallet's mnemonic, or read it from the Ledger device for
+hardware wallets, and add it to the wallet file.
+
+By default the account gets the index following the highest index in the wallet, so indices of
+removed accounts aren't reused. Use --index to derive a specific account, e.g. to restore a removed
+one. If the wallet was created with a BIP-39 passphrase, it's needed to derive the new account.
+
+Accounts are derived in BIP-44 account 0' and chain 0' unless --account or --chain is set, e.g. to
+separate business units or to use a separate chain for testnet. Alternatively, pass the chain
+(m/44'/540'/account'/chain') or the full path of the account (m/44'/540'/account'/chain'/index')
+using --path. All segments of the path must be hardened.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		w, creds, err := openWalletWithPassword(args[0])
+		cobra.CheckErr(err)
+
+		chain, index, hasIndex, err := pathFromFlags(cmd)
+		cobra.CheckErr(err)
+		switch {
+		case hasIndex && cmd.Flags().Changed("index"):
+			cobra.CheckErr(errors.New("--index can't be used with a full account --path"))
+		case cmd.Flags().Changed("index") && accountIndex >= wallet.BIP32HardenedKeyStart:
+			cobra.CheckErr(fmt.Errorf("%w: index must be less than 2^31", wallet.ErrInvalidPath))
+		case cmd.Flags().Changed("index"):
+			index = accountIndex
+		case !hasIndex:
+			index = w.NextAccountIndex(chain)
+		}
+		seed, err := walletSeed(w)
+		cobra.CheckErr(err)
+		var device wallet.LedgerDevice
+		if w.IsLedger() {
+			device, err = walletLedger(w)
+			cobra.CheckErr(err)
+			prompt("Confirm the new account on your Ledger device.\n")
+		}
+		kp, err := w.AddAccount(seed, device, chain.Extend(wallet.BIP44HardenedAccountIndex(index)), accountName)
+		cobra.CheckErr(err)
+		cobra.CheckErr(saveWallet(args[0], w, creds))
+		printAccountChange(w, fmt.Sprintf("Added account %s (%s) with address %s",
+			kp.Path.String(), kp.DisplayName, wallet.PubkeyToAddress(kp.Public, hrp)))
+	},
+}
+
+// accountRemoveCmd removes an account.
+var accountRemoveCmd = &cobra.Command{
+	Use:   "remove [wallet file] [index or path]",
+	Short: "Remove an account from the wallet",
+	Long: `Remove the account with the given index or HD path from the wallet file. The indices of the
+other accounts don't change. The account can be restored later using "account add --index".`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		cobra.CheckErr(checkAccountSelector(args[1]))
+		w, creds, err := openWalletWithPassword(args[0])
+		cobra.CheckErr(err)
+		kp, err := w.FindAccount(args[1])
+		cobra.CheckErr(err)
+		address := wallet.PubkeyToAddress(kp.Public, hrp)
+		cobra.CheckErr(w.RemoveAccount(kp.Path))
+		cobra.CheckErr(saveWallet(args[0], w, creds))
+		printAccountChange(w, fmt.Sprintf("Removed account %s (%s) with address %s",
+			kp.Path.String(), kp.DisplayName, address))
+	},
+}
+
+// accountRenameCmd renames an account.
+var accountRenameCmd = &cobra.Command{
+	Use:   "rename [wallet file] [index or path] [name]",
+	Short: "Change the display name of an account",
+	Args:  cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		cobra.CheckErr(checkAccountSelector(args[1]))
+		w, creds, err := openWalletWithPassword(args[0])
+		cobra.CheckErr(err)
+		kp, err := w.FindAccount(args[1])
+		cobra.CheckErr(err)
+		cobra.CheckErr(w.RenameAccount(kp.Path, args[2]))
+		cobra.CheckErr(saveWallet(args[0], w, creds))
+		printAccountChange(w, fmt.Sprintf("Renamed account %s to %s", kp.Path.String(), args[2]))
+	},
+}
+
+// checkAccountSelector checks that s is an account index or HD path (see Wallet.FindAccount)
+// before the wallet is opened.
+func checkAccountSelector(s string) error {
+	if strings.HasPrefix(s, "m/") {
+		_, err := wallet.StringToHDPath(s)
+		return err
+	}
+	if _, err := strconv.ParseUint(s, 10, 31); err != nil {
+		return fmt.Errorf("invalid account %q: expected an index or an HD path", s)
+	}
+	return nil
+}
+
+// addPathFlags registers the flags that select the HD path of new accounts on c.
+func addPathFlags(c *cobra.Command) {
+	c.Flags().Uint32Var(&hdAccount, "account", 0, "BIP-44 account to derive accounts in")
+	c.Flags().Uint32Var(&hdChain, "chain", 0, "BIP-44 chain to derive accounts in")
+	c.Flags().StringVar(&hdPath, "path", "",
+		"HD path of the chain (m/44'/540'/account'/chain') or account (m/44'/540'/account'/chain'/index')")
+	c.MarkFlagsMutuallyExclusive("path", "account")
+	c.MarkFlagsMutuallyExclusive("path", "chain")
+}
+
+// pathFromFlags returns the chain path selected by --account and --chain, or by --path. If --path
+// is a full account path, its index is returned as well, and hasIndex is set.
+func pathFromFlags(cmd *cobra.Command) (chain wallet.HDPath, index uint32, hasIndex bool, err error) {
+	if !cmd.Flags().Changed("path") {
+		if hdAccount >= wallet.BIP32HardenedKeyStart || hdChain >= wallet.BIP32HardenedKeyStart {
+			return nil, 0, false, fmt.Errorf("%w: account and chain must be less than 2^31", wallet.ErrInvalidPath)
+		}
+		return wallet.ChainPath(hdAccount, hdChain), 0, false, nil
+	}
+	path, err := wallet.StringToHDPath(hdPath)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if len(path) == wallet.HDIndexSegment {
+		return path, 0, false, wallet.ValidateChainPath(path)
+	}
+	if err := wallet.ValidateAccountPath(path); err != nil {
+		return nil, 0, false, err
+	}
+	return path[:wallet.HDIndexSegment], path.Index() &^ wallet.BIP32HardenedKeyStart, true, nil
+}
+
+// walletSeed returns the seed used to derive new accounts in w, prompting for the BIP-39
+// passphrase if the wallet was created with one.
+func walletSeed(w *wallet.Wallet) ([]byte, error) {
+	if w.IsLedger() || !w.Meta.HasPassphrase {
+		return w.Seed("")
+	}
+	prompt("Enter the wallet's BIP-39 passphrase: ")
+	passphrase, err := password.Read(os.Stdin)
+	prompt("\n")
+	if err != nil {
+		return nil, err
+	}
+	return w.Seed(passphrase)
+}
+
+// printAccountChange prints msg, or the updated wallet if a structured output format is selected.
+func printAccountChange(w *wallet.Wallet, msg string) {
+	if structured() {
+		cobra.CheckErr(printStructured(newWalletOutput(w, hex.EncodeToString)))
+		return
+	}
+	fmt.Println(msg)
+}
+
+func init() {
+	walletCmd.AddCommand(accountCmd)
+	accountCmd.AddCommand(accountAddCmd)
+	accountCmd.AddCommand(accountRemoveCmd)
+	accountCmd.AddCommand(accountRenameCmd)
+	accountAddCmd.Flags().StringVar(&accountName, "name", "", "Display name of the new account")
+	accountAddCmd.Flags().Uint32Var(&accountIndex, "index", 0, "Index of the account to derive (default: next unused)")
+	addLedgerFlags(accountAddCmd)
+	addPathFlags(accountAddCmd)
+	for _, c := range []*cobra.Command{accountAddCmd, accountRemoveCmd, accountRenameCmd} {
+		c.Flags().StringVar(&hrp, "hrp", types.NetworkHRP(), "Set human-readable address prefix")
+	}
+}
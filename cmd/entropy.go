@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"github.com/spacemeshos/smcli/wallet"
+)
+
+// entropyHints tell how to enter each kind of user-supplied entropy.
+var entropyHints = map[string]string{
+	wallet.EntropyDice:  "Roll a six-sided die and enter the results (1 to 6)",
+	wallet.EntropyCoins: "Flip a coin and enter the results (h or t, or 1 or 0)",
+	wallet.EntropyHex:   "Enter hexadecimal digits",
+}
+
+// userEntropy collects entropy of the given kind for a mnemonic with the given number of words,
+// from the secret source (see readSecret) if it's set, or else interactively until enough was
+// entered.
+func userEntropy(kind, source string, words int) (*wallet.UserEntropy, error) {
+	e, err := wallet.NewUserEntropy(kind)
+	if err != nil {
+		return nil, err
+	}
+	needed := wallet.MnemonicEntropyBits(words)
+	if source != "" {
+		s, err := readSecret(source)
+		if err != nil {
+			return nil, err
+		}
+		return e, e.Add(s)
+	}
+
+	prompt("%s, on as many lines as you like, and an empty line when done. A %d-word mnemonic needs %d bits "+
+		"of entropy.\n", entropyHints[kind], words, needed)
+	for {
+		prompt("> ")
+		line, err := readLine()
+		if err != nil {
+			return nil, err
+		}
+		if line == "" {
+			if e.Bits() >= float64(needed) {
+				return e, nil
+			}
+			prompt("%.1f bits of entropy collected, %d needed.\n", e.Bits(), needed)
+			continue
+		}
+		if err := e.Add(line); err != nil {
+			prompt("%v, enter the line again.\n", err)
+			continue
+		}
+		prompt("%.1f bits of entropy collected from %d %s symbols.\n", e.Bits(), e.Len(), kind)
+	}
+}
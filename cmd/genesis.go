@@ -1,102 +1,446 @@
 package cmd
 
 import (
-	"crypto/ed25519"
+	"bufio"
 	"encoding/hex"
+	"errors"
 	"fmt"
-	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/spacemeshos/economics/constants"
 	"github.com/spacemeshos/go-spacemesh/common/types"
 	"github.com/spacemeshos/go-spacemesh/genvm/core"
-	"github.com/spacemeshos/go-spacemesh/genvm/templates/multisig"
 	"github.com/spacemeshos/go-spacemesh/genvm/templates/vault"
-	"github.com/spacemeshos/go-spacemesh/genvm/templates/vesting"
 	"github.com/spf13/cobra"
+
+	"github.com/spacemeshos/smcli/common"
+	"github.com/spacemeshos/smcli/genesis"
+)
+
+var (
+	// keysFile is a file containing hex-encoded public keys, one per line.
+	keysFile string
+
+	// genesisAmount is the vault balance, in SMH or smidge.
+	genesisAmount string
+
+	// vestStart and vestEnd are the layers at which a vault starts and finishes unlocking.
+	vestStart, vestEnd uint32
+
+	// initialUnlock is the fraction of the vault amount set as its initial unlock amount.
+	initialUnlock string
+
+	// showSchedule prints the unlock schedule of the vault.
+	showSchedule bool
+
+	// scheduleStep is the number of layers between rows of the unlock schedule.
+	scheduleStep uint32
+
+	// genesisTime, layerDuration and layersPerEpoch describe the network, and are used to show
+	// dates and epochs in the unlock schedule.
+	genesisTime    string
+	layerDuration  time.Duration
+	layersPerEpoch uint32
 )
 
-// genesisCmd represents the wallet command.
+// genesisCmd represents the genesis command.
 var genesisCmd = &cobra.Command{
 	Use:   "genesis",
 	Short: "Genesis-related utilities",
 }
 
-// createCmd represents the create command.
+// verifyCmd represents the genesis verify command.
 var verifyCmd = &cobra.Command{
-	Use:   "verify",
+	Use:   "verify [--key <pubkey>... | --keys-file <file>] [--required n] [--amount <amount>]",
 	Short: "Verify a genesis ledger account",
-	Args:  cobra.MaximumNArgs(0),
-	Run: func(cmd *cobra.Command, args []string) {
-		var err error
+	Long: `Compute the vesting and vault addresses of a genesis ledger account.
+
+The public keys, number of required signatures and vault amount may be passed using flags, which
+allows the command to be scripted. Any of them that is not passed is prompted for interactively.
+Amounts are denominated in SMH unless followed by a unit, e.g. "100SMH" or "100000000000smidge".
 
+The vault is computed using the mainnet vesting schedule unless --vest-start, --vest-end or
+--initial-unlock are set, e.g. to compute addresses on a testnet or to review a proposed schedule.
+Use --schedule to also print how much of the vault has unlocked over time.`,
+	Args: cobra.MaximumNArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
 		// first, collect the keys
 		var keys []core.PublicKey
-		fmt.Print("First, let's collect your public keys. ")
-		fmt.Print("Keys must be entered in hex format: 64 characters, without 0x prefix.\n")
-		fmt.Print("Enter pub keys one at a time; press enter again when done: ")
-		for {
-			var keyStr string
-			_, err := fmt.Scanln(&keyStr)
-			if err != nil {
-				break
-			}
-			keyBytes, err := hex.DecodeString(keyStr)
-			if err != nil || len(keyBytes) != ed25519.PublicKeySize {
-				log.Fatalln("Error: key is unreadable")
-			}
-			key := [ed25519.PublicKeySize]byte{}
-			copy(key[:], keyBytes)
-			keys = append(keys, key)
-			fmt.Printf("[enter next key or just press enter to end] > ")
-		}
-		if len(keys) == 0 {
-			log.Fatalln("Error: must enter at least one key")
+		var err error
+		switch {
+		case keysFile != "":
+			keys, err = readKeysFile(keysFile)
+		case len(msKeys) > 0:
+			keys, err = parsePublicKeys(msKeys)
+		default:
+			keys, err = promptKeys()
 		}
+		cobra.CheckErr(err)
 
 		// next collect multisig params
-		m := uint8(1)
-		if len(keys) > 1 {
-			fmt.Printf("Enter number of required signatures (between 1 and %d): ", len(keys))
+		m := msRequired
+		if len(keys) > 1 && !cmd.Flags().Changed("required") {
+			prompt("Enter number of required signatures (between 1 and %d): ", len(keys))
 			_, err := fmt.Scanln(&m)
 			cobra.CheckErr(err)
 		}
 
 		// finally, collect amount
-		var amount uint64
-		fmt.Printf("Enter vault balance (denominated in SMH): ")
-		_, err = fmt.Scanln(&amount)
+		amountStr := genesisAmount
+		if amountStr == "" {
+			prompt("Enter vault balance (denominated in SMH): ")
+			_, err = fmt.Scanln(&amountStr)
+			cobra.CheckErr(err)
+		}
+		amount, err := common.ParseAmount(amountStr)
 		cobra.CheckErr(err)
-		amount *= constants.OneSmesh
 
 		// calculate keys
-		vestingArgs := &multisig.SpawnArguments{
-			Required:   m,
-			PublicKeys: keys,
-		}
-		if int(vestingArgs.Required) > len(vestingArgs.PublicKeys) {
-			log.Fatalf("requires more signatures (%d) than public keys (%d) in the wallet\n",
-				vestingArgs.Required,
-				len(vestingArgs.PublicKeys),
-			)
-		}
-		vestingAddress := core.ComputePrincipal(vesting.TemplateAddress, vestingArgs)
-		vaultArgs := &vault.SpawnArguments{
-			Owner:               vestingAddress,
-			TotalAmount:         amount,
-			InitialUnlockAmount: amount / 4,
-			VestingStart:        types.LayerID(constants.VestStart),
-			VestingEnd:          types.LayerID(constants.VestEnd),
+		schedule, err := vestingSchedule()
+		cobra.CheckErr(err)
+		vestingArgs, err := genesis.VestingArgs(keys, m)
+		cobra.CheckErr(err)
+		vestingAddress := genesis.VestingAddress(vestingArgs)
+		vaultArgs := schedule.VaultArgs(vestingAddress, amount)
+		vaultAddress := genesis.VaultAddress(vaultArgs)
+		var unlocks []genesis.ScheduleEntry
+		if showSchedule {
+			unlocks = genesis.UnlockSchedule(vaultArgs, scheduleStep)
 		}
-		vaultAddress := core.ComputePrincipal(vault.TemplateAddress, vaultArgs)
 
 		// output addresses
+		if structured() {
+			out := newGenesisAccount(keys, m, vaultArgs)
+			out.Schedule = unlocks
+			cobra.CheckErr(printStructured(out))
+			return
+		}
 		fmt.Printf("Vesting address: %s\nVault address: %s\n",
 			vestingAddress.String(),
 			vaultAddress.String())
+		if showSchedule {
+			cobra.CheckErr(printUnlockSchedule(vaultArgs, unlocks))
+		}
+	},
+}
+
+// verifyLedgerCmd audits a whole genesis ledger.
+var verifyLedgerCmd = &cobra.Command{
+	Use:   "verify-ledger [file]",
+	Short: "Verify every account in a genesis ledger allocation file",
+	Long: `Recompute the vesting and vault address of every allocation in a genesis ledger file and
+compare them with the addresses listed in the file.
+
+The file is either a JSON array of objects with the fields keys, required, amount, vestingAddress
+and vaultAddress, or a CSV file with the header:
+
+  keys,required,amount,vesting_address,vault_address
+
+where multiple keys in the keys column are separated by semicolons or spaces. Amounts are
+denominated in SMH unless followed by a unit, as for verify. Vaults are computed using the mainnet
+vesting schedule unless --vest-start, --vest-end or --initial-unlock are set.
+
+The total allocated amount and any keys or addresses that appear in more than one row are reported
+as well. The command exits with a non-zero status if any row doesn't match.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		types.SetNetworkHRP(hrp)
+		f, err := os.Open(args[0])
+		cobra.CheckErr(err)
+		defer f.Close()
+		allocs, err := genesis.ReadAllocations(f)
+		cobra.CheckErr(err)
+		schedule, err := vestingSchedule()
+		cobra.CheckErr(err)
+		report := genesis.Audit(allocs, schedule)
+
+		if structured() {
+			cobra.CheckErr(printStructured(&auditOutput{report}))
+		} else {
+			printAuditReport(report)
+		}
+		if report.Mismatches > 0 {
+			cobra.CheckErr(fmt.Errorf("%d of %d allocations failed verification", report.Mismatches, len(allocs)))
+		}
 	},
 }
 
+func printAuditReport(report *genesis.AuditReport) {
+	match := func(ok bool) string {
+		if ok {
+			return "ok"
+		}
+		return "MISMATCH"
+	}
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.SetTitle("Genesis ledger")
+	t.AppendHeader(table.Row{"row", "amount", "vesting address", "vault address", "result"})
+	for _, r := range report.Results {
+		if r.Error != "" {
+			t.AppendRow(table.Row{r.Row, "", "", "", "ERROR: " + r.Error})
+			continue
+		}
+		t.AppendRow(table.Row{
+			r.Row,
+			common.FormatAmount(r.Amount),
+			fmt.Sprintf("%s (%s)", r.VestingAddress, match(r.VestingMatch)),
+			fmt.Sprintf("%s (%s)", r.VaultAddress, match(r.VaultMatch)),
+			match(r.OK()),
+		})
+	}
+	t.SetCaption("%d allocation(s), %d mismatch(es), total allocated: %s",
+		len(report.Results), report.Mismatches, common.FormatAmount(report.TotalAmount))
+	t.Render()
+
+	printDuplicates("Duplicate keys", report.DuplicateKeys)
+	printDuplicates("Duplicate addresses", report.DuplicateAddresses)
+}
+
+// printDuplicates prints values that appear in more than one row, sorted by the first row.
+func printDuplicates(title string, dups map[string][]int) {
+	if len(dups) == 0 {
+		return
+	}
+	values := make([]string, 0, len(dups))
+	for v := range dups {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		a, b := dups[values[i]], dups[values[j]]
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		return values[i] < values[j]
+	})
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.SetTitle(title)
+	t.AppendHeader(table.Row{"value", "rows"})
+	for _, v := range values {
+		t.AppendRow(table.Row{v, strings.Trim(fmt.Sprint(dups[v]), "[]")})
+	}
+	t.Render()
+}
+
+// auditOutput is the structured output of a genesis ledger audit.
+type auditOutput struct {
+	*genesis.AuditReport `yaml:",inline"`
+}
+
+func (o *auditOutput) Header() []string {
+	return []string{"row", "amount", "vesting_address", "vault_address", "vesting_match", "vault_match", "error"}
+}
+
+func (o *auditOutput) Rows() [][]string {
+	rows := make([][]string, 0, len(o.Results))
+	for _, r := range o.Results {
+		rows = append(rows, []string{
+			strconv.Itoa(r.Row),
+			strconv.FormatUint(r.Amount, 10),
+			r.VestingAddress,
+			r.VaultAddress,
+			strconv.FormatBool(r.VestingMatch),
+			strconv.FormatBool(r.VaultMatch),
+			r.Error,
+		})
+	}
+	return rows
+}
+
+// genesisAccount is the structured representation of a genesis ledger account.
+type genesisAccount struct {
+	VestingAddress string                  `json:"vestingAddress" yaml:"vestingAddress"`
+	VaultAddress   string                  `json:"vaultAddress" yaml:"vaultAddress"`
+	Required       uint8                   `json:"required" yaml:"required"`
+	PublicKeys     []string                `json:"publicKeys" yaml:"publicKeys"`
+	Vault          genesisVault            `json:"vault" yaml:"vault"`
+	Schedule       []genesis.ScheduleEntry `json:"schedule,omitempty" yaml:"schedule,omitempty"`
+}
+
+func newGenesisAccount(keys []core.PublicKey, required uint8, vaultArgs *vault.SpawnArguments) *genesisAccount {
+	pubs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pubs = append(pubs, hex.EncodeToString(k[:]))
+	}
+	return &genesisAccount{
+		VestingAddress: vaultArgs.Owner.String(),
+		VaultAddress:   genesis.VaultAddress(vaultArgs).String(),
+		Required:       required,
+		PublicKeys:     pubs,
+		Vault: genesisVault{
+			Owner:               vaultArgs.Owner.String(),
+			TotalAmount:         vaultArgs.TotalAmount,
+			InitialUnlockAmount: vaultArgs.InitialUnlockAmount,
+			VestingStart:        vaultArgs.VestingStart.Uint32(),
+			VestingEnd:          vaultArgs.VestingEnd.Uint32(),
+		},
+	}
+}
+
+func (a *genesisAccount) Header() []string {
+	return []string{
+		"vesting_address", "vault_address", "required", "keys",
+		"total_amount", "initial_unlock_amount", "vesting_start", "vesting_end",
+	}
+}
+
+func (a *genesisAccount) Rows() [][]string {
+	return [][]string{{
+		a.VestingAddress,
+		a.VaultAddress,
+		strconv.Itoa(int(a.Required)),
+		strings.Join(a.PublicKeys, ";"),
+		strconv.FormatUint(a.Vault.TotalAmount, 10),
+		strconv.FormatUint(a.Vault.InitialUnlockAmount, 10),
+		strconv.FormatUint(uint64(a.Vault.VestingStart), 10),
+		strconv.FormatUint(uint64(a.Vault.VestingEnd), 10),
+	}}
+}
+
+// genesisVault is the structured representation of the spawn arguments of a genesis vault.
+type genesisVault struct {
+	Owner               string `json:"owner" yaml:"owner"`
+	TotalAmount         uint64 `json:"totalAmount" yaml:"totalAmount"`
+	InitialUnlockAmount uint64 `json:"initialUnlockAmount" yaml:"initialUnlockAmount"`
+	VestingStart        uint32 `json:"vestingStart" yaml:"vestingStart"`
+	VestingEnd          uint32 `json:"vestingEnd" yaml:"vestingEnd"`
+}
+
+// vestingSchedule returns the vesting schedule set by flags, which defaults to the mainnet one.
+func vestingSchedule() (genesis.Schedule, error) {
+	schedule := genesis.Schedule{
+		VestingStart: types.LayerID(vestStart),
+		VestingEnd:   types.LayerID(vestEnd),
+	}
+	fraction, err := genesis.ParseFraction(initialUnlock)
+	if err != nil {
+		return schedule, err
+	}
+	schedule.InitialUnlock = fraction
+	return schedule, schedule.Validate()
+}
+
+// addScheduleFlags adds the flags used to set the vesting schedule to c.
+func addScheduleFlags(c *cobra.Command) {
+	mainnet := genesis.MainnetSchedule()
+	c.Flags().Uint32Var(&vestStart, "vest-start", mainnet.VestingStart.Uint32(),
+		"Layer at which the vault starts to unlock")
+	c.Flags().Uint32Var(&vestEnd, "vest-end", mainnet.VestingEnd.Uint32(), "Layer at which the vault is fully unlocked")
+	c.Flags().StringVar(&initialUnlock, "initial-unlock", mainnet.InitialUnlock.RatString(),
+		"Fraction of the vault amount set as its initial unlock amount, e.g. 1/4, 0.25 or 25%")
+}
+
+// addScheduleTableFlags adds the flags used to print the unlock schedule table to c.
+func addScheduleTableFlags(c *cobra.Command) {
+	c.Flags().BoolVar(&showSchedule, "schedule", false, "Print the unlock schedule of the vault")
+	c.Flags().Uint32Var(&scheduleStep, "schedule-step", constants.OneEpoch,
+		"Number of layers between rows of the unlock schedule")
+	c.Flags().StringVar(&genesisTime, "genesis-time", genesis.MainnetGenesisTime.Format(time.RFC3339),
+		"Genesis time of the network, used to show dates in the unlock schedule")
+	c.Flags().DurationVar(&layerDuration, "layer-duration", genesis.MainnetLayerDuration,
+		"Layer duration of the network, used to show dates in the unlock schedule")
+	c.Flags().Uint32Var(&layersPerEpoch, "layers-per-epoch", constants.OneEpoch,
+		"Number of layers per epoch of the network, used to show epochs in the unlock schedule")
+}
+
+// printUnlockSchedule prints the unlock schedule of a vault as a table.
+func printUnlockSchedule(args *vault.SpawnArguments, unlocks []genesis.ScheduleEntry) error {
+	start, err := time.Parse(time.RFC3339, genesisTime)
+	if err != nil {
+		return fmt.Errorf("invalid genesis time: %w", err)
+	}
+	if layersPerEpoch == 0 {
+		return errors.New("layers per epoch must be positive")
+	}
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.SetTitle("Unlock schedule")
+	t.AppendHeader(table.Row{"layer", "epoch", "date", "unlocked", "percent"})
+	for _, e := range unlocks {
+		pct := 100.0
+		if args.TotalAmount > 0 {
+			pct = float64(e.Unlocked) / float64(args.TotalAmount) * 100
+		}
+		t.AppendRow(table.Row{
+			e.Layer.Uint32(),
+			e.Layer.Uint32() / layersPerEpoch,
+			genesis.LayerTime(start, layerDuration, e.Layer).Format(time.DateOnly),
+			common.FormatAmount(e.Unlocked),
+			fmt.Sprintf("%.2f%%", pct),
+		})
+	}
+	t.SetCaption("Initial unlock amount: %s (recorded in the vault, not released at the vesting start)",
+		common.FormatAmount(args.InitialUnlockAmount))
+	t.Render()
+	return nil
+}
+
+// promptKeys interactively collects public keys until an empty line is entered.
+func promptKeys() ([]core.PublicKey, error) {
+	var keys []core.PublicKey
+	prompt("First, let's collect your public keys. ")
+	prompt("Keys must be entered in hex format: 64 characters, without 0x prefix.\n")
+	prompt("Enter pub keys one at a time; press enter again when done: ")
+	for {
+		var keyStr string
+		_, err := fmt.Scanln(&keyStr)
+		if err != nil {
+			break
+		}
+		key, err := parsePublicKey(keyStr)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+		prompt("[enter next key or just press enter to end] > ")
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("must enter at least one key")
+	}
+	return keys, nil
+}
+
+// readKeysFile reads hex-encoded public keys from a file, one per line. Blank lines and lines
+// starting with # are ignored.
+func readKeysFile(fn string) ([]core.PublicKey, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var keys []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return parsePublicKeys(keys)
+}
+
 func init() {
 	rootCmd.AddCommand(genesisCmd)
 	genesisCmd.AddCommand(verifyCmd)
+	verifyCmd.Flags().StringArrayVar(&msKeys, "key", nil, "Hex-encoded public key (repeat for each key)")
+	verifyCmd.Flags().StringVar(&keysFile, "keys-file", "", "File containing hex-encoded public keys, one per line")
+	verifyCmd.Flags().Uint8Var(&msRequired, "required", 1, "Number of required signatures")
+	verifyCmd.Flags().StringVar(&genesisAmount, "amount", "", "Vault balance, e.g. 100SMH or 100000000000smidge")
+	verifyCmd.MarkFlagsMutuallyExclusive("key", "keys-file")
+	addScheduleFlags(verifyCmd)
+	addScheduleTableFlags(verifyCmd)
+	genesisCmd.AddCommand(verifyLedgerCmd)
+	verifyLedgerCmd.Flags().StringVar(&hrp, "hrp", types.NetworkHRP(), "Set human-readable address prefix")
+	addScheduleFlags(verifyLedgerCmd)
 }
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+
+	"github.com/spacemeshos/smcli/wallet"
+)
+
+// ledgerSelector selects the Ledger device to use when more than one is connected: its index in
+// "wallet ledger list", its serial number, or its HID device path.
+var ledgerSelector string
+
+// ledgerCmd groups the Ledger device commands.
+var ledgerCmd = &cobra.Command{
+	Use:   "ledger",
+	Short: "Ledger device management",
+}
+
+// ledgerListCmd lists the connected Ledger devices.
+var ledgerListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the connected Ledger devices",
+	Long: `List the connected Ledger devices, with their index, HID path, model, serial number, and the
+fingerprint of the Spacemesh app's master key. Any of the index, serial number or path can be passed
+to --ledger-device to select a device.
+
+The fingerprint is stored in wallet files created from a Ledger device, and identifies the device
+that holds the wallet's keys. It can only be read while the device is unlocked and the Spacemesh app
+is open; otherwise it's shown as "-".
+
+Listing devices is only supported on Linux, where the path is the hidraw device node, e.g.
+/dev/hidraw1. On other platforms, omit --ledger-device to use the first device found.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		devices, err := wallet.ListLedgers()
+		cobra.CheckErr(err)
+		o := &ledgerListOutput{Devices: make([]ledgerOutput, 0, len(devices))}
+		for i, d := range devices {
+			fp, err := wallet.ReadLedgerFingerprint(wallet.SmkeysLedger(d.Path))
+			if err != nil {
+				fp = "-"
+			}
+			o.Devices = append(o.Devices, ledgerOutput{Index: i, LedgerInfo: d, Fingerprint: fp})
+		}
+
+		if structured() {
+			cobra.CheckErr(printStructured(o))
+			return
+		}
+		if len(devices) == 0 {
+			fmt.Println("No Ledger devices found.")
+			return
+		}
+		t := table.NewWriter()
+		t.SetOutputMirror(os.Stdout)
+		t.SetTitle("Ledger devices")
+		t.AppendHeader(table.Row{"#", "path", "model", "serial", "fingerprint"})
+		for _, d := range o.Devices {
+			t.AppendRow(table.Row{d.Index, d.Path, d.Model, d.Serial, d.Fingerprint})
+		}
+		t.Render()
+	},
+}
+
+// ledgerListOutput is the structured output of the ledger list command.
+type ledgerListOutput struct {
+	Devices []ledgerOutput `json:"devices" yaml:"devices"`
+}
+
+// ledgerOutput is the structured output of a Ledger device.
+type ledgerOutput struct {
+	Index             int `json:"index" yaml:"index"`
+	wallet.LedgerInfo `yaml:",inline"`
+	Fingerprint       string `json:"fingerprint" yaml:"fingerprint"`
+}
+
+func (o *ledgerListOutput) Header() []string {
+	return []string{"index", "path", "model", "product_id", "serial", "fingerprint"}
+}
+
+func (o *ledgerListOutput) Rows() [][]string {
+	rows := make([][]string, 0, len(o.Devices))
+	for _, d := range o.Devices {
+		rows = append(rows, []string{
+			strconv.Itoa(d.Index),
+			d.Path,
+			d.Model,
+			fmt.Sprintf("0x%04x", d.ProductID),
+			d.Serial,
+			d.Fingerprint,
+		})
+	}
+	return rows
+}
+
+// addLedgerFlags registers the flag that selects the Ledger device on c.
+func addLedgerFlags(c *cobra.Command) {
+	c.Flags().StringVar(&ledgerSelector, "ledger-device", "",
+		`Ledger device to use: index or serial from "wallet ledger list", or HID path (default: first device)`)
+}
+
+// ledgerDevice returns the Ledger device selected by --ledger-device, or the first one found if
+// the flag isn't set.
+func ledgerDevice() (wallet.LedgerDevice, error) {
+	if ledgerSelector == "" || strings.HasPrefix(ledgerSelector, "/") {
+		return wallet.SmkeysLedger(ledgerSelector), nil
+	}
+	devices, err := wallet.ListLedgers()
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range devices {
+		if d.Serial != "" && d.Serial == ledgerSelector {
+			return wallet.SmkeysLedger(d.Path), nil
+		}
+	}
+	if i, err := strconv.Atoi(ledgerSelector); err == nil && i >= 0 && i < len(devices) {
+		return wallet.SmkeysLedger(devices[i].Path), nil
+	}
+	return nil, fmt.Errorf("no Ledger device %q found (%d connected), see \"wallet ledger list\"",
+		ledgerSelector, len(devices))
+}
+
+// walletLedger returns the selected Ledger device after checking that it holds the keys of w.
+func walletLedger(w *wallet.Wallet) (wallet.LedgerDevice, error) {
+	device, err := ledgerDevice()
+	if err != nil {
+		return nil, err
+	}
+	if err := w.CheckLedger(device); err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+func init() {
+	walletCmd.AddCommand(ledgerCmd)
+	ledgerCmd.AddCommand(ledgerListCmd)
+}
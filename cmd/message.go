@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spf13/cobra"
+
+	"github.com/spacemeshos/smcli/wallet"
+)
+
+var (
+	// msgAccount is the index or HD path of the wallet account used to sign the message.
+	msgAccount string
+
+	// msgFile is a file containing the message to sign.
+	msgFile string
+
+	// msgOut is an optional file to write the signed message envelope to.
+	msgOut string
+
+	// msgPublicKey is the hex-encoded public key the message is expected to be signed with.
+	msgPublicKey string
+
+	// msgAddress is the address the message is expected to be signed by.
+	msgAddress string
+)
+
+// signMessageCmd signs an arbitrary message with a wallet account.
+var signMessageCmd = &cobra.Command{
+	Use:   "sign-message [wallet file] [message] [--file path] [--account n] [--out file]",
+	Short: "Sign a message with a wallet account",
+	Long: `Sign an arbitrary text message, or the contents of a file passed using --file, with the key of
+one of the accounts in the wallet file, e.g., to prove control of an address.
+
+The message is prefixed with "\x19Spacemesh Signed Message:\n" and its length before it's signed,
+so the signature can't be used as a transaction signature. The result is a JSON envelope that
+contains the message, the public key and address of the signer, and the signature. It can be
+checked using verify-message.
+
+With a Ledger wallet, the message is signed on the device selected by --ledger-device, which
+shows it and asks for confirmation.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		var msg []byte
+		switch {
+		case len(args) == 2 && msgFile != "":
+			cobra.CheckErr(errors.New("pass either a message or --file, not both"))
+		case len(args) == 2:
+			msg = []byte(args[1])
+		case msgFile != "":
+			var err error
+			msg, err = os.ReadFile(msgFile)
+			cobra.CheckErr(err)
+		default:
+			cobra.CheckErr(errors.New("a message or --file is required"))
+		}
+
+		w, err := openWallet(args[0])
+		cobra.CheckErr(err)
+		kp, err := walletAccount(w, msgAccount)
+		cobra.CheckErr(err)
+		signer, err := accountSigner(w, kp)
+		cobra.CheckErr(err)
+		signed, err := wallet.SignMessage(signer, msg, hrp)
+		cobra.CheckErr(err)
+
+		b, err := json.MarshalIndent(signed, "", "  ")
+		cobra.CheckErr(err)
+		if msgOut != "" {
+			cobra.CheckErr(os.WriteFile(msgOut, append(b, '\n'), 0o600))
+		}
+		if structured() {
+			cobra.CheckErr(printStructured(signed))
+			return
+		}
+		fmt.Println(string(b))
+	},
+}
+
+// verifyResult is the structured output of verify-message.
+type verifyResult struct {
+	Valid     bool   `json:"valid" yaml:"valid"`
+	PublicKey string `json:"publicKey" yaml:"publicKey"`
+	Address   string `json:"address,omitempty" yaml:"address,omitempty"`
+	Message   string `json:"message" yaml:"message"`
+	Encoding  string `json:"encoding" yaml:"encoding"`
+}
+
+// verifyMessageCmd verifies a signed message envelope.
+var verifyMessageCmd = &cobra.Command{
+	Use:   "verify-message [envelope file] [--public-key hex] [--address address]",
+	Short: "Verify a signed message",
+	Long: `Verify the signature of a message signed using sign-message. The JSON envelope is read from the
+given file, or from stdin if no file or "-" is given.
+
+If the envelope contains an address, it's checked to be the address of the public key. Use
+--public-key or --address to also check that the message was signed by the expected key or
+address; the address is derived from the public key in the envelope.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var data []byte
+		var err error
+		if len(args) == 0 || args[0] == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(args[0])
+		}
+		cobra.CheckErr(err)
+
+		signed, err := wallet.ReadSignedMessage(data)
+		cobra.CheckErr(err)
+		cobra.CheckErr(signed.Verify())
+		if msgPublicKey != "" {
+			cobra.CheckErr(signed.CheckPublicKey(msgPublicKey))
+		}
+		address := signed.Address
+		if msgAddress != "" {
+			cobra.CheckErr(signed.CheckAddress(msgAddress))
+			address = msgAddress
+		}
+
+		if structured() {
+			cobra.CheckErr(printStructured(&verifyResult{
+				Valid:     true,
+				PublicKey: signed.PublicKey,
+				Address:   address,
+				Message:   signed.Message,
+				Encoding:  signed.Encoding,
+			}))
+			return
+		}
+		fmt.Println("Signature is valid.")
+		fmt.Printf("Public key: %s\n", signed.PublicKey)
+		if address != "" {
+			fmt.Printf("Address: %s\n", address)
+		}
+		fmt.Printf("Message (%s): %s\n", signed.Encoding, signed.Message)
+	},
+}
+
+func init() {
+	walletCmd.AddCommand(signMessageCmd)
+	walletCmd.AddCommand(verifyMessageCmd)
+	signMessageCmd.Flags().StringVar(&msgAccount, "account", "0", "Index or HD path of the wallet account to sign with")
+	signMessageCmd.Flags().StringVar(&msgFile, "file", "", "Sign the contents of this file")
+	signMessageCmd.Flags().StringVar(&msgOut, "out", "", "Also write the signed message to this file")
+	signMessageCmd.Flags().StringVar(&hrp, "hrp", types.NetworkHRP(), "Set human-readable address prefix")
+	addLedgerFlags(signMessageCmd)
+	verifyMessageCmd.Flags().StringVar(&msgPublicKey, "public-key", "", "Expected hex-encoded public key of the signer")
+	verifyMessageCmd.Flags().StringVar(&msgAddress, "address", "", "Expected address of the signer")
+}
@@ -0,0 +1,318 @@
+package cmd
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/genvm/core"
+	"github.com/spf13/cobra"
+
+	"github.com/spacemeshos/smcli/common"
+	"github.com/spacemeshos/smcli/tx"
+	"github.com/spacemeshos/smcli/wallet"
+)
+
+var (
+	// msKeys are the hex-encoded public keys of the multisig account's signers.
+	msKeys []string
+
+	// msRequired is the number of signatures required by the multisig account.
+	msRequired uint8
+
+	// msTemplate is the template of the multisig account (multisig or vesting).
+	msTemplate string
+)
+
+// multisigCmd represents the multisig command.
+var multisigCmd = &cobra.Command{
+	Use:   "multisig",
+	Short: "Offline multisig transaction coordination",
+	Long: `Create, sign and finalize transactions for multisig (and vesting) accounts.
+
+Transactions are passed between signers as partially signed transaction files, so that each signer
+can add their signature on a separate, air-gapped machine. The workflow is:
+
+  1. create-spawn or create-spend produces an unsigned transaction file
+  2. each signer runs sign to add their signature to the file
+  3. inspect shows the transaction and which signatures are present
+  4. finalize produces a transaction that can be broadcast once enough signatures are present`,
+}
+
+// createSpawnCmd creates an unsigned multisig self-spawn transaction.
+var createSpawnCmd = &cobra.Command{
+	Use:   "create-spawn --key <pubkey>... --required n --genesis-id <id> --out <file>",
+	Short: "Create an unsigned self-spawn transaction for a multisig account",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		pubs, err := parsePublicKeys(msKeys)
+		cobra.CheckErr(err)
+		opts, err := txOptions("")
+		cobra.CheckErr(err)
+		p, err := tx.NewMultisigSpawn(msTemplate, msRequired, pubs, txNonce, opts...)
+		cobra.CheckErr(err)
+		cobra.CheckErr(writePartiallySigned(txOut, p))
+		cobra.CheckErr(printCreated(p, txOut))
+	},
+}
+
+// createSpendCmd creates an unsigned multisig spend transaction.
+var createSpendCmd = &cobra.Command{
+	Use:   "create-spend --key <pubkey>... --required n --to <address> --amount <amount> --genesis-id <id> --out <file>",
+	Short: "Create an unsigned spend transaction from a multisig account",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		types.SetNetworkHRP(hrp)
+		to, err := types.StringToAddress(txTo)
+		cobra.CheckErr(err)
+		amount, err := common.ParseAmountWithUnit(txAmount)
+		cobra.CheckErr(err)
+		pubs, err := parsePublicKeys(msKeys)
+		cobra.CheckErr(err)
+		opts, err := txOptions("")
+		cobra.CheckErr(err)
+		p, err := tx.NewMultisigSpend(msTemplate, msRequired, pubs, to, amount, txNonce, opts...)
+		cobra.CheckErr(err)
+		cobra.CheckErr(writePartiallySigned(txOut, p))
+		cobra.CheckErr(printCreated(p, txOut))
+	},
+}
+
+// msSignCmd adds a signature to a partially signed transaction.
+var msSignCmd = &cobra.Command{
+	Use:   "sign [tx file] [wallet file] [--account n]",
+	Short: "Add a signature from a wallet account to a partially signed transaction",
+	Long: `Sign a partially signed transaction using an account from the wallet file. By default the
+first account in the wallet that is one of the multisig signers is used; use --account to choose a
+specific one. The signature is added to the transaction file in place, or written to --out.
+
+With a Ledger wallet, the transaction is signed on the device selected by --ledger-device, which
+shows it and asks for confirmation.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		types.SetNetworkHRP(hrp)
+		p, err := readPartiallySigned(args[0])
+		cobra.CheckErr(err)
+		if !structured() {
+			printPartiallySigned(p)
+		}
+		cobra.CheckErr(signWithWallet(cmd, p, args[1]))
+
+		out := args[0]
+		if txOut != "" {
+			out = txOut
+		}
+		cobra.CheckErr(writePartiallySigned(out, p))
+		if structured() {
+			cobra.CheckErr(printStructured(newPartiallySignedOutput(p, out)))
+			return
+		}
+		fmt.Printf("Signature added. %d of %d required signatures present. Written to %s\n",
+			len(p.Signatures), p.Required, out)
+	},
+}
+
+// inspectCmd prints a partially signed transaction.
+var inspectCmd = &cobra.Command{
+	Use:   "inspect [tx file]",
+	Short: "Show a partially signed transaction and which signatures are present",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		types.SetNetworkHRP(hrp)
+		p, err := readPartiallySigned(args[0])
+		cobra.CheckErr(err)
+		if structured() {
+			cobra.CheckErr(printStructured(newPartiallySignedOutput(p, "")))
+			return
+		}
+		printPartiallySigned(p)
+	},
+}
+
+// finalizeCmd produces a broadcastable transaction from a partially signed transaction.
+var finalizeCmd = &cobra.Command{
+	Use:   "finalize [tx file] [--out file]",
+	Short: "Produce a broadcastable transaction once enough signatures are present",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		types.SetNetworkHRP(hrp)
+		p, err := readPartiallySigned(args[0])
+		cobra.CheckErr(err)
+		raw, err := p.Finalize()
+		cobra.CheckErr(err)
+		cobra.CheckErr(printTx(p.Principal().String(), raw, txOut))
+	},
+}
+
+// parsePublicKey parses a hex-encoded ed25519 public key.
+func parsePublicKey(s string) (core.PublicKey, error) {
+	key := core.PublicKey{}
+	keyBytes, err := hex.DecodeString(s)
+	if err != nil || len(keyBytes) != ed25519.PublicKeySize {
+		return key, fmt.Errorf("key is unreadable: %s", s)
+	}
+	copy(key[:], keyBytes)
+	return key, nil
+}
+
+func parsePublicKeys(keys []string) ([]core.PublicKey, error) {
+	pubs := make([]core.PublicKey, 0, len(keys))
+	for _, k := range keys {
+		pub, err := parsePublicKey(k)
+		if err != nil {
+			return nil, err
+		}
+		pubs = append(pubs, pub)
+	}
+	if len(pubs) == 0 {
+		return nil, errors.New("must provide at least one key")
+	}
+	return pubs, nil
+}
+
+// signWithWallet adds a signature to p using an account from the wallet file. The account is chosen
+// using the --account flag if it's set, otherwise the first account that is a signer is used.
+func signWithWallet(cmd *cobra.Command, p *tx.PartiallySignedTx, walletFn string) error {
+	w, err := openWallet(walletFn)
+	if err != nil {
+		return err
+	}
+	var kp *wallet.EDKeyPair
+	if cmd.Flags().Changed("account") {
+		kp, err = walletAccount(w, txAccount)
+	} else {
+		kp, err = signerAccount(w, p)
+	}
+	if err != nil {
+		return err
+	}
+	signer, err := accountSigner(w, kp)
+	if err != nil {
+		return err
+	}
+	return p.Sign(signer)
+}
+
+// signerAccount returns the first account in the wallet that is a signer of the transaction.
+func signerAccount(w *wallet.Wallet, p *tx.PartiallySignedTx) (*wallet.EDKeyPair, error) {
+	for _, a := range w.Secrets.Accounts {
+		if _, err := p.Ref(a.Public); err == nil {
+			return a, nil
+		}
+	}
+	return nil, errors.New("none of the accounts in the wallet is a signer of this transaction")
+}
+
+func readPartiallySigned(fn string) (*tx.PartiallySignedTx, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return tx.ReadPartiallySigned(f)
+}
+
+// writePartiallySigned writes p to fn through a temporary file in the same directory, so that
+// signing in place never leaves a truncated transaction file behind.
+func writePartiallySigned(fn string, p *tx.PartiallySignedTx) error {
+	if fn == "" {
+		return errors.New("output file is required, use --out")
+	}
+	f, err := os.CreateTemp(filepath.Dir(fn), "."+filepath.Base(fn)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // no-op once renamed
+	if err := f.Chmod(0o600); err != nil {
+		f.Close()
+		return err
+	}
+	if err := p.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), fn)
+}
+
+// printCreated reports that a new partially signed transaction was written to fn.
+func printCreated(p *tx.PartiallySignedTx, fn string) error {
+	if structured() {
+		return printStructured(newPartiallySignedOutput(p, fn))
+	}
+	fmt.Printf("Unsigned transaction for %s written to %s\n", p.Principal().String(), fn)
+	return nil
+}
+
+func printPartiallySigned(p *tx.PartiallySignedTx) {
+	fmt.Printf("Account: %s (%s, %d of %d)\n", p.Principal().String(), p.Template, p.Required, len(p.PublicKeys))
+	switch p.Kind {
+	case tx.KindSpawn:
+		fmt.Println("Transaction: self-spawn")
+	case tx.KindSpend:
+		fmt.Printf("Transaction: spend %s to %s\n", common.FormatAmount(p.Amount), p.To)
+	case tx.KindSpawnVault:
+		fmt.Printf("Transaction: spawn vault %s holding %s, vesting from layer %d to %d\n",
+			p.VaultAddress().String(), common.FormatAmount(p.Vault.TotalAmount), p.Vault.VestingStart, p.Vault.VestingEnd)
+	case tx.KindDrainVault:
+		fmt.Printf("Transaction: drain %s from vault %s to %s\n",
+			common.FormatAmount(p.Amount), p.VaultAddress().String(), p.To)
+	}
+	fmt.Printf("Nonce: %d\nFee (gas price): %d\nGenesis ID: %s\n", p.Nonce, p.GasPrice, hex.EncodeToString(p.GenesisID))
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.SetTitle("Signatures")
+	t.AppendHeader(table.Row{"ref", "pubkey", "address", "signed"})
+	for i, pub := range p.PublicKeys {
+		signed := "no"
+		if p.Signed(uint8(i)) {
+			signed = "yes"
+		}
+		t.AppendRow(table.Row{i, hex.EncodeToString(pub), wallet.PubkeyToAddress(pub, hrp), signed})
+	}
+	t.SetCaption("%d of %d required signatures present", len(p.Signatures), p.Required)
+	t.Render()
+}
+
+func init() {
+	rootCmd.AddCommand(multisigCmd)
+	multisigCmd.AddCommand(createSpawnCmd)
+	multisigCmd.AddCommand(createSpendCmd)
+	multisigCmd.AddCommand(msSignCmd)
+	multisigCmd.AddCommand(inspectCmd)
+	multisigCmd.AddCommand(finalizeCmd)
+	for _, c := range []*cobra.Command{createSpawnCmd, createSpendCmd} {
+		c.Flags().StringArrayVar(&msKeys, "key", nil, "Hex-encoded public key of a signer (repeat for each signer)")
+		c.Flags().Uint8Var(&msRequired, "required", 1, "Number of required signatures")
+		c.Flags().StringVar(&msTemplate, "template", tx.TemplateMultisig, "Account template: multisig or vesting")
+		c.Flags().Uint64Var(&txNonce, "nonce", 0, "Nonce of the transaction (the account's next nonce)")
+		c.Flags().Uint64Var(&txFee, "fee", 1, "Fee (gas price) in smidge per unit of gas")
+		c.Flags().StringVar(&txGenesisID, "genesis-id", "", "Hex-encoded genesis ID of the target network")
+		c.Flags().StringVar(&txOut, "out", "", "File to write the unsigned transaction to")
+		cobra.CheckErr(c.MarkFlagRequired("out"))
+	}
+	createSpendCmd.Flags().StringVar(&txTo, "to", "", "Address of the recipient")
+	createSpendCmd.Flags().StringVar(&txAmount, "amount", "",
+		"Amount to send with its unit, e.g. 1.5SMH or 1500000000smidge")
+	cobra.CheckErr(createSpendCmd.MarkFlagRequired("to"))
+	cobra.CheckErr(createSpendCmd.MarkFlagRequired("amount"))
+	msSignCmd.Flags().StringVar(&txAccount, "account", "0", "Index or HD path of the wallet account to sign with")
+	msSignCmd.Flags().StringVar(&txOut, "out", "", "Write the signed transaction here instead of updating it in place")
+	addLedgerFlags(msSignCmd)
+	finalizeCmd.Flags().StringVar(&txOut, "out", "", "Also write the raw signed transaction to this file")
+	for _, c := range []*cobra.Command{createSpawnCmd, createSpendCmd, msSignCmd, inspectCmd, finalizeCmd} {
+		c.Flags().StringVar(&hrp, "hrp", types.NetworkHRP(), "Set human-readable address prefix")
+	}
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/spacemeshos/smcli/common"
+	"github.com/spacemeshos/smcli/tx"
+	"github.com/spacemeshos/smcli/wallet"
+)
+
+var (
+	// outputFormat is the output format selected with --output.
+	outputFormat string
+
+	// output is the parsed output format.
+	output = common.OutputText
+)
+
+// structured reports whether a structured output format (rather than text) was selected.
+func structured() bool {
+	return output != common.OutputText
+}
+
+// printStructured prints v in the structured output format selected with --output.
+func printStructured(v any) error {
+	return common.WriteOutput(os.Stdout, output, v)
+}
+
+// prompt prints a prompt for interactive input. Prompts are written to stderr when a structured
+// output format is selected so that stdout only contains the result.
+func prompt(format string, a ...any) {
+	if structured() {
+		fmt.Fprintf(os.Stderr, format, a...)
+		return
+	}
+	fmt.Printf(format, a...)
+}
+
+// txOutput is the structured output of a signed transaction.
+type txOutput struct {
+	Principal   string `json:"principal" yaml:"principal"`
+	ID          string `json:"id" yaml:"id"`
+	Transaction string `json:"transaction" yaml:"transaction"`
+	File        string `json:"file,omitempty" yaml:"file,omitempty"`
+}
+
+func (o *txOutput) Header() []string {
+	return []string{"principal", "id", "transaction", "file"}
+}
+
+func (o *txOutput) Rows() [][]string {
+	return [][]string{{o.Principal, o.ID, o.Transaction, o.File}}
+}
+
+// signerOutput is the structured output of a signer of a partially signed transaction.
+type signerOutput struct {
+	Ref       uint8  `json:"ref" yaml:"ref"`
+	PublicKey string `json:"publicKey" yaml:"publicKey"`
+	Address   string `json:"address" yaml:"address"`
+	Signed    bool   `json:"signed" yaml:"signed"`
+}
+
+// partiallySignedOutput is the structured output of a partially signed transaction.
+type partiallySignedOutput struct {
+	Account     string         `json:"account" yaml:"account"`
+	Template    string         `json:"template" yaml:"template"`
+	Kind        string         `json:"kind" yaml:"kind"`
+	Required    uint8          `json:"required" yaml:"required"`
+	Signatures  int            `json:"signatures" yaml:"signatures"`
+	Nonce       uint64         `json:"nonce" yaml:"nonce"`
+	GasPrice    uint64         `json:"gasPrice" yaml:"gasPrice"`
+	GenesisID   string         `json:"genesisID" yaml:"genesisID"`
+	To          string         `json:"to,omitempty" yaml:"to,omitempty"`
+	Amount      uint64         `json:"amount,omitempty" yaml:"amount,omitempty"`
+	Vault       string         `json:"vault,omitempty" yaml:"vault,omitempty"`
+	Signers     []signerOutput `json:"signers" yaml:"signers"`
+	File        string         `json:"file,omitempty" yaml:"file,omitempty"`
+	Transaction *txOutput      `json:"transaction,omitempty" yaml:"transaction,omitempty"`
+}
+
+func newPartiallySignedOutput(p *tx.PartiallySignedTx, fn string) *partiallySignedOutput {
+	o := &partiallySignedOutput{
+		Account:    p.Principal().String(),
+		Template:   p.Template,
+		Kind:       p.Kind,
+		Required:   p.Required,
+		Signatures: len(p.Signatures),
+		Nonce:      p.Nonce,
+		GasPrice:   p.GasPrice,
+		GenesisID:  hex.EncodeToString(p.GenesisID),
+		To:         p.To,
+		Amount:     p.Amount,
+		File:       fn,
+	}
+	if p.Vault != nil {
+		o.Vault = p.VaultAddress().String()
+		if p.Kind == tx.KindSpawnVault {
+			o.Amount = p.Vault.TotalAmount
+		}
+	}
+	for i, pub := range p.PublicKeys {
+		o.Signers = append(o.Signers, signerOutput{
+			Ref:       uint8(i),
+			PublicKey: hex.EncodeToString(pub),
+			Address:   wallet.PubkeyToAddress(pub, hrp),
+			Signed:    p.Signed(uint8(i)),
+		})
+	}
+	return o
+}
+
+func (o *partiallySignedOutput) Header() []string {
+	return []string{"account", "kind", "ref", "pubkey", "address", "signed"}
+}
+
+func (o *partiallySignedOutput) Rows() [][]string {
+	rows := make([][]string, 0, len(o.Signers))
+	for _, s := range o.Signers {
+		rows = append(rows, []string{
+			o.Account, o.Kind, strconv.Itoa(int(s.Ref)), s.PublicKey, s.Address, strconv.FormatBool(s.Signed),
+		})
+	}
+	return rows
+}
+
+func init() {
+	names := make([]string, 0, len(common.OutputFormats))
+	for _, f := range common.OutputFormats {
+		names = append(names, string(f))
+	}
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "output", string(common.OutputText),
+		"Output format: "+strings.Join(names, ", "))
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		var err error
+		output, err = common.ParseOutputFormat(outputFormat)
+		return err
+	}
+}
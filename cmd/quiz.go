@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spacemeshos/smcli/wallet"
+)
+
+const (
+	// quizWords is the number of words of a new mnemonic the user is asked to re-enter.
+	quizWords = 3
+
+	// quizAttempts is the number of attempts the user gets for each word.
+	quizAttempts = 3
+
+	// clearScreen clears the terminal and its scrollback, so that the mnemonic is no longer shown.
+	clearScreen = "\033[H\033[2J\033[3J"
+)
+
+// verifyMnemonicBackup clears the screen and asks the user to re-enter randomly chosen words of the
+// mnemonic by position, to check that it was written down correctly before the wallet is saved.
+func verifyMnemonicBackup(m string) error {
+	words := wallet.MnemonicWords(m)
+	positions := rand.Perm(len(words))[:quizWords]
+	slices.Sort(positions)
+
+	prompt(clearScreen)
+	prompt("Let's check your backup. Enter the following words of your mnemonic.\n")
+	for _, i := range positions {
+		for attempt := 1; ; attempt++ {
+			prompt("Word #%d: ", i+1)
+			answer, err := readLine()
+			if err != nil {
+				return err
+			}
+			if slices.Equal(wallet.MnemonicWords(strings.ToLower(answer)), words[i:i+1]) {
+				break
+			}
+			if attempt == quizAttempts {
+				return fmt.Errorf("word #%d doesn't match the mnemonic; no wallet file was written, "+
+					"run create again to start over with a new mnemonic", i+1)
+			}
+			prompt("That's not word #%d of your mnemonic, try again.\n", i+1)
+		}
+	}
+	prompt("Your backup is correct.\n\n")
+	return nil
+}
+
+// readLine reads a line from stdin, one byte at a time so that nothing after it is consumed.
+func readLine() (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := os.Stdin.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				break
+			}
+			line = append(line, b[0])
+		}
+		if errors.Is(err, io.EOF) {
+			if len(line) == 0 {
+				return "", io.ErrUnexpectedEOF
+			}
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return strings.TrimSpace(string(line)), nil
+}
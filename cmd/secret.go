@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// readSecret reads a secret, such as a mnemonic or password, from a source that doesn't show it
+// on the command line or in the process list: "file:<path>" reads it from a file, "fd:<n>" from
+// an inherited file descriptor, and "env:<name>" from an environment variable. A single trailing
+// newline is removed, so that files written by echo or editors can be used.
+func readSecret(source string) (string, error) {
+	kind, arg, ok := strings.Cut(source, ":")
+	if !ok || arg == "" {
+		return "", fmt.Errorf("invalid secret source %q: expected file:<path>, fd:<n> or env:<name>", source)
+	}
+	var secret string
+	switch kind {
+	case "file":
+		b, err := os.ReadFile(arg)
+		if err != nil {
+			return "", err
+		}
+		secret = string(b)
+	case "fd":
+		fd, err := strconv.ParseUint(arg, 10, 31)
+		if err != nil {
+			return "", fmt.Errorf("invalid file descriptor %q", arg)
+		}
+		f := os.NewFile(uintptr(fd), "fd:"+arg)
+		if f == nil {
+			return "", fmt.Errorf("invalid file descriptor %q", arg)
+		}
+		defer f.Close()
+		b, err := io.ReadAll(f)
+		if err != nil {
+			return "", fmt.Errorf("reading file descriptor %s: %w", arg, err)
+		}
+		secret = string(b)
+	case "env":
+		v, ok := os.LookupEnv(arg)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", arg)
+		}
+		secret = v
+	default:
+		return "", fmt.Errorf("invalid secret source %q: expected file:<path>, fd:<n> or env:<name>", source)
+	}
+	secret = strings.TrimSuffix(secret, "\n")
+	return strings.TrimSuffix(secret, "\r"), nil
+}
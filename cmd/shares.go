@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-secure-stdlib/password"
+	"github.com/spf13/cobra"
+
+	"github.com/spacemeshos/smcli/wallet"
+)
+
+var (
+	// sharesThreshold is the number of SLIP-39 shares needed to restore a mnemonic.
+	sharesThreshold int
+
+	// sharesCount is the number of SLIP-39 shares to split a mnemonic into.
+	sharesCount int
+
+	// sharesOutDir is the directory to write each SLIP-39 share to a separate file in.
+	sharesOutDir string
+)
+
+// sharesCmd groups the SLIP-39 backup commands.
+var sharesCmd = &cobra.Command{
+	Use:   "shares",
+	Short: "SLIP-39 Shamir backups of the wallet mnemonic",
+}
+
+// sharesSplitCmd splits the mnemonic of a wallet into SLIP-39 shares.
+var sharesSplitCmd = &cobra.Command{
+	Use:   "split [wallet file] --threshold m --shares n [--out-dir dir]",
+	Short: "Split the wallet mnemonic into M-of-N SLIP-39 shares",
+	Long: `Split the secret encoded by the wallet's mnemonic into n SLIP-39 share mnemonics, any m of which
+are needed to restore it, so that no single person needs to hold the complete mnemonic. Fewer than m
+shares reveal nothing about it.
+
+Restore the wallet with "wallet create --from-shares", or --shares-from to read the shares from a file.
+The shares don't include the language of the mnemonic, so --language must be passed when restoring a
+mnemonic that isn't English, nor the BIP-39 passphrase, which is still needed to derive the accounts.
+
+Add --out-dir to write each share to a separate file, which must not exist, rather than printing
+them. If any file can't be written, the ones already written are removed. Ledger wallets have no
+mnemonic and can't be split.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		w, err := openWallet(args[0])
+		cobra.CheckErr(err)
+		if w.IsLedger() {
+			cobra.CheckErr(errors.New("Ledger wallets have no mnemonic to split"))
+		}
+		shares, err := wallet.SplitMnemonicSLIP39(w.Mnemonic(), sharesThreshold, sharesCount)
+		cobra.CheckErr(err)
+		language, err := wallet.ParseMnemonic(w.Mnemonic())
+		cobra.CheckErr(err)
+
+		o := &sharesOutput{
+			Threshold:     sharesThreshold,
+			Language:      language,
+			HasPassphrase: w.Meta.HasPassphrase,
+		}
+		if sharesOutDir != "" {
+			o.Files, err = writeShareFiles(sharesOutDir, shares)
+			cobra.CheckErr(err)
+		} else {
+			o.Shares = shares
+		}
+
+		if structured() {
+			cobra.CheckErr(printStructured(o))
+			return
+		}
+		for i, s := range o.Shares {
+			fmt.Printf("Share %d of %d: %s\n", i+1, len(shares), s)
+		}
+		for i, fn := range o.Files {
+			fmt.Printf("Share %d of %d written to %s\n", i+1, len(shares), fn)
+		}
+		restore := "smcli wallet create --from-shares"
+		if language != wallet.DefaultMnemonicLanguage {
+			restore += " --language " + language
+		}
+		fmt.Printf("Any %d of these shares restore the wallet using: %s\n", sharesThreshold, restore)
+		if w.Meta.HasPassphrase {
+			fmt.Println("The wallet's BIP-39 passphrase is also needed, and isn't part of the shares.")
+		}
+	},
+}
+
+// writeShareFiles writes each share to a new file in dir. If any of them can't be written, the files
+// already written are removed, so that a failed split doesn't leave an incomplete set of shares behind.
+func writeShareFiles(dir string, shares []string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(shares))
+	for i, s := range shares {
+		fn := filepath.Join(dir, fmt.Sprintf("share-%d-of-%d.txt", i+1, len(shares)))
+		err := writeShareFile(fn, s)
+		if err != nil {
+			for _, written := range files {
+				os.Remove(written)
+			}
+			return nil, err
+		}
+		files = append(files, fn)
+	}
+	return files, nil
+}
+
+// writeShareFile writes a share to a new file, removing it if the write fails.
+func writeShareFile(fn, share string) error {
+	f, err := os.OpenFile(fn, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(share + "\n")
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(fn)
+	}
+	return err
+}
+
+// sharesOutput is the structured output of the shares split command. Shares is empty if they were
+// written to Files.
+type sharesOutput struct {
+	Threshold     int      `json:"threshold" yaml:"threshold"`
+	Language      string   `json:"language" yaml:"language"`
+	HasPassphrase bool     `json:"hasPassphrase" yaml:"hasPassphrase"`
+	Shares        []string `json:"shares,omitempty" yaml:"shares,omitempty"`
+	Files         []string `json:"files,omitempty" yaml:"files,omitempty"`
+}
+
+func (o *sharesOutput) Header() []string {
+	return []string{"index", "threshold", "language", "has_passphrase", "share", "file"}
+}
+
+func (o *sharesOutput) Rows() [][]string {
+	n := max(len(o.Shares), len(o.Files))
+	rows := make([][]string, 0, n)
+	for i := 0; i < n; i++ {
+		var share, file string
+		if i < len(o.Shares) {
+			share = o.Shares[i]
+		}
+		if i < len(o.Files) {
+			file = o.Files[i]
+		}
+		rows = append(rows, []string{
+			strconv.Itoa(i + 1),
+			strconv.Itoa(o.Threshold),
+			o.Language,
+			strconv.FormatBool(o.HasPassphrase),
+			share,
+			file,
+		})
+	}
+	return rows
+}
+
+// readShares asks for SLIP-39 shares until enough were entered to restore the mnemonic, which is
+// returned in the given language. Shares that can't be decoded are asked for again.
+func readShares(language string) (string, error) {
+	var shares []string
+	for {
+		prompt("Enter SLIP-39 share #%d: ", len(shares)+1)
+		s, err := password.Read(os.Stdin)
+		prompt("\n")
+		if err != nil {
+			return "", err
+		}
+		if _, err := wallet.ParseSLIP39Share(s); err != nil {
+			prompt("%v, try again.\n", err)
+			continue
+		}
+		shares = append(shares, s)
+		m, err := wallet.MnemonicFromSLIP39(shares, language)
+		if errors.Is(err, wallet.ErrNotEnoughShares) {
+			prompt("%v.\n", err)
+			continue
+		}
+		return m, err
+	}
+}
+
+// sharesFromSource restores a mnemonic in the given language from the SLIP-39 shares read from a
+// secret source (see readSecret), one per line.
+func sharesFromSource(source, language string) (string, error) {
+	s, err := readSecret(source)
+	if err != nil {
+		return "", err
+	}
+	var shares []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			shares = append(shares, line)
+		}
+	}
+	return wallet.MnemonicFromSLIP39(shares, language)
+}
+
+func init() {
+	walletCmd.AddCommand(sharesCmd)
+	sharesCmd.AddCommand(sharesSplitCmd)
+	sharesSplitCmd.Flags().IntVar(&sharesThreshold, "threshold", 0, "Number of shares needed to restore the wallet")
+	sharesSplitCmd.Flags().IntVar(&sharesCount, "shares", 0,
+		fmt.Sprintf("Number of shares to create, at most %d", wallet.SLIP39MaxShares))
+	sharesSplitCmd.Flags().StringVar(&sharesOutDir, "out-dir", "", "Write each share to a separate file in this directory")
+	cobra.CheckErr(sharesSplitCmd.MarkFlagRequired("threshold"))
+	cobra.CheckErr(sharesSplitCmd.MarkFlagRequired("shares"))
+}
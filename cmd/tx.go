@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/genvm/sdk"
+	"github.com/spf13/cobra"
+
+	"github.com/spacemeshos/smcli/common"
+	"github.com/spacemeshos/smcli/tx"
+	"github.com/spacemeshos/smcli/wallet"
+)
+
+var (
+	// txAccount is the index or HD path of the wallet account used to sign the transaction.
+	txAccount string
+
+	// txNonce is the nonce of the transaction, i.e., the principal account's next nonce.
+	txNonce uint64
+
+	// txFee is the fee (gas price) in smidge paid per unit of gas.
+	txFee uint64
+
+	// txGenesisID is the hex-encoded genesis ID of the network the transaction is for.
+	txGenesisID string
+
+	// txTo is the address of the recipient of a spend transaction.
+	txTo string
+
+	// txAmount is the amount of a spend or drain transaction, with an explicit SMH or smidge unit.
+	txAmount string
+
+	// txOut is an optional file to write the raw signed transaction to.
+	txOut string
+)
+
+// txCmd represents the tx command.
+var txCmd = &cobra.Command{
+	Use:   "tx",
+	Short: "Offline transaction signing",
+	Long: `Build and sign transactions for wallet accounts without connecting to a node.
+
+Signed transactions are printed as hex (and optionally written as raw bytes using --out) so that
+they can be carried from an offline machine to an online one and broadcast from there.`,
+}
+
+// spawnCmd builds a self-spawn transaction.
+var spawnCmd = &cobra.Command{
+	Use:   "spawn [wallet file] --genesis-id <id> [--account n] [--nonce n] [--fee n]",
+	Short: "Sign a self-spawn transaction for a wallet account",
+	Long: `Sign a transaction that spawns the wallet template account for one of the accounts in the
+wallet file. An account must be spawned before it can spend.
+
+With a Ledger wallet, the transaction is signed on the device, which shows it and asks for
+confirmation.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		w, err := openWallet(args[0])
+		cobra.CheckErr(err)
+		kp, err := walletAccount(w, txAccount)
+		cobra.CheckErr(err)
+		opts, err := txOptions(w.Meta.GenesisID)
+		cobra.CheckErr(err)
+		signer, err := accountSigner(w, kp)
+		cobra.CheckErr(err)
+
+		raw, err := tx.SelfSpawn(signer, txNonce, opts...)
+		cobra.CheckErr(err)
+		cobra.CheckErr(printTx(wallet.PubkeyToAddress(kp.Public, hrp), raw, txOut))
+	},
+}
+
+// spendCmd builds a spend transaction.
+var spendCmd = &cobra.Command{
+	Use:   "spend [wallet file] --genesis-id <id> --to <address> --amount <amount> [--account n] [--nonce n] [--fee n]",
+	Short: "Sign a spend transaction from a wallet account",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		types.SetNetworkHRP(hrp)
+		to, err := types.StringToAddress(txTo)
+		cobra.CheckErr(err)
+		amount, err := common.ParseAmountWithUnit(txAmount)
+		cobra.CheckErr(err)
+
+		w, err := openWallet(args[0])
+		cobra.CheckErr(err)
+		kp, err := walletAccount(w, txAccount)
+		cobra.CheckErr(err)
+		opts, err := txOptions(w.Meta.GenesisID)
+		cobra.CheckErr(err)
+		signer, err := accountSigner(w, kp)
+		cobra.CheckErr(err)
+
+		raw, err := tx.Spend(signer, to, amount, txNonce, opts...)
+		cobra.CheckErr(err)
+		cobra.CheckErr(printTx(wallet.PubkeyToAddress(kp.Public, hrp), raw, txOut))
+	},
+}
+
+// walletAccount returns the account of the wallet selected by an index or HD path.
+func walletAccount(w *wallet.Wallet, selector string) (*wallet.EDKeyPair, error) {
+	return w.FindAccount(selector)
+}
+
+// accountSigner returns a signer for an account of w. Accounts of Ledger wallets sign using the
+// device selected by --ledger-device, once it's checked to hold the wallet's keys, and the user
+// confirms each request on the device.
+func accountSigner(w *wallet.Wallet, kp *wallet.EDKeyPair) (wallet.Signer, error) {
+	var device wallet.LedgerDevice
+	if w.IsLedger() {
+		var err error
+		device, err = walletLedger(w)
+		if err != nil {
+			return nil, err
+		}
+		prompt("Review and confirm the request on your Ledger device.\n")
+	}
+	return kp.Signer(device)
+}
+
+// txOptions returns the transaction options set by flags. defaultGenesisID (e.g., the one stored in
+// the wallet file) is used if no genesis ID is passed explicitly.
+func txOptions(defaultGenesisID string) ([]sdk.Opt, error) {
+	id := txGenesisID
+	if id == "" {
+		id = defaultGenesisID
+	}
+	genesisID, err := parseGenesisID(id)
+	if err != nil {
+		return nil, err
+	}
+	return []sdk.Opt{sdk.WithGenesisID(genesisID), sdk.WithGasPrice(txFee)}, nil
+}
+
+func parseGenesisID(s string) (types.Hash20, error) {
+	var id types.Hash20
+	if s == "" {
+		return id, errors.New("genesis ID is required, use --genesis-id")
+	}
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		return id, fmt.Errorf("invalid genesis ID: %w", err)
+	}
+	if len(b) != len(id) {
+		return id, fmt.Errorf("invalid genesis ID: expected %d bytes, got %d", len(id), len(b))
+	}
+	copy(id[:], b)
+	return id, nil
+}
+
+// printTx prints a signed transaction and writes it to outFn as raw bytes, if set.
+func printTx(principal string, raw []byte, outFn string) error {
+	if outFn != "" {
+		if err := os.WriteFile(outFn, raw, 0o600); err != nil {
+			return err
+		}
+	}
+	o := newTxOutput(principal, raw, outFn)
+	if structured() {
+		return printStructured(o)
+	}
+	if outFn != "" {
+		fmt.Printf("Raw transaction written to %s\n", outFn)
+	}
+	fmt.Printf("Principal: %s\n", o.Principal)
+	fmt.Printf("Transaction ID: %s\n", o.ID)
+	fmt.Printf("Signed transaction: %s\n", o.Transaction)
+	return nil
+}
+
+func newTxOutput(principal string, raw []byte, outFn string) *txOutput {
+	return &txOutput{
+		Principal:   principal,
+		ID:          tx.ID(raw).String(),
+		Transaction: hex.EncodeToString(raw),
+		File:        outFn,
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(txCmd)
+	txCmd.AddCommand(spawnCmd)
+	txCmd.AddCommand(spendCmd)
+	for _, c := range []*cobra.Command{spawnCmd, spendCmd} {
+		c.Flags().StringVar(&txAccount, "account", "0", "Index or HD path of the wallet account to sign with")
+		c.Flags().Uint64Var(&txNonce, "nonce", 0, "Nonce of the transaction (the account's next nonce)")
+		c.Flags().Uint64Var(&txFee, "fee", 1, "Fee (gas price) in smidge per unit of gas")
+		c.Flags().StringVar(&txGenesisID, "genesis-id", "", "Hex-encoded genesis ID of the target network")
+		c.Flags().StringVar(&txOut, "out", "", "Also write the raw signed transaction to this file")
+		c.Flags().StringVar(&hrp, "hrp", types.NetworkHRP(), "Set human-readable address prefix")
+		addLedgerFlags(c)
+	}
+	spendCmd.Flags().StringVar(&txTo, "to", "", "Address of the recipient")
+	spendCmd.Flags().StringVar(&txAmount, "amount", "", "Amount to send with its unit, e.g. 1.5SMH or 1500000000smidge")
+	cobra.CheckErr(spendCmd.MarkFlagRequired("to"))
+	cobra.CheckErr(spendCmd.MarkFlagRequired("amount"))
+}
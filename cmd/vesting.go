@@ -0,0 +1,249 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/genvm/core"
+	"github.com/spacemeshos/go-spacemesh/genvm/sdk"
+	"github.com/spacemeshos/go-spacemesh/genvm/templates/vault"
+	"github.com/spf13/cobra"
+
+	"github.com/spacemeshos/smcli/common"
+	"github.com/spacemeshos/smcli/genesis"
+	"github.com/spacemeshos/smcli/tx"
+)
+
+var (
+	// vestVaultAmount is the total amount held by the vault, in SMH or smidge.
+	vestVaultAmount string
+
+	// vestWallet is an optional wallet file used to sign the transaction right away.
+	vestWallet string
+
+	// vestLayer is the layer at which to compute the unlocked amount.
+	vestLayer uint32
+)
+
+// vestingCmd represents the vesting command.
+var vestingCmd = &cobra.Command{
+	Use:   "vesting",
+	Short: "Vesting and vault operations",
+	Long: `Operate genesis vesting accounts and their vaults.
+
+Each genesis allocation consists of a vesting account (a multisig account owned by one or more
+keys) and a vault holding the allocated funds, which unlock gradually between the vesting start
+and end layers. The vesting account must be spawned first; it then spawns the vault and can drain
+unlocked funds from the vault.
+
+The keys, number of required signatures and vault amount are the same inputs used by
+"genesis verify". Transactions are written as partially signed transaction files (see "multisig").
+Pass --wallet to sign with a local wallet right away; if that provides enough signatures, the
+broadcastable transaction is printed as well. With a Ledger wallet, the transaction is signed on
+the device selected by --ledger-device, which shows it and asks for confirmation.`,
+}
+
+// vestingSpawnCmd spawns a vesting account.
+var vestingSpawnCmd = &cobra.Command{
+	Use:   "spawn --key <pubkey>... --required n --genesis-id <id> --out <file> [--wallet file]",
+	Short: "Create a self-spawn transaction for a vesting account",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		pubs, opts := vestingInputs()
+		p, err := tx.NewMultisigSpawn(tx.TemplateVesting, msRequired, pubs, txNonce, opts...)
+		cobra.CheckErr(err)
+		finishVestingTx(cmd, p)
+	},
+}
+
+// vaultSpawnCmd spawns a vault from a vesting account.
+var vaultSpawnCmd = &cobra.Command{
+	Use:   "spawn-vault --key <pubkey>... --required n --vault-amount <amount> --genesis-id <id> --out <file>",
+	Short: "Create a transaction in which a vesting account spawns its vault",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		pubs, opts := vestingInputs()
+		vaultArgs, err := vestingVaultArgs(pubs)
+		cobra.CheckErr(err)
+		p, err := tx.NewVaultSpawn(msRequired, pubs, vaultArgs, txNonce, opts...)
+		cobra.CheckErr(err)
+		finishVestingTx(cmd, p)
+	},
+}
+
+// drainCmd drains a vault into a vesting account.
+var drainCmd = &cobra.Command{
+	Use:   "drain --key <pubkey>... --required n --vault-amount <amount> --amount <amount> --genesis-id <id> --out <file>",
+	Short: "Create a transaction that drains unlocked funds from a vault",
+	Long: `Create a transaction that drains unlocked funds from the vault into the vesting account, or
+into another account given by --to. Only funds that have already unlocked can be drained; use
+"vesting unlocked" to see how much that is at a given layer.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		pubs, opts := vestingInputs()
+		vaultArgs, err := vestingVaultArgs(pubs)
+		cobra.CheckErr(err)
+		to := vaultArgs.Owner
+		if txTo != "" {
+			to, err = types.StringToAddress(txTo)
+			cobra.CheckErr(err)
+		}
+		amount, err := common.ParseAmountWithUnit(txAmount)
+		cobra.CheckErr(err)
+		p, err := tx.NewDrainVault(msRequired, pubs, vaultArgs, to, amount, txNonce, opts...)
+		cobra.CheckErr(err)
+		finishVestingTx(cmd, p)
+	},
+}
+
+// unlockedCmd shows how much of a vault has unlocked.
+var unlockedCmd = &cobra.Command{
+	Use:   "unlocked --key <pubkey>... --required n --vault-amount <amount> --layer n [--schedule]",
+	Short: "Show how much of a vault has unlocked at a given layer",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		types.SetNetworkHRP(hrp)
+		pubs, err := parsePublicKeys(msKeys)
+		cobra.CheckErr(err)
+		vaultArgs, err := vestingVaultArgs(pubs)
+		cobra.CheckErr(err)
+		unlocked := genesis.Unlocked(vaultArgs, types.LayerID(vestLayer))
+
+		if structured() {
+			out := &unlockedOutput{
+				genesisAccount: newGenesisAccount(pubs, msRequired, vaultArgs),
+				Layer:          vestLayer,
+				Unlocked:       unlocked,
+				Locked:         vaultArgs.TotalAmount - unlocked,
+			}
+			if showSchedule {
+				out.Schedule = genesis.UnlockSchedule(vaultArgs, scheduleStep)
+			}
+			cobra.CheckErr(printStructured(out))
+			return
+		}
+		fmt.Printf("Vesting address: %s\nVault address: %s\n",
+			vaultArgs.Owner.String(), genesis.VaultAddress(vaultArgs).String())
+		fmt.Printf("Vesting schedule: layer %d to layer %d\n", vaultArgs.VestingStart, vaultArgs.VestingEnd)
+		fmt.Printf("Total amount: %s\n", common.FormatAmount(vaultArgs.TotalAmount))
+		fmt.Printf("Unlocked at layer %d: %s\n", vestLayer, common.FormatAmount(unlocked))
+		fmt.Printf("Still locked: %s\n", common.FormatAmount(vaultArgs.TotalAmount-unlocked))
+		if showSchedule {
+			cobra.CheckErr(printUnlockSchedule(vaultArgs, genesis.UnlockSchedule(vaultArgs, scheduleStep)))
+		}
+	},
+}
+
+// unlockedOutput is the structured output of the unlocked command.
+type unlockedOutput struct {
+	*genesisAccount `yaml:",inline"`
+	Layer           uint32 `json:"layer" yaml:"layer"`
+	Unlocked        uint64 `json:"unlocked" yaml:"unlocked"`
+	Locked          uint64 `json:"locked" yaml:"locked"`
+}
+
+func (o *unlockedOutput) Header() []string {
+	return append(o.genesisAccount.Header(), "layer", "unlocked", "locked")
+}
+
+func (o *unlockedOutput) Rows() [][]string {
+	row := o.genesisAccount.Rows()[0]
+	row = append(row,
+		strconv.FormatUint(uint64(o.Layer), 10),
+		strconv.FormatUint(o.Unlocked, 10),
+		strconv.FormatUint(o.Locked, 10),
+	)
+	return [][]string{row}
+}
+
+// vestingInputs parses the keys and transaction options common to all vesting transactions.
+func vestingInputs() ([]core.PublicKey, []sdk.Opt) {
+	types.SetNetworkHRP(hrp)
+	pubs, err := parsePublicKeys(msKeys)
+	cobra.CheckErr(err)
+	opts, err := txOptions("")
+	cobra.CheckErr(err)
+	return pubs, opts
+}
+
+// vestingVaultArgs returns the spawn arguments of the vault owned by the vesting account of pubs,
+// using the vesting schedule set by flags.
+func vestingVaultArgs(pubs []core.PublicKey) (*vault.SpawnArguments, error) {
+	schedule, err := vestingSchedule()
+	if err != nil {
+		return nil, err
+	}
+	vestingArgs, err := genesis.VestingArgs(pubs, msRequired)
+	if err != nil {
+		return nil, err
+	}
+	amount, err := common.ParseAmount(vestVaultAmount)
+	if err != nil {
+		return nil, err
+	}
+	return schedule.VaultArgs(genesis.VestingAddress(vestingArgs), amount), nil
+}
+
+// finishVestingTx optionally signs p with the local wallet, writes it to the --out file, and prints
+// the final transaction if it has enough signatures.
+func finishVestingTx(cmd *cobra.Command, p *tx.PartiallySignedTx) {
+	if !structured() {
+		printPartiallySigned(p)
+	}
+	if vestWallet != "" {
+		cobra.CheckErr(signWithWallet(cmd, p, vestWallet))
+	}
+	cobra.CheckErr(writePartiallySigned(txOut, p))
+	if structured() {
+		o := newPartiallySignedOutput(p, txOut)
+		if p.Complete() {
+			raw, err := p.Finalize()
+			cobra.CheckErr(err)
+			o.Transaction = newTxOutput(p.Principal().String(), raw, "")
+		}
+		cobra.CheckErr(printStructured(o))
+		return
+	}
+	fmt.Printf("Transaction written to %s. %d of %d required signatures present.\n",
+		txOut, len(p.Signatures), p.Required)
+	if p.Complete() {
+		raw, err := p.Finalize()
+		cobra.CheckErr(err)
+		cobra.CheckErr(printTx(p.Principal().String(), raw, ""))
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(vestingCmd)
+	vestingCmd.AddCommand(vestingSpawnCmd)
+	vestingCmd.AddCommand(vaultSpawnCmd)
+	vestingCmd.AddCommand(drainCmd)
+	vestingCmd.AddCommand(unlockedCmd)
+	for _, c := range []*cobra.Command{vestingSpawnCmd, vaultSpawnCmd, drainCmd, unlockedCmd} {
+		c.Flags().StringArrayVar(&msKeys, "key", nil, "Hex-encoded public key of a signer (repeat for each signer)")
+		c.Flags().Uint8Var(&msRequired, "required", 1, "Number of required signatures")
+		c.Flags().StringVar(&hrp, "hrp", types.NetworkHRP(), "Set human-readable address prefix")
+	}
+	for _, c := range []*cobra.Command{vaultSpawnCmd, drainCmd, unlockedCmd} {
+		c.Flags().StringVar(&vestVaultAmount, "vault-amount", "", "Total amount held by the vault, e.g. 100SMH")
+		cobra.CheckErr(c.MarkFlagRequired("vault-amount"))
+		addScheduleFlags(c)
+	}
+	for _, c := range []*cobra.Command{vestingSpawnCmd, vaultSpawnCmd, drainCmd} {
+		c.Flags().Uint64Var(&txNonce, "nonce", 0, "Nonce of the transaction (the vesting account's next nonce)")
+		c.Flags().Uint64Var(&txFee, "fee", 1, "Fee (gas price) in smidge per unit of gas")
+		c.Flags().StringVar(&txGenesisID, "genesis-id", "", "Hex-encoded genesis ID of the target network")
+		c.Flags().StringVar(&txOut, "out", "", "File to write the partially signed transaction to")
+		c.Flags().StringVar(&vestWallet, "wallet", "", "Sign with an account from this wallet file")
+		c.Flags().StringVar(&txAccount, "account", "0", "Index or HD path of the wallet account to sign with")
+		addLedgerFlags(c)
+		cobra.CheckErr(c.MarkFlagRequired("out"))
+	}
+	drainCmd.Flags().StringVar(&txTo, "to", "", "Address to drain funds to (default: the vesting account)")
+	drainCmd.Flags().StringVar(&txAmount, "amount", "", "Amount to drain with its unit, e.g. 1.5SMH or 1500000000smidge")
+	cobra.CheckErr(drainCmd.MarkFlagRequired("amount"))
+	unlockedCmd.Flags().Uint32Var(&vestLayer, "layer", 0, "Layer at which to compute the unlocked amount")
+	cobra.CheckErr(unlockedCmd.MarkFlagRequired("layer"))
+	addScheduleTableFlags(unlockedCmd)
+}
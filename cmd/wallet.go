@@ -1,11 +1,14 @@
 package cmd
 
 import (
+	"bytes"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"math"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -38,10 +41,65 @@ var (
 	// useLedger indicates that the Ledger device should be used.
 	useLedger bool
 
+	// mnemonicFrom, passphraseFrom and passwordFrom are the sources (see readSecret) of the
+	// mnemonic, BIP-39 passphrase and password of a new wallet, if they aren't entered interactively.
+	mnemonicFrom, passphraseFrom, passwordFrom string
+
+	// assumeYes indicates that create shouldn't ask for confirmation, and should generate a new
+	// mnemonic rather than ask for one unless --mnemonic-from is set.
+	assumeYes bool
+
+	// entropyKind is the kind of user-supplied entropy (dice, coins or hex) to generate a new mnemonic
+	// from, and entropyFrom is its source (see readSecret) if it isn't entered interactively.
+	entropyKind, entropyFrom string
+
+	// mixRandom indicates that user-supplied entropy should be XORed with random bytes from the
+	// operating system.
+	mixRandom bool
+
+	// fromShares indicates that create should restore the mnemonic from SLIP-39 shares entered
+	// interactively, and sharesFrom is the source of SLIP-39 shares to restore it from, one per line.
+	fromShares bool
+	sharesFrom string
+
+	// skipVerify indicates that create shouldn't ask the user to re-enter words of a new mnemonic.
+	skipVerify bool
+
+	// mnemonicWords and mnemonicLanguage are the length and wordlist language of a new mnemonic.
+	mnemonicWords    int
+	mnemonicLanguage string
+
+	// createOut is the path to write a new wallet file to, instead of a timestamped file in the
+	// smcli directory.
+	createOut string
+
 	// hrp is the human-readable network identifier used in Spacemesh network addresses.
 	hrp string
+
+	// keepBackup indicates that the backup of a replaced wallet file should be kept.
+	keepBackup bool
+
+	// kdfName is the KDF used to derive the wallet encryption key from the password.
+	kdfName string
+
+	// pbkdf2Iterations is the number of PBKDF2 iterations.
+	pbkdf2Iterations int
+
+	// argon2Time, argon2Memory (in MiB) and argon2Threads are the Argon2id cost parameters.
+	argon2Time    uint32
+	argon2Memory  uint32
+	argon2Threads uint8
+
+	// scryptN, scryptR and scryptP are the scrypt cost parameters.
+	scryptN, scryptR, scryptP int
 )
 
+// walletCredentials are the password and KDF used to encrypt a wallet file.
+type walletCredentials struct {
+	password []byte
+	kdf      wallet.KDF
+}
+
 // walletCmd represents the wallet command.
 var walletCmd = &cobra.Command{
 	Use:   "wallet",
@@ -50,14 +108,44 @@ var walletCmd = &cobra.Command{
 
 // createCmd represents the create command.
 var createCmd = &cobra.Command{
-	Use:   "create [--ledger] [numaccounts]",
+	Use:   "create [--ledger] [--account n] [--chain n] [--path path] [numaccounts]",
 	Short: "Generate a new wallet file from a BIP-39-compatible mnemonic or Ledger device",
 	Long: `Create a new wallet file containing one or more accounts using a BIP-39-compatible mnemonic
 or a Ledger hardware wallet. If using a mnemonic you can choose to use an existing mnemonic or generate
-a new, random mnemonic.
+a new, random mnemonic. You may also provide an optional BIP-39 passphrase (sometimes called the
+"25th word"), which is mixed into the seed and is required, along with the mnemonic, to restore the wallet.
 
 Add --ledger to instead read the public key from a Ledger device. If using a Ledger device please make
-sure the device is connected, unlocked, and the Spacemesh app is open.`,
+sure the device is connected, unlocked, and the Spacemesh app is open. If more than one Ledger device is
+connected, select one using --ledger-device (see "wallet ledger list"). The fingerprint of the device is
+stored in the wallet file, and is checked whenever the device is used with the wallet.
+
+New mnemonics have 24 English words by default. Use --words to generate a 12, 15, 18 or 21-word
+mnemonic instead, and --language to use another BIP-39 wordlist (english, spanish, french, italian,
+czech, japanese, korean, chinese-simplified or chinese-traditional). The language of an existing
+mnemonic is detected automatically.
+
+To generate the mnemonic from your own entropy rather than only the operating system's random number
+generator, e.g. on an air-gapped machine, add --entropy dice, coins or hex and enter the dice rolls,
+coin flips or hex digits, or pass them using --entropy-from. Add --mix-random to XOR them with random
+bytes from the operating system.
+
+To restore a wallet from SLIP-39 shares created by "wallet shares split", add --from-shares to enter
+them one at a time, or pass them one per line using --shares-from. Pass --language if the original
+mnemonic wasn't English.
+
+After a new mnemonic is shown, the screen is cleared and you're asked to re-enter a few of its words
+to check your backup before the wallet file is written. Add --skip-verify to skip this check.
+
+Accounts are derived at m/44'/540'/0'/0'/index'. Use --account and --chain to derive them in another
+BIP-44 account or chain, or --path to pass the chain or the path of the first account.
+
+For scripted provisioning, pass the mnemonic, passphrase and password using --mnemonic-from,
+--passphrase-from and --password-from, as file:<path>, fd:<n> or env:<name>. With --yes, nothing is
+confirmed interactively, and a new mnemonic is generated unless --mnemonic-from is set; it's printed
+along with the accounts. --yes requires --password-from, --entropy-from if --entropy is set, and
+--shares-from rather than --from-shares. Use --out to choose the path of the wallet file, which must not exist, and
+--output json to describe the created file and its accounts in JSON.`,
 	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		// get the number of accounts to create
@@ -67,78 +155,235 @@ sure the device is connected, unlocked, and the Spacemesh app is open.`,
 			cobra.CheckErr(err)
 			n = int(tmpN)
 		}
+		if n < 0 || n > common.MaxAccountsPerWallet {
+			cobra.CheckErr(fmt.Errorf("invalid number of accounts %d", n))
+		}
+
+		// check the KDF parameters before doing anything else
+		kdf, err := kdfFromFlags(cmd, wallet.NewPBKDF2())
+		cobra.CheckErr(err)
+		chain, start, _, err := pathFromFlags(cmd)
+		cobra.CheckErr(err)
+
+		// read the secrets passed non-interactively first, so that bad sources fail early
+		var mnemonic, passphrase, walletPassword string
+		if mnemonicFrom != "" {
+			mnemonic, err = readSecret(mnemonicFrom)
+			cobra.CheckErr(err)
+		}
+		if passphraseFrom != "" {
+			passphrase, err = readSecret(passphraseFrom)
+			cobra.CheckErr(err)
+		}
+		if passwordFrom != "" {
+			walletPassword, err = readSecret(passwordFrom)
+			cobra.CheckErr(err)
+		}
+		if useLedger && (mnemonicFrom != "" || passphraseFrom != "") {
+			cobra.CheckErr(errors.New("--mnemonic-from and --passphrase-from can't be used with --ledger"))
+		}
+		restoring := fromShares || sharesFrom != ""
+		if restoring && (useLedger || mnemonicFrom != "") {
+			cobra.CheckErr(errors.New("--from-shares and --shares-from can't be used with --ledger or --mnemonic-from"))
+		}
+		if (useLedger || mnemonicFrom != "" || restoring) && cmd.Flags().Changed("words") {
+			cobra.CheckErr(errors.New("--words only applies to new mnemonics"))
+		}
+		if (useLedger || mnemonicFrom != "") && cmd.Flags().Changed("language") {
+			cobra.CheckErr(errors.New("--language only applies to new mnemonics and mnemonics restored from shares"))
+		}
+		if (entropyFrom != "" || mixRandom) && entropyKind == "" {
+			cobra.CheckErr(errors.New("--entropy-from and --mix-random require --entropy"))
+		}
+		if entropyKind != "" && (useLedger || mnemonicFrom != "" || restoring) {
+			cobra.CheckErr(errors.New("--entropy only applies to new mnemonics"))
+		}
+		// --yes never falls back to prompting for secrets
+		if assumeYes && passwordFrom == "" {
+			cobra.CheckErr(errors.New("--yes requires --password-from"))
+		}
+		if assumeYes && entropyKind != "" && entropyFrom == "" {
+			cobra.CheckErr(errors.New("--entropy with --yes requires --entropy-from"))
+		}
+		if assumeYes && fromShares {
+			cobra.CheckErr(errors.New("--from-shares can't be used with --yes, use --shares-from"))
+		}
+		if sharesFrom != "" {
+			mnemonic, err = sharesFromSource(sharesFrom, mnemonicLanguage)
+			cobra.CheckErr(err)
+		}
+
+		// generate the new mnemonic up front, so that a bad length or language fails before prompting
+		var newMnemonic string
+		if !useLedger && mnemonicFrom == "" && !restoring {
+			newMnemonic, err = wallet.NewMnemonic(mnemonicWords, mnemonicLanguage)
+			cobra.CheckErr(err)
+			if entropyKind != "" {
+				e, err := userEntropy(entropyKind, entropyFrom, mnemonicWords)
+				cobra.CheckErr(err)
+				newMnemonic, err = e.Mnemonic(mnemonicWords, mnemonicLanguage, mixRandom)
+				cobra.CheckErr(err)
+				mixed := ""
+				if mixRandom {
+					mixed = ", mixed with random bytes from the operating system"
+				}
+				prompt("Generated the mnemonic from %.1f bits of entropy in %d %s symbols%s.\n", e.Bits(), e.Len(),
+					entropyKind, mixed)
+			}
+		}
 
 		var w *wallet.Wallet
-		var err error
+		var seed []byte
+		var device wallet.LedgerDevice
+		generated := false
 
 		// Short-circuit and check for a ledger device
 		if useLedger {
-			w, err = wallet.NewMultiWalletFromLedger(n)
+			device, err = ledgerDevice()
 			cobra.CheckErr(err)
-			fmt.Println("Note that, when using a hardware wallet, the wallet file I'm about to produce won't " +
-				"contain any private keys or mnemonics, but you may still choose to encrypt it to protect privacy.")
-		} else {
-			// get or generate the mnemonic
-			fmt.Print("Enter a BIP-39-compatible mnemonic (or leave blank to generate a new one): ")
-			text, err := password.Read(os.Stdin)
-			fmt.Println()
+			w, err = wallet.NewMultiWalletFromLedger(device, 0)
 			cobra.CheckErr(err)
-			fmt.Print("Note: This application does not yet support BIP-39-compatible optional passwords. ")
-			fmt.Println("Support will be added soon.")
+			prompt("Note that, when using a hardware wallet, the wallet file I'm about to produce won't " +
+				"contain any private keys or mnemonics, but you may still choose to encrypt it to protect privacy.\n")
+		} else {
+			// get or generate the mnemonic, prompting only if it isn't passed non-interactively
+			interactive := mnemonicFrom == "" && !restoring && !assumeYes
+			if interactive && entropyKind == "" {
+				prompt("Enter a BIP-39-compatible mnemonic (or leave blank to generate a new one): ")
+				mnemonic, err = password.Read(os.Stdin)
+				prompt("\n")
+				cobra.CheckErr(err)
+			}
 
 			// It's critical that we trim whitespace, including CRLF. Otherwise it will get included in the mnemonic.
-			text = strings.TrimSpace(text)
+			mnemonic = strings.TrimSpace(mnemonic)
+
+			if fromShares {
+				mnemonic, err = readShares(mnemonicLanguage)
+				cobra.CheckErr(err)
+			}
+
+			if (interactive || fromShares) && passphraseFrom == "" {
+				passphrase, err = readPassphrase()
+				cobra.CheckErr(err)
+			}
 
-			if text == "" {
-				w, err = wallet.NewMultiWalletRandomMnemonic(n)
+			if mnemonic == "" {
+				w, err = wallet.NewMultiWalletFromMnemonic(newMnemonic, passphrase, 0)
 				cobra.CheckErr(err)
-				fmt.Print("\nThis is your mnemonic (seed phrase). Write it down and store it safely.")
-				fmt.Print("It is the ONLY way to restore your wallet.\n")
-				fmt.Print("Neither Spacemesh nor anyone else can help you restore your wallet without this mnemonic.\n")
-				fmt.Print("\n***********************************\n")
-				fmt.Print("SAVE THIS MNEMONIC IN A SAFE PLACE!")
-				fmt.Print("\n***********************************\n")
-				fmt.Println()
-				fmt.Println(w.Mnemonic())
-				fmt.Println("\nPress enter when you have securely saved your mnemonic.")
-				_, _ = fmt.Scanln()
+				generated = true
+				if !assumeYes {
+					prompt("\nThis is your mnemonic (seed phrase). Write it down and store it safely.")
+					prompt("It is the ONLY way to restore your wallet.\n")
+					prompt("Neither Spacemesh nor anyone else can help you restore your wallet without this mnemonic.\n")
+					prompt("\n***********************************\n")
+					prompt("SAVE THIS MNEMONIC IN A SAFE PLACE!")
+					prompt("\n***********************************\n")
+					prompt("\n%s\n", w.Mnemonic())
+					prompt("\nPress enter when you have securely saved your mnemonic.\n")
+					_, _ = fmt.Scanln()
+					if !skipVerify {
+						cobra.CheckErr(verifyMnemonicBackup(w.Mnemonic()))
+					}
+				}
 			} else {
 				// try to use as a mnemonic
-				w, err = wallet.NewMultiWalletFromMnemonic(text, n)
+				w, err = wallet.NewMultiWalletFromMnemonic(mnemonic, passphrase, 0)
 				cobra.CheckErr(err)
+				if language, _ := wallet.ParseMnemonic(mnemonic); language != wallet.DefaultMnemonicLanguage {
+					prompt("Using a mnemonic from the %s wordlist.\n", language)
+				}
 			}
+			seed, err = w.Seed(passphrase)
+			cobra.CheckErr(err)
 		}
 
-		fmt.Print("Enter a secure password used to encrypt the wallet file (optional but strongly recommended): ")
-		password, err := password.Read(os.Stdin)
-		fmt.Println()
-		cobra.CheckErr(err)
-		wk := wallet.NewKey(wallet.WithRandomSalt(), wallet.WithPbkdf2Password([]byte(password)))
-		err = os.MkdirAll(common.DotDirectory(), 0o700)
-		cobra.CheckErr(err)
-
-		// Make sure we're not overwriting an existing wallet (this should not happen)
-		walletFn := common.WalletFile()
-		_, err = os.Stat(walletFn)
-		switch {
-		case errors.Is(err, os.ErrNotExist):
-			// all fine
-		case err == nil:
-			log.Fatalln("Wallet file already exists")
-		default:
-			log.Fatalf("Error opening %s: %v\n", walletFn, err)
+		// derive the accounts, starting at the index of --path if it's an account path
+		if useLedger && n > 0 {
+			prompt("Confirm the new accounts on your Ledger device.\n")
+		}
+		for i := 0; i < n; i++ {
+			_, err := w.AddAccount(seed, device, chain.Extend(wallet.BIP44HardenedAccountIndex(start+uint32(i))), "")
+			cobra.CheckErr(err)
 		}
 
-		// Now open for writing
-		f2, err := os.OpenFile(walletFn, os.O_WRONLY|os.O_CREATE, 0o600)
+		if passwordFrom == "" {
+			prompt("Enter a secure password used to encrypt the wallet file (optional but strongly recommended): ")
+			walletPassword, err = password.Read(os.Stdin)
+			prompt("\n")
+			cobra.CheckErr(err)
+		}
+		wk, err := wallet.NewKey(wallet.WithRandomSalt(), wallet.WithKDFPassword(kdf, []byte(walletPassword)))
 		cobra.CheckErr(err)
-		defer f2.Close()
-		cobra.CheckErr(wk.Export(f2, w))
 
+		walletFn := createOut
+		if walletFn == "" {
+			walletFn = common.WalletFile()
+		}
+		cobra.CheckErr(os.MkdirAll(filepath.Dir(walletFn), 0o700))
+		cobra.CheckErr(writeNewWallet(walletFn, &wk, w))
+
+		if structured() {
+			o := &createOutput{File: walletFn, walletOutput: newWalletOutput(w, hex.EncodeToString)}
+			// a mnemonic generated without prompting isn't shown anywhere else
+			if generated && assumeYes {
+				o.Mnemonic = w.Mnemonic()
+			}
+			cobra.CheckErr(printStructured(o))
+			return
+		}
+		if generated && assumeYes {
+			fmt.Printf("Mnemonic: %s\n", w.Mnemonic())
+		}
+		for _, a := range w.Secrets.Accounts {
+			fmt.Printf("Account %s: %s\n", a.Path.String(), wallet.PubkeyToAddress(a.Public, hrp))
+		}
 		fmt.Printf("Wallet saved to %s. BACK UP THIS FILE NOW!\n", walletFn)
 	},
 }
 
+// writeNewWallet encrypts the wallet with wk and writes it to a new file at walletFn. It fails rather
+// than overwrite an existing wallet, and removes the file if writing it fails, so that no partially
+// written wallet is left behind.
+func writeNewWallet(walletFn string, wk *wallet.WalletKey, w *wallet.Wallet) error {
+	f, err := os.OpenFile(walletFn, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("wallet file %s already exists", walletFn)
+	}
+	if err != nil {
+		return err
+	}
+	err = wk.Export(f, w)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(walletFn)
+	}
+	return err
+}
+
+// createOutput is the structured output of the create command.
+type createOutput struct {
+	File          string `json:"file" yaml:"file"`
+	*walletOutput `yaml:",inline"`
+}
+
+func (o *createOutput) Header() []string {
+	return append([]string{"file"}, o.walletOutput.Header()...)
+}
+
+func (o *createOutput) Rows() [][]string {
+	rows := o.walletOutput.Rows()
+	for i := range rows {
+		rows[i] = append([]string{o.File}, rows[i]...)
+	}
+	return rows
+}
+
 // readCmd reads an existing wallet file.
 var readCmd = &cobra.Command{
 	Use:   "read [wallet file] [--full/-f] [--private/-p] [--base58]",
@@ -148,26 +393,33 @@ successfully read and decrypted, whether the password to open the file is correc
 It prints the accounts from the wallet file. By default it does not print private keys.
 Add --private to print private keys. Add --full to print full keys. Add --base58 to print
 keys in base58 format rather than hexadecimal. Add --parent to print parent key (and not
-only child keys).`,
+only child keys). Accounts in different BIP-44 accounts or chains are grouped.
+
+Add --output json, yaml or csv to print the wallet in a structured format meant to be consumed
+by other tools. Keys are never abbreviated in structured output.`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		walletFn := args[0]
-
-		// make sure the file exists
-		f, err := os.Open(walletFn)
+		w, err := openWallet(args[0])
 		cobra.CheckErr(err)
-		defer f.Close()
 
-		// get the password
-		fmt.Print("Enter wallet password: ")
-		password, err := password.Read(os.Stdin)
-		fmt.Println()
-		cobra.CheckErr(err)
+		// set the encoder
+		encoder := hex.EncodeToString
+		if printBase58 {
+			encoder = base58.Encode
+		}
 
-		// attempt to read it
-		wk := wallet.NewKey(wallet.WithPasswordOnly([]byte(password)))
-		w, err := wk.Open(f, debug)
-		cobra.CheckErr(err)
+		if structured() {
+			cobra.CheckErr(printStructured(newWalletOutput(w, encoder)))
+			return
+		}
+
+		if w.Meta.HasPassphrase {
+			fmt.Println("Note: this wallet was created with a BIP-39 passphrase. Restoring it from the mnemonic " +
+				"will require the same passphrase.")
+		}
+		if w.Meta.LedgerFingerprint != "" {
+			fmt.Printf("Ledger device fingerprint: %s\n", w.Meta.LedgerFingerprint)
+		}
 
 		widthEnforcer := func(col string, maxLen int) string {
 			if len(col) <= maxLen {
@@ -224,12 +476,6 @@ only child keys).`,
 			})
 		}
 
-		// set the encoder
-		encoder := hex.EncodeToString
-		if printBase58 {
-			encoder = base58.Encode
-		}
-
 		privKeyEncoder := func(privKey []byte) string {
 			if len(privKey) == 0 {
 				return "(none)"
@@ -262,8 +508,14 @@ only child keys).`,
 			}
 		}
 
-		// print child accounts
-		for _, a := range w.Secrets.Accounts {
+		// print child accounts, grouped by BIP-44 account and chain if there's more than one
+		groups := accountGroups(w)
+		for i, a := range w.Secrets.Accounts {
+			if len(groups) > 1 && (i == 0 || groups[i] != groups[i-1]) {
+				t.AppendSeparator()
+				t.AppendRow(table.Row{groups[i]})
+				t.AppendSeparator()
+			}
 			if printPrivate {
 				t.AppendRow(table.Row{
 					wallet.PubkeyToAddress(a.Public, hrp),
@@ -287,10 +539,408 @@ only child keys).`,
 	},
 }
 
+// accountGroups returns the BIP-44 account and chain of each account in w, e.g. "account 0', chain
+// 1'", or an empty slice if all accounts are in the same chain.
+func accountGroups(w *wallet.Wallet) []string {
+	groups := make([]string, len(w.Secrets.Accounts))
+	distinct := 0
+	for i, a := range w.Secrets.Accounts {
+		if len(a.Path) > wallet.HDChainSegment {
+			groups[i] = fmt.Sprintf("account %d', chain %d'",
+				a.Path.Account()&^wallet.BIP32HardenedKeyStart, a.Path.Chain()&^wallet.BIP32HardenedKeyStart)
+		}
+		if i == 0 || groups[i] != groups[i-1] {
+			distinct++
+		}
+	}
+	if distinct <= 1 {
+		return nil
+	}
+	return groups
+}
+
+// walletOutput is the structured output of the read command.
+type walletOutput struct {
+	DisplayName   string          `json:"displayName" yaml:"displayName"`
+	Created       string          `json:"created" yaml:"created"`
+	GenesisID     string          `json:"genesisID" yaml:"genesisID"`
+	HasPassphrase bool            `json:"hasPassphrase" yaml:"hasPassphrase"`
+	Ledger        string          `json:"ledgerFingerprint,omitempty" yaml:"ledgerFingerprint,omitempty"`
+	Mnemonic      string          `json:"mnemonic,omitempty" yaml:"mnemonic,omitempty"`
+	Parent        *accountOutput  `json:"parent,omitempty" yaml:"parent,omitempty"`
+	Accounts      []accountOutput `json:"accounts" yaml:"accounts"`
+}
+
+// accountOutput is the structured output of a wallet account.
+type accountOutput struct {
+	Address    string `json:"address,omitempty" yaml:"address,omitempty"`
+	PublicKey  string `json:"publicKey" yaml:"publicKey"`
+	PrivateKey string `json:"privateKey,omitempty" yaml:"privateKey,omitempty"`
+	Path       string `json:"path" yaml:"path"`
+	Name       string `json:"name" yaml:"name"`
+	Created    string `json:"created" yaml:"created"`
+	KeyType    string `json:"keyType" yaml:"keyType"`
+	HDAccount  uint32 `json:"hdAccount" yaml:"hdAccount"`
+	HDChain    uint32 `json:"hdChain" yaml:"hdChain"`
+}
+
+// newWalletOutput returns the structured output of the wallet. Private keys, the mnemonic and the
+// parent key are only included if requested by flags.
+func newWalletOutput(w *wallet.Wallet, encoder func([]byte) string) *walletOutput {
+	account := func(kp *wallet.EDKeyPair) accountOutput {
+		a := accountOutput{
+			PublicKey: encoder(kp.Public),
+			Path:      kp.Path.String(),
+			Name:      kp.DisplayName,
+			Created:   kp.Created,
+			KeyType:   kp.KeyType.String(),
+		}
+		if len(kp.Path) > wallet.HDChainSegment {
+			a.HDAccount = kp.Path.Account() &^ wallet.BIP32HardenedKeyStart
+			a.HDChain = kp.Path.Chain() &^ wallet.BIP32HardenedKeyStart
+		}
+		if printPrivate && len(kp.Private) > 0 {
+			a.PrivateKey = encoder(kp.Private)
+		}
+		return a
+	}
+	o := &walletOutput{
+		DisplayName:   w.Meta.DisplayName,
+		Created:       w.Meta.Created,
+		GenesisID:     w.Meta.GenesisID,
+		HasPassphrase: w.Meta.HasPassphrase,
+		Ledger:        w.Meta.LedgerFingerprint,
+		Accounts:      make([]accountOutput, 0, len(w.Secrets.Accounts)),
+	}
+	if printPrivate {
+		o.Mnemonic = w.Mnemonic()
+	}
+	if printParent && w.Secrets.MasterKeypair != nil {
+		parent := account(w.Secrets.MasterKeypair)
+		o.Parent = &parent
+	}
+	for _, kp := range w.Secrets.Accounts {
+		a := account(kp)
+		a.Address = wallet.PubkeyToAddress(kp.Public, hrp)
+		o.Accounts = append(o.Accounts, a)
+	}
+	return o
+}
+
+func (o *walletOutput) Header() []string {
+	return []string{"address", "pubkey", "privkey", "path", "name", "created", "key_type", "hd_account", "hd_chain"}
+}
+
+func (o *walletOutput) Rows() [][]string {
+	row := func(a accountOutput) []string {
+		return []string{
+			a.Address, a.PublicKey, a.PrivateKey, a.Path, a.Name, a.Created, a.KeyType,
+			strconv.FormatUint(uint64(a.HDAccount), 10), strconv.FormatUint(uint64(a.HDChain), 10),
+		}
+	}
+	rows := make([][]string, 0, len(o.Accounts)+1)
+	if o.Parent != nil {
+		rows = append(rows, row(*o.Parent))
+	}
+	for _, a := range o.Accounts {
+		rows = append(rows, row(a))
+	}
+	return rows
+}
+
+// passwdCmd changes the password of a wallet file.
+var passwdCmd = &cobra.Command{
+	Use:   "passwd [wallet file] [--kdf name] [--keep-backup]",
+	Short: "Change the password of a wallet file",
+	Long: `Decrypt the wallet file with the current password and re-encrypt it with a new password and a
+new random salt. Account names and other metadata are preserved.
+
+The wallet keeps using the same KDF and cost parameters unless --kdf or one of the cost flags is
+set, so passwd can also be used to switch an existing wallet to a memory-hard KDF.
+
+The original file is backed up next to the wallet file before it's replaced. The backup is removed
+once the new file has been verified by opening it with the new password, unless --keep-backup is
+set. If verification fails, the original file is restored.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		w, creds, err := openWalletWithPassword(args[0])
+		cobra.CheckErr(err)
+		kdf, err := kdfFromFlags(cmd, creds.kdf)
+		cobra.CheckErr(err)
+		newPassword, err := readNewPassword()
+		cobra.CheckErr(err)
+		backup, err := replaceWallet(args[0], w, walletCredentials{password: newPassword, kdf: kdf})
+		cobra.CheckErr(err)
+		// passwd has no structured result, so with --output its messages don't go to stdout either
+		prompt("Password changed. Wallet saved to %s\n", args[0])
+		if backup != "" {
+			prompt("The original wallet file was kept at %s. It can still be opened with the old password.\n",
+				backup)
+		}
+	},
+}
+
+// readNewPassword prompts for a new wallet password and asks for it a second time to make sure it
+// was typed correctly.
+func readNewPassword() ([]byte, error) {
+	prompt("Enter a new password used to encrypt the wallet file (optional but strongly recommended): ")
+	pw, err := password.Read(os.Stdin)
+	prompt("\n")
+	if err != nil {
+		return nil, err
+	}
+	prompt("Confirm new password: ")
+	confirm, err := password.Read(os.Stdin)
+	prompt("\n")
+	if err != nil {
+		return nil, err
+	}
+	if pw != confirm {
+		return nil, errors.New("passwords do not match")
+	}
+	if pw == "" {
+		prompt("Warning: the wallet file will not be protected by a password.\n")
+	}
+	return []byte(pw), nil
+}
+
+// migrateCmd upgrades a wallet file to the current file format.
+var migrateCmd = &cobra.Command{
+	Use:   "migrate [wallet file] [--keep-backup]",
+	Short: "Upgrade a wallet file to the current file format",
+	Long: fmt.Sprintf(`Re-encrypt a wallet file written by an older version of smcli in the current wallet file
+format (version %d). The password, KDF, accounts and other metadata are preserved.
+
+The original file is backed up next to the wallet file before it's replaced. The backup is removed
+once the new file has been verified, unless --keep-backup is set. If verification fails, the
+original file is restored.`, wallet.WalletFileVersion),
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		f, err := os.Open(args[0])
+		cobra.CheckErr(err)
+		version, err := wallet.ReadFileVersion(f)
+		f.Close()
+		cobra.CheckErr(err)
+		if version == wallet.WalletFileVersion {
+			fmt.Printf("Wallet file %s is already at version %d\n", args[0], version)
+			return
+		}
+
+		w, creds, err := openWalletWithPassword(args[0])
+		cobra.CheckErr(err)
+		backup, err := replaceWallet(args[0], w, creds)
+		cobra.CheckErr(err)
+		fmt.Printf("Wallet file %s migrated from version %d to version %d\n",
+			args[0], version, wallet.WalletFileVersion)
+		if backup != "" {
+			fmt.Printf("The original wallet file was kept at %s\n", backup)
+		}
+	},
+}
+
+// replaceWallet saves w to the existing wallet file at walletFn, encrypted with creds. The
+// original file is copied to a backup first, and the new file is verified by decrypting it again.
+// If verification fails, the original file is restored. The backup is removed on success unless
+// --keep-backup is set, in which case its name is returned.
+func replaceWallet(walletFn string, w *wallet.Wallet, creds walletCredentials) (string, error) {
+	original, err := os.ReadFile(walletFn)
+	if err != nil {
+		return "", err
+	}
+	backup := walletFn + ".bak-" + common.NowTimeString()
+	if err := os.WriteFile(backup, original, 0o600); err != nil {
+		return "", fmt.Errorf("backing up wallet file: %w", err)
+	}
+
+	if err := saveWallet(walletFn, w, creds); err != nil {
+		return "", errors.Join(err, restoreWallet(backup, walletFn))
+	}
+	if err := verifyWallet(walletFn, w, creds.password); err != nil {
+		err = fmt.Errorf("verifying new wallet file: %w", err)
+		return "", errors.Join(err, restoreWallet(backup, walletFn))
+	}
+	if keepBackup {
+		return backup, nil
+	}
+	return "", os.Remove(backup)
+}
+
+// restoreWallet moves the backup back over the wallet file.
+func restoreWallet(backup, walletFn string) error {
+	if err := os.Rename(backup, walletFn); err != nil {
+		return fmt.Errorf("restoring original wallet file from %s: %w", backup, err)
+	}
+	return nil
+}
+
+// verifyWallet checks that the wallet file at walletFn can be opened with password and contains w.
+func verifyWallet(walletFn string, w *wallet.Wallet, password []byte) error {
+	f, err := os.Open(walletFn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	wk, err := wallet.NewKey(wallet.WithPasswordOnly(password))
+	if err != nil {
+		return err
+	}
+	saved, err := wk.Open(f, false)
+	if err != nil {
+		return err
+	}
+	// compare the serialized wallets, since decoding doesn't restore nil slices exactly
+	want, err := json.Marshal(w)
+	if err != nil {
+		return err
+	}
+	got, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got, want) {
+		return errors.New("wallet contents do not match")
+	}
+	return nil
+}
+
+// openWallet prompts for the password and decrypts the wallet file at walletFn.
+func openWallet(walletFn string) (*wallet.Wallet, error) {
+	w, _, err := openWalletWithPassword(walletFn)
+	return w, err
+}
+
+// openWalletWithPassword is like openWallet, but also returns the password and KDF so that the
+// wallet can be saved again using saveWallet.
+func openWalletWithPassword(walletFn string) (*wallet.Wallet, walletCredentials, error) {
+	// make sure the file exists
+	f, err := os.Open(walletFn)
+	if err != nil {
+		return nil, walletCredentials{}, err
+	}
+	defer f.Close()
+
+	// get the password
+	prompt("Enter wallet password: ")
+	password, err := password.Read(os.Stdin)
+	prompt("\n")
+	if err != nil {
+		return nil, walletCredentials{}, err
+	}
+
+	// attempt to read it
+	wk, err := wallet.NewKey(wallet.WithPasswordOnly([]byte(password)))
+	if err != nil {
+		return nil, walletCredentials{}, err
+	}
+	w, err := wk.Open(f, debug)
+	if err != nil {
+		return nil, walletCredentials{}, err
+	}
+	return w, walletCredentials{password: []byte(password), kdf: wk.KDF()}, nil
+}
+
+// saveWallet encrypts the wallet with creds, using a new salt, and atomically replaces the wallet
+// file at walletFn.
+func saveWallet(walletFn string, w *wallet.Wallet, creds walletCredentials) error {
+	wk, err := wallet.NewKey(wallet.WithRandomSalt(), wallet.WithKDFPassword(creds.kdf, creds.password))
+	if err != nil {
+		return err
+	}
+	return wk.ExportFile(walletFn, w)
+}
+
+// kdfFromFlags returns the KDF selected using --kdf, with any cost parameters set by flags. def is
+// used if --kdf isn't set.
+func kdfFromFlags(cmd *cobra.Command, def wallet.KDF) (wallet.KDF, error) {
+	kdf := def
+	if cmd.Flags().Changed("kdf") {
+		var err error
+		if kdf, err = wallet.ParseKDF(kdfName); err != nil {
+			return nil, err
+		}
+	}
+	changed := cmd.Flags().Changed
+	switch k := kdf.(type) {
+	case *wallet.PBKDF2:
+		if changed("pbkdf2-iterations") {
+			k.Iterations = pbkdf2Iterations
+		}
+	case *wallet.Argon2id:
+		if changed("argon2-time") {
+			k.Time = argon2Time
+		}
+		if changed("argon2-memory") {
+			if argon2Memory > math.MaxUint32/1024 {
+				return nil, errors.New("invalid Argon2id memory")
+			}
+			k.Memory = argon2Memory * 1024
+		}
+		if changed("argon2-threads") {
+			k.Threads = argon2Threads
+		}
+	case *wallet.Scrypt:
+		if changed("scrypt-n") {
+			k.N = scryptN
+		}
+		if changed("scrypt-r") {
+			k.R = scryptR
+		}
+		if changed("scrypt-p") {
+			k.P = scryptP
+		}
+	}
+	// check the parameters before asking for a password
+	if err := kdf.Validate(); err != nil {
+		return nil, err
+	}
+	return kdf, nil
+}
+
+// addKDFFlags adds the flags used to choose the KDF and its cost parameters to c.
+func addKDFFlags(c *cobra.Command) {
+	c.Flags().StringVar(&kdfName, "kdf", "pbkdf2", "Key derivation function: pbkdf2, argon2id or scrypt")
+	c.Flags().IntVar(&pbkdf2Iterations, "pbkdf2-iterations", wallet.Pbkdf2Iterations, "Number of PBKDF2 iterations")
+	c.Flags().Uint32Var(&argon2Time, "argon2-time", wallet.Argon2idTime, "Number of Argon2id passes")
+	c.Flags().Uint32Var(&argon2Memory, "argon2-memory", wallet.Argon2idMemory/1024, "Argon2id memory, in MiB")
+	c.Flags().Uint8Var(&argon2Threads, "argon2-threads", wallet.Argon2idThreads, "Argon2id parallelism")
+	c.Flags().IntVar(&scryptN, "scrypt-n", wallet.ScryptN, "scrypt CPU/memory cost (a power of 2)")
+	c.Flags().IntVar(&scryptR, "scrypt-r", wallet.ScryptR, "scrypt block size")
+	c.Flags().IntVar(&scryptP, "scrypt-p", wallet.ScryptP, "scrypt parallelism")
+}
+
+// readPassphrase prompts for an optional BIP-39 passphrase and asks for it a second time to
+// make sure it was typed correctly. An empty string means no passphrase.
+func readPassphrase() (string, error) {
+	prompt("Enter an optional BIP-39 passphrase (leave blank for none): ")
+	passphrase, err := password.Read(os.Stdin)
+	prompt("\n")
+	if err != nil || passphrase == "" {
+		return "", err
+	}
+	prompt("Confirm BIP-39 passphrase: ")
+	confirm, err := password.Read(os.Stdin)
+	prompt("\n")
+	if err != nil {
+		return "", err
+	}
+	if passphrase != confirm {
+		return "", errors.New("passphrases do not match")
+	}
+	prompt("Note: the passphrase is NOT stored in the wallet file. Without it, the mnemonic alone " +
+		"cannot restore this wallet.\n")
+	return passphrase, nil
+}
+
 func init() {
 	rootCmd.AddCommand(walletCmd)
 	walletCmd.AddCommand(createCmd)
 	walletCmd.AddCommand(readCmd)
+	walletCmd.AddCommand(passwdCmd)
+	walletCmd.AddCommand(migrateCmd)
+	migrateCmd.Flags().BoolVar(&keepBackup, "keep-backup", false, "Keep a backup of the original wallet file")
+	passwdCmd.Flags().BoolVar(&keepBackup, "keep-backup", false, "Keep a backup of the original wallet file")
+	addKDFFlags(createCmd)
+	addKDFFlags(passwdCmd)
 	readCmd.Flags().BoolVarP(&printPrivate, "private", "p", false, "Print private keys")
 	readCmd.Flags().BoolVarP(&printFull, "full", "f", false, "Print full keys (no abbreviation)")
 	readCmd.Flags().BoolVar(&printBase58, "base58", false, "Print keys in base58 (rather than hex)")
@@ -298,4 +948,32 @@ func init() {
 	readCmd.Flags().StringVar(&hrp, "hrp", types.NetworkHRP(), "Set human-readable address prefix")
 	readCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "enable debug mode")
 	createCmd.Flags().BoolVarP(&useLedger, "ledger", "l", false, "Create a wallet using a Ledger device")
+	addLedgerFlags(createCmd)
+	addPathFlags(createCmd)
+	createCmd.Flags().StringVar(&mnemonicFrom, "mnemonic-from", "",
+		"Read the mnemonic from file:<path>, fd:<n> or env:<name> instead of the terminal")
+	createCmd.Flags().StringVar(&passphraseFrom, "passphrase-from", "",
+		"Read the BIP-39 passphrase from file:<path>, fd:<n> or env:<name> instead of the terminal")
+	createCmd.Flags().StringVar(&passwordFrom, "password-from", "",
+		"Read the wallet password from file:<path>, fd:<n> or env:<name> instead of the terminal")
+	createCmd.Flags().IntVar(&mnemonicWords, "words", wallet.DefaultMnemonicWords,
+		"Number of words of a new mnemonic: 12, 15, 18, 21 or 24")
+	createCmd.Flags().StringVar(&mnemonicLanguage, "language", wallet.DefaultMnemonicLanguage,
+		"Wordlist language of a new mnemonic: "+strings.Join(wallet.MnemonicLanguages(), ", "))
+	createCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false,
+		"Don't ask for confirmation; generate a new mnemonic unless --mnemonic-from is set")
+	createCmd.Flags().StringVar(&entropyKind, "entropy", "",
+		"Generate the mnemonic from your own entropy: dice, coins or hex")
+	createCmd.Flags().StringVar(&entropyFrom, "entropy-from", "",
+		"Read the entropy for --entropy from file:<path>, fd:<n> or env:<name> instead of the terminal")
+	createCmd.Flags().BoolVar(&mixRandom, "mix-random", false,
+		"XOR the entropy for --entropy with random bytes from the operating system")
+	createCmd.Flags().BoolVar(&fromShares, "from-shares", false, "Restore the mnemonic from SLIP-39 shares")
+	createCmd.Flags().StringVar(&sharesFrom, "shares-from", "",
+		"Read SLIP-39 shares to restore the mnemonic from, one per line, from file:<path>, fd:<n> or env:<name>")
+	createCmd.Flags().BoolVar(&skipVerify, "skip-verify", false,
+		"Don't ask to re-enter words of a new mnemonic to verify the backup")
+	createCmd.Flags().StringVar(&createOut, "out", "",
+		"Write the wallet file to this path (default: timestamped file in ~/.spacemesh)")
+	createCmd.Flags().StringVar(&hrp, "hrp", types.NetworkHRP(), "Set human-readable address prefix")
 }
@@ -0,0 +1,61 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/spacemeshos/economics/constants"
+)
+
+// ParseAmount parses an amount of SMH or smidge and returns it in smidge. The unit is given as a
+// case-insensitive suffix, e.g. "1.5SMH", "1.5 smh" or "1500000000smidge". A number without a unit
+// is interpreted as SMH. SMH amounts may have up to nine decimal places; smidge amounts must be
+// whole numbers.
+func ParseAmount(s string) (uint64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	unit := big.NewRat(constants.OneSmesh, 1)
+	switch {
+	case strings.HasSuffix(s, "smidge"):
+		s = strings.TrimSuffix(s, "smidge")
+		unit = big.NewRat(1, 1)
+	case strings.HasSuffix(s, "smh"):
+		s = strings.TrimSuffix(s, "smh")
+	}
+	s = strings.TrimSpace(s)
+	if s == "" || strings.ContainsAny(s, "+-eE/") {
+		return 0, errors.New("invalid amount: must be a non-negative number, optionally followed by SMH or smidge")
+	}
+	amount, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("invalid amount: %s", s)
+	}
+	amount.Mul(amount, unit)
+	if !amount.IsInt() {
+		return 0, errors.New("invalid amount: must be a whole number of smidge")
+	}
+	if !amount.Num().IsUint64() {
+		return 0, errors.New("invalid amount: too large")
+	}
+	return amount.Num().Uint64(), nil
+}
+
+// ParseAmountWithUnit is like ParseAmount, but requires the unit, so that an amount to transfer
+// can't be misread by a factor of a billion.
+func ParseAmountWithUnit(s string) (uint64, error) {
+	unit := strings.ToLower(strings.TrimSpace(s))
+	if !strings.HasSuffix(unit, "smh") && !strings.HasSuffix(unit, "smidge") {
+		return 0, errors.New("invalid amount: must be followed by a unit, e.g. 1.5SMH or 1500000000smidge")
+	}
+	return ParseAmount(s)
+}
+
+// FormatAmount formats an amount of smidge as SMH.
+func FormatAmount(smidge uint64) string {
+	whole, frac := smidge/constants.OneSmesh, smidge%constants.OneSmesh
+	if frac == 0 {
+		return fmt.Sprintf("%d SMH", whole)
+	}
+	return strings.TrimRight(fmt.Sprintf("%d.%09d", whole, frac), "0") + " SMH"
+}
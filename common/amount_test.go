@@ -0,0 +1,63 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAmount(t *testing.T) {
+	testVectors := []struct {
+		input    string
+		expected uint64
+	}{
+		{"0", 0},
+		{"1", 1000000000},
+		{"1SMH", 1000000000},
+		{"1 smh", 1000000000},
+		{" 2.5 SMH ", 2500000000},
+		{"0.000000001", 1},
+		{"1smidge", 1},
+		{"1500000000 Smidge", 1500000000},
+		{"18446744073709551615smidge", 18446744073709551615},
+	}
+	for _, tv := range testVectors {
+		t.Run(tv.input, func(t *testing.T) {
+			amount, err := ParseAmount(tv.input)
+			require.NoError(t, err)
+			require.Equal(t, tv.expected, amount)
+		})
+	}
+
+	for _, input := range []string{
+		"", "smh", "-1", "+1", "1e9", "1/2", "abc", "1.5smidge", "0.0000000001", "18446744073709551616smidge",
+	} {
+		t.Run(input, func(t *testing.T) {
+			_, err := ParseAmount(input)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestParseAmountWithUnit(t *testing.T) {
+	amount, err := ParseAmountWithUnit("1.5 SMH")
+	require.NoError(t, err)
+	require.Equal(t, uint64(1500000000), amount)
+	amount, err = ParseAmountWithUnit("15smidge")
+	require.NoError(t, err)
+	require.Equal(t, uint64(15), amount)
+
+	for _, input := range []string{"", "1", "1.5", "smh", "1.5smidge"} {
+		t.Run(input, func(t *testing.T) {
+			_, err := ParseAmountWithUnit(input)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	require.Equal(t, "0 SMH", FormatAmount(0))
+	require.Equal(t, "1 SMH", FormatAmount(1000000000))
+	require.Equal(t, "2.5 SMH", FormatAmount(2500000000))
+	require.Equal(t, "0.000000001 SMH", FormatAmount(1))
+}
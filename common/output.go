@@ -0,0 +1,80 @@
+package common
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OutputFormat is a format in which commands print their results.
+type OutputFormat string
+
+// Supported output formats. OutputText is the human-readable output of each command; the others
+// are structured formats meant to be consumed by other tools.
+const (
+	OutputText OutputFormat = "text"
+	OutputJSON OutputFormat = "json"
+	OutputYAML OutputFormat = "yaml"
+	OutputCSV  OutputFormat = "csv"
+)
+
+// OutputFormats lists the supported output formats.
+var OutputFormats = []OutputFormat{OutputText, OutputJSON, OutputYAML, OutputCSV}
+
+// ParseOutputFormat parses the name of an output format.
+func ParseOutputFormat(s string) (OutputFormat, error) {
+	for _, f := range OutputFormats {
+		if strings.EqualFold(s, string(f)) {
+			return f, nil
+		}
+	}
+	names := make([]string, 0, len(OutputFormats))
+	for _, f := range OutputFormats {
+		names = append(names, string(f))
+	}
+	return "", fmt.Errorf("unknown output format %q, must be one of: %s", s, strings.Join(names, ", "))
+}
+
+// Table is implemented by results that can be written as CSV. Every row must have as many columns
+// as the header.
+type Table interface {
+	Header() []string
+	Rows() [][]string
+}
+
+// WriteOutput writes v to w in a structured format. The yaml tags of v's fields must name them as
+// their json tags do so that both formats share the same schema; CSV requires v to implement Table.
+func WriteOutput(w io.Writer, format OutputFormat, v any) error {
+	switch format {
+	case OutputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case OutputYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	case OutputCSV:
+		t, ok := v.(Table)
+		if !ok {
+			return fmt.Errorf("output format %s is not supported by this command", format)
+		}
+		cw := csv.NewWriter(w)
+		if err := cw.Write(t.Header()); err != nil {
+			return err
+		}
+		if err := cw.WriteAll(t.Rows()); err != nil {
+			return err
+		}
+		return cw.Error()
+	default:
+		return fmt.Errorf("output format %s is not a structured format", format)
+	}
+}
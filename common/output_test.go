@@ -0,0 +1,67 @@
+package common
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testResult struct {
+	Name   string `json:"name" yaml:"name"`
+	Amount uint64 `json:"amount,omitempty" yaml:"amount,omitempty"`
+}
+
+type testTable []testResult
+
+func (t testTable) Header() []string {
+	return []string{"name", "amount"}
+}
+
+func (t testTable) Rows() [][]string {
+	rows := make([][]string, 0, len(t))
+	for _, r := range t {
+		rows = append(rows, []string{r.Name, FormatAmount(r.Amount)})
+	}
+	return rows
+}
+
+func TestParseOutputFormat(t *testing.T) {
+	for _, f := range OutputFormats {
+		parsed, err := ParseOutputFormat(string(f))
+		require.NoError(t, err)
+		require.Equal(t, f, parsed)
+	}
+	parsed, err := ParseOutputFormat("JSON")
+	require.NoError(t, err)
+	require.Equal(t, OutputJSON, parsed)
+
+	_, err = ParseOutputFormat("xml")
+	require.Error(t, err)
+}
+
+func TestWriteOutput(t *testing.T) {
+	v := testTable{{Name: "a", Amount: 1}, {Name: "b, c"}}
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteOutput(buf, OutputJSON, v))
+	require.JSONEq(t, `[{"name": "a", "amount": 1}, {"name": "b, c"}]`, buf.String())
+
+	// YAML uses the same field names as JSON, in the order of the fields
+	buf.Reset()
+	require.NoError(t, WriteOutput(buf, OutputYAML, v))
+	require.Equal(t, "- name: a\n  amount: 1\n- name: b, c\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, WriteOutput(buf, OutputYAML, testResult{Name: "max", Amount: math.MaxUint64}))
+	require.Equal(t, "name: max\namount: 18446744073709551615\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, WriteOutput(buf, OutputCSV, v))
+	require.Equal(t, "name,amount\na,0.000000001 SMH\n\"b, c\",0 SMH\n", buf.String())
+
+	// CSV requires a table
+	require.Error(t, WriteOutput(buf, OutputCSV, testResult{Name: "a"}))
+	require.Error(t, WriteOutput(buf, OutputText, v))
+}
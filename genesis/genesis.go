@@ -0,0 +1,59 @@
+// Package genesis computes the vesting and vault accounts allocated in the genesis ledger.
+package genesis
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/genvm/core"
+	"github.com/spacemeshos/go-spacemesh/genvm/templates/multisig"
+	"github.com/spacemeshos/go-spacemesh/genvm/templates/vault"
+	"github.com/spacemeshos/go-spacemesh/genvm/templates/vesting"
+)
+
+// VestingArgs returns the spawn arguments of the vesting (multisig) account owned by keys that
+// requires the given number of signatures.
+func VestingArgs(keys []core.PublicKey, required uint8) (*multisig.SpawnArguments, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("must provide at least one key")
+	}
+	if required == 0 {
+		return nil, errors.New("must require at least one signature")
+	}
+	if int(required) > len(keys) {
+		return nil, fmt.Errorf("requires more signatures (%d) than public keys (%d) in the wallet", required, len(keys))
+	}
+	return &multisig.SpawnArguments{
+		Required:   required,
+		PublicKeys: keys,
+	}, nil
+}
+
+// VestingAddress returns the address of the vesting account with the given spawn arguments.
+func VestingAddress(args *multisig.SpawnArguments) types.Address {
+	return core.ComputePrincipal(vesting.TemplateAddress, args)
+}
+
+// VaultArgs returns the spawn arguments of the vault owned by owner and holding amount smidge,
+// using the mainnet vesting schedule.
+func VaultArgs(owner types.Address, amount uint64) *vault.SpawnArguments {
+	return MainnetSchedule().VaultArgs(owner, amount)
+}
+
+// VaultAddress returns the address of the vault with the given spawn arguments.
+func VaultAddress(args *vault.SpawnArguments) types.Address {
+	return core.ComputePrincipal(vault.TemplateAddress, args)
+}
+
+// Unlocked returns the amount of smidge that has vested in the vault as of the given layer.
+func Unlocked(args *vault.SpawnArguments, layer types.LayerID) uint64 {
+	v := vault.Vault{
+		Owner:               args.Owner,
+		TotalAmount:         args.TotalAmount,
+		InitialUnlockAmount: args.InitialUnlockAmount,
+		VestingStart:        args.VestingStart,
+		VestingEnd:          args.VestingEnd,
+	}
+	return v.Vested(layer)
+}
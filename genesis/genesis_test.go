@@ -0,0 +1,44 @@
+package genesis
+
+import (
+	"testing"
+
+	"github.com/spacemeshos/economics/constants"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/genvm/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVestingArgs(t *testing.T) {
+	keys := []core.PublicKey{{1}, {2}, {3}}
+	args, err := VestingArgs(keys, 2)
+	require.NoError(t, err)
+	require.Equal(t, uint8(2), args.Required)
+	require.Equal(t, keys, args.PublicKeys)
+
+	_, err = VestingArgs(keys, 4)
+	require.Error(t, err)
+	_, err = VestingArgs(keys, 0)
+	require.Error(t, err)
+	_, err = VestingArgs(nil, 1)
+	require.Error(t, err)
+
+	// the order of the keys matters
+	other, err := VestingArgs([]core.PublicKey{{3}, {2}, {1}}, 2)
+	require.NoError(t, err)
+	require.NotEqual(t, VestingAddress(args), VestingAddress(other))
+}
+
+func TestUnlocked(t *testing.T) {
+	amount := uint64(3 * constants.OneSmesh)
+	args := VaultArgs(types.Address{}, amount)
+	require.Equal(t, types.LayerID(constants.VestStart), args.VestingStart)
+	require.Equal(t, types.LayerID(constants.VestEnd), args.VestingEnd)
+	require.Equal(t, amount/4, args.InitialUnlockAmount)
+
+	require.Zero(t, Unlocked(args, 0))
+	require.Zero(t, Unlocked(args, types.LayerID(constants.VestStart-1)))
+	require.Equal(t, amount/2, Unlocked(args, types.LayerID((constants.VestStart+constants.VestEnd)/2)))
+	require.Equal(t, amount, Unlocked(args, types.LayerID(constants.VestEnd)))
+	require.Equal(t, amount, Unlocked(args, types.LayerID(constants.VestEnd+1)))
+}
@@ -0,0 +1,205 @@
+package genesis
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/genvm/core"
+
+	"github.com/spacemeshos/smcli/common"
+)
+
+// Allocation is a single genesis ledger entry: a vault owned by a vesting account, together with
+// the addresses the ledger claims they have.
+type Allocation struct {
+	Keys                   []string `json:"keys"`
+	Required               uint8    `json:"required"`
+	Amount                 string   `json:"amount"`
+	ExpectedVestingAddress string   `json:"vestingAddress"`
+	ExpectedVaultAddress   string   `json:"vaultAddress"`
+}
+
+// csvHeader lists the columns of a CSV allocation file. Multiple keys in the keys column are
+// separated by spaces or semicolons.
+var csvHeader = []string{"keys", "required", "amount", "vesting_address", "vault_address"}
+
+// ReadAllocations reads a list of allocations from r. The input is either a JSON array of
+// allocations or a CSV file with the columns keys, required, amount, vesting_address and
+// vault_address; the format is detected from the content.
+func ReadAllocations(r io.Reader) ([]Allocation, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		return readAllocationsJSON(trimmed)
+	}
+	return readAllocationsCSV(data)
+}
+
+func readAllocationsJSON(data []byte) ([]Allocation, error) {
+	var raw []struct {
+		Allocation
+		Amount any `json:"amount"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+	allocs := make([]Allocation, 0, len(raw))
+	for _, a := range raw {
+		switch amount := a.Amount.(type) {
+		case string:
+			a.Allocation.Amount = amount
+		case json.Number:
+			a.Allocation.Amount = amount.String()
+		default:
+			return nil, fmt.Errorf("invalid amount %v", a.Amount)
+		}
+		allocs = append(allocs, a.Allocation)
+	}
+	return allocs, nil
+}
+
+func readAllocationsCSV(data []byte) ([]Allocation, error) {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("empty allocation file")
+	}
+	for i, col := range csvHeader {
+		if i >= len(records[0]) || strings.TrimSpace(strings.ToLower(records[0][i])) != col {
+			return nil, fmt.Errorf("invalid CSV header, expected columns: %s", strings.Join(csvHeader, ","))
+		}
+	}
+	allocs := make([]Allocation, 0, len(records)-1)
+	for i, rec := range records[1:] {
+		required, err := strconv.ParseUint(strings.TrimSpace(rec[1]), 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: invalid number of required signatures: %w", i+1, err)
+		}
+		allocs = append(allocs, Allocation{
+			Keys: strings.FieldsFunc(rec[0], func(r rune) bool {
+				return r == ';' || r == ' ' || r == '\t'
+			}),
+			Required:               uint8(required),
+			Amount:                 strings.TrimSpace(rec[2]),
+			ExpectedVestingAddress: strings.TrimSpace(rec[3]),
+			ExpectedVaultAddress:   strings.TrimSpace(rec[4]),
+		})
+	}
+	return allocs, nil
+}
+
+// AuditResult is the outcome of recomputing a single allocation.
+type AuditResult struct {
+	Row            int    `json:"row" yaml:"row"`
+	Amount         uint64 `json:"amount" yaml:"amount"`
+	VestingAddress string `json:"vestingAddress,omitempty" yaml:"vestingAddress,omitempty"`
+	VaultAddress   string `json:"vaultAddress,omitempty" yaml:"vaultAddress,omitempty"`
+	VestingMatch   bool   `json:"vestingMatch" yaml:"vestingMatch"`
+	VaultMatch     bool   `json:"vaultMatch" yaml:"vaultMatch"`
+	Error          string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// OK reports whether the allocation was valid and both addresses match.
+func (r *AuditResult) OK() bool {
+	return r.Error == "" && r.VestingMatch && r.VaultMatch
+}
+
+// AuditReport summarizes the audit of a whole genesis ledger.
+type AuditReport struct {
+	Results            []AuditResult    `json:"results" yaml:"results"`
+	Mismatches         int              `json:"mismatches" yaml:"mismatches"`
+	TotalAmount        uint64           `json:"totalAmount" yaml:"totalAmount"`
+	DuplicateKeys      map[string][]int `json:"duplicateKeys" yaml:"duplicateKeys"`
+	DuplicateAddresses map[string][]int `json:"duplicateAddresses" yaml:"duplicateAddresses"`
+}
+
+// Audit recomputes the vesting and vault address of every allocation, using the given vesting
+// schedule, and compares them with the expected ones. Rows are numbered starting at 1. Keys and
+// addresses that appear in more than one row are reported along with the rows they appear in.
+func Audit(allocs []Allocation, schedule Schedule) *AuditReport {
+	report := &AuditReport{
+		Results:            make([]AuditResult, 0, len(allocs)),
+		DuplicateKeys:      map[string][]int{},
+		DuplicateAddresses: map[string][]int{},
+	}
+	keyRows := map[string][]int{}
+	addressRows := map[string][]int{}
+	for i, a := range allocs {
+		res := auditAllocation(i+1, a, schedule)
+		if !res.OK() {
+			report.Mismatches++
+		}
+		if res.Error == "" {
+			report.TotalAmount += res.Amount
+			addressRows[res.VestingAddress] = append(addressRows[res.VestingAddress], res.Row)
+			addressRows[res.VaultAddress] = append(addressRows[res.VaultAddress], res.Row)
+		}
+		for _, k := range a.Keys {
+			k = strings.ToLower(k)
+			keyRows[k] = append(keyRows[k], res.Row)
+		}
+		report.Results = append(report.Results, res)
+	}
+	for k, rows := range keyRows {
+		if len(rows) > 1 {
+			report.DuplicateKeys[k] = rows
+		}
+	}
+	for a, rows := range addressRows {
+		if len(rows) > 1 {
+			report.DuplicateAddresses[a] = rows
+		}
+	}
+	return report
+}
+
+func auditAllocation(row int, a Allocation, schedule Schedule) AuditResult {
+	res := AuditResult{Row: row}
+	keys := make([]core.PublicKey, 0, len(a.Keys))
+	for _, k := range a.Keys {
+		b, err := hex.DecodeString(k)
+		if err != nil || len(b) != len(core.PublicKey{}) {
+			res.Error = fmt.Sprintf("key is unreadable: %s", k)
+			return res
+		}
+		keys = append(keys, core.PublicKey(b))
+	}
+	amount, err := common.ParseAmount(a.Amount)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	vestingArgs, err := VestingArgs(keys, a.Required)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	vestingAddress := VestingAddress(vestingArgs)
+	vaultAddress := VaultAddress(schedule.VaultArgs(vestingAddress, amount))
+
+	res.Amount = amount
+	res.VestingAddress = vestingAddress.String()
+	res.VaultAddress = vaultAddress.String()
+	res.VestingMatch = addressMatches(a.ExpectedVestingAddress, vestingAddress)
+	res.VaultMatch = addressMatches(a.ExpectedVaultAddress, vaultAddress)
+	return res
+}
+
+func addressMatches(expected string, actual types.Address) bool {
+	addr, err := types.StringToAddress(expected)
+	return err == nil && addr == actual
+}
@@ -0,0 +1,89 @@
+package genesis
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/genvm/core"
+	"github.com/stretchr/testify/require"
+
+	"github.com/spacemeshos/smcli/common"
+)
+
+func testAllocation(t *testing.T, required uint8, amount string, keys ...core.PublicKey) Allocation {
+	args, err := VestingArgs(keys, required)
+	require.NoError(t, err)
+	smidge, err := common.ParseAmount(amount)
+	require.NoError(t, err)
+	vesting := VestingAddress(args)
+	a := Allocation{
+		Required:               required,
+		Amount:                 amount,
+		ExpectedVestingAddress: vesting.String(),
+		ExpectedVaultAddress:   VaultAddress(VaultArgs(vesting, smidge)).String(),
+	}
+	for _, k := range keys {
+		a.Keys = append(a.Keys, hex.EncodeToString(k[:]))
+	}
+	return a
+}
+
+func TestReadAllocations(t *testing.T) {
+	a1 := testAllocation(t, 1, "100", core.PublicKey{1})
+	a2 := testAllocation(t, 2, "5000000000smidge", core.PublicKey{2}, core.PublicKey{3})
+
+	csvInput := fmt.Sprintf("keys,required,amount,vesting_address,vault_address\n"+
+		"%s,1,100,%s,%s\n%s,2,5000000000smidge,%s,%s\n",
+		a1.Keys[0], a1.ExpectedVestingAddress, a1.ExpectedVaultAddress,
+		strings.Join(a2.Keys, ";"), a2.ExpectedVestingAddress, a2.ExpectedVaultAddress)
+	allocs, err := ReadAllocations(strings.NewReader(csvInput))
+	require.NoError(t, err)
+	require.Equal(t, []Allocation{a1, a2}, allocs)
+
+	jsonInput := fmt.Sprintf(`[
+		{"keys": ["%s"], "required": 1, "amount": 100, "vestingAddress": "%s", "vaultAddress": "%s"},
+		{"keys": ["%s", "%s"], "required": 2, "amount": "5000000000smidge", "vestingAddress": "%s", "vaultAddress": "%s"}
+	]`, a1.Keys[0], a1.ExpectedVestingAddress, a1.ExpectedVaultAddress,
+		a2.Keys[0], a2.Keys[1], a2.ExpectedVestingAddress, a2.ExpectedVaultAddress)
+	allocs, err = ReadAllocations(strings.NewReader(jsonInput))
+	require.NoError(t, err)
+	require.Equal(t, []Allocation{a1, a2}, allocs)
+
+	_, err = ReadAllocations(strings.NewReader("pubkeys,threshold\n"))
+	require.Error(t, err)
+}
+
+func TestAudit(t *testing.T) {
+	good := testAllocation(t, 1, "100", core.PublicKey{1})
+	wrongAmount := testAllocation(t, 1, "100", core.PublicKey{2})
+	wrongAmount.Amount = "101"
+	duplicate := testAllocation(t, 2, "50", core.PublicKey{1}, core.PublicKey{3})
+	bad := Allocation{Keys: []string{"zz"}, Required: 1, Amount: "1"}
+
+	report := Audit([]Allocation{good, wrongAmount, duplicate, bad}, MainnetSchedule())
+	require.Len(t, report.Results, 4)
+	require.True(t, report.Results[0].OK())
+
+	require.True(t, report.Results[1].VestingMatch)
+	require.False(t, report.Results[1].VaultMatch)
+	require.False(t, report.Results[1].OK())
+
+	require.True(t, report.Results[2].OK())
+	require.NotEmpty(t, report.Results[3].Error)
+	require.Equal(t, 2, report.Mismatches)
+
+	smh, err := common.ParseAmount("251")
+	require.NoError(t, err)
+	require.Equal(t, smh, report.TotalAmount)
+	require.Equal(t, map[string][]int{good.Keys[0]: {1, 3}}, report.DuplicateKeys)
+	require.Empty(t, report.DuplicateAddresses)
+
+	report = Audit([]Allocation{good, good}, MainnetSchedule())
+	require.Zero(t, report.Mismatches)
+	require.Equal(t, map[string][]int{
+		good.ExpectedVestingAddress: {1, 2},
+		good.ExpectedVaultAddress:   {1, 2},
+	}, report.DuplicateAddresses)
+}
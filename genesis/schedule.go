@@ -0,0 +1,111 @@
+package genesis
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/spacemeshos/economics/constants"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/genvm/templates/vault"
+)
+
+// MainnetGenesisTime and MainnetLayerDuration are the genesis time and layer duration of mainnet,
+// as in the go-spacemesh mainnet config. They're used to convert layers to dates.
+var (
+	MainnetGenesisTime   = time.Date(2023, time.July, 14, 8, 0, 0, 0, time.UTC)
+	MainnetLayerDuration = 5 * time.Minute
+)
+
+// Schedule is the vesting schedule of a vault.
+type Schedule struct {
+	// VestingStart is the layer at which funds start to unlock.
+	VestingStart types.LayerID
+
+	// VestingEnd is the layer at which all funds have unlocked.
+	VestingEnd types.LayerID
+
+	// InitialUnlock is the fraction of the total amount recorded as the vault's initial unlock
+	// amount.
+	InitialUnlock *big.Rat
+}
+
+// MainnetSchedule returns the vesting schedule of the mainnet genesis vaults.
+func MainnetSchedule() Schedule {
+	return Schedule{
+		VestingStart:  types.LayerID(constants.VestStart),
+		VestingEnd:    types.LayerID(constants.VestEnd),
+		InitialUnlock: big.NewRat(1, 4),
+	}
+}
+
+// Validate checks that the schedule can be used to spawn a vault.
+func (s Schedule) Validate() error {
+	if s.VestingEnd.Before(s.VestingStart) {
+		return fmt.Errorf("vesting end (layer %d) is before vesting start (layer %d)", s.VestingEnd, s.VestingStart)
+	}
+	if s.InitialUnlock == nil || s.InitialUnlock.Sign() < 0 || s.InitialUnlock.Cmp(big.NewRat(1, 1)) > 0 {
+		return errors.New("initial unlock must be between 0 and 1")
+	}
+	return nil
+}
+
+// VaultArgs returns the spawn arguments of the vault owned by owner and holding amount smidge,
+// using this schedule. The initial unlock amount is rounded down.
+func (s Schedule) VaultArgs(owner types.Address, amount uint64) *vault.SpawnArguments {
+	initial := new(big.Int).SetUint64(amount)
+	initial.Mul(initial, s.InitialUnlock.Num())
+	initial.Quo(initial, s.InitialUnlock.Denom())
+	return &vault.SpawnArguments{
+		Owner:               owner,
+		TotalAmount:         amount,
+		InitialUnlockAmount: initial.Uint64(),
+		VestingStart:        s.VestingStart,
+		VestingEnd:          s.VestingEnd,
+	}
+}
+
+// ParseFraction parses a fraction written as a percentage ("25%"), a decimal ("0.25") or a ratio
+// ("1/4").
+func ParseFraction(s string) (*big.Rat, error) {
+	s = strings.TrimSpace(s)
+	r := new(big.Rat)
+	if pct, ok := strings.CutSuffix(s, "%"); ok {
+		if _, ok := r.SetString(strings.TrimSpace(pct)); !ok {
+			return nil, fmt.Errorf("invalid fraction %q", s)
+		}
+		return r.Quo(r, big.NewRat(100, 1)), nil
+	}
+	if _, ok := r.SetString(s); !ok {
+		return nil, fmt.Errorf("invalid fraction %q", s)
+	}
+	return r, nil
+}
+
+// ScheduleEntry is the amount unlocked in a vault as of a layer.
+type ScheduleEntry struct {
+	Layer    types.LayerID `json:"layer" yaml:"layer"`
+	Unlocked uint64        `json:"unlocked" yaml:"unlocked"`
+}
+
+// UnlockSchedule returns the amount unlocked in the vault every step layers from the vesting start
+// until the vesting end. The vesting end is always included.
+func UnlockSchedule(args *vault.SpawnArguments, step uint32) []ScheduleEntry {
+	if step == 0 {
+		step = 1
+	}
+	var entries []ScheduleEntry
+	for l := uint64(args.VestingStart); l < uint64(args.VestingEnd); l += uint64(step) {
+		layer := types.LayerID(l)
+		entries = append(entries, ScheduleEntry{Layer: layer, Unlocked: Unlocked(args, layer)})
+	}
+	return append(entries, ScheduleEntry{Layer: args.VestingEnd, Unlocked: Unlocked(args, args.VestingEnd)})
+}
+
+// LayerTime returns the time at which the layer starts, given the network's genesis time and
+// layer duration.
+func LayerTime(genesisTime time.Time, layerDuration time.Duration, layer types.LayerID) time.Time {
+	return genesisTime.Add(time.Duration(layer) * layerDuration)
+}
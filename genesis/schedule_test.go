@@ -0,0 +1,79 @@
+package genesis
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/spacemeshos/economics/constants"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMainnetSchedule(t *testing.T) {
+	owner := types.GenerateAddress([]byte("owner"))
+	amount := uint64(7*constants.OneSmesh + 3)
+	args := MainnetSchedule().VaultArgs(owner, amount)
+	require.Equal(t, owner, args.Owner)
+	require.Equal(t, amount, args.TotalAmount)
+	require.Equal(t, amount/4, args.InitialUnlockAmount)
+	require.Equal(t, types.LayerID(constants.VestStart), args.VestingStart)
+	require.Equal(t, types.LayerID(constants.VestEnd), args.VestingEnd)
+	require.Equal(t, VaultArgs(owner, amount), args)
+}
+
+func TestScheduleValidate(t *testing.T) {
+	require.NoError(t, MainnetSchedule().Validate())
+
+	s := Schedule{VestingStart: 10, VestingEnd: 10, InitialUnlock: big.NewRat(0, 1)}
+	require.NoError(t, s.Validate())
+	s.InitialUnlock = big.NewRat(1, 1)
+	require.NoError(t, s.Validate())
+
+	s.VestingEnd = 9
+	require.Error(t, s.Validate())
+	s.VestingEnd = 20
+	s.InitialUnlock = big.NewRat(3, 2)
+	require.Error(t, s.Validate())
+	s.InitialUnlock = big.NewRat(-1, 2)
+	require.Error(t, s.Validate())
+	s.InitialUnlock = nil
+	require.Error(t, s.Validate())
+}
+
+func TestParseFraction(t *testing.T) {
+	for _, tc := range []string{"1/4", "0.25", "25%", " 25 % ", ".25"} {
+		r, err := ParseFraction(tc)
+		require.NoError(t, err, tc)
+		require.Zero(t, r.Cmp(big.NewRat(1, 4)), tc)
+	}
+	for _, tc := range []string{"", "abc", "%", "1/0"} {
+		_, err := ParseFraction(tc)
+		require.Error(t, err, tc)
+	}
+}
+
+func TestUnlockSchedule(t *testing.T) {
+	s := Schedule{VestingStart: 10, VestingEnd: 20, InitialUnlock: big.NewRat(1, 10)}
+	args := s.VaultArgs(types.Address{}, 1000)
+	require.Equal(t, uint64(100), args.InitialUnlockAmount)
+
+	require.Equal(t, []ScheduleEntry{
+		{Layer: 10, Unlocked: 0},
+		{Layer: 14, Unlocked: 400},
+		{Layer: 18, Unlocked: 800},
+		{Layer: 20, Unlocked: 1000},
+	}, UnlockSchedule(args, 4))
+	require.Len(t, UnlockSchedule(args, 0), 11)
+
+	// a vault with no vesting period unlocks at once
+	args.VestingEnd = args.VestingStart
+	require.Equal(t, []ScheduleEntry{{Layer: 10, Unlocked: 1000}}, UnlockSchedule(args, 1))
+}
+
+func TestLayerTime(t *testing.T) {
+	require.Equal(t, MainnetGenesisTime, LayerTime(MainnetGenesisTime, MainnetLayerDuration, 0))
+	require.Equal(t,
+		MainnetGenesisTime.Add(365*24*time.Hour),
+		LayerTime(MainnetGenesisTime, MainnetLayerDuration, constants.OneYear))
+}
@@ -0,0 +1,492 @@
+package tx
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/spacemeshos/go-scale"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/genvm/core"
+	"github.com/spacemeshos/go-spacemesh/genvm/sdk"
+	sdkMultisig "github.com/spacemeshos/go-spacemesh/genvm/sdk/multisig"
+	"github.com/spacemeshos/go-spacemesh/genvm/templates/multisig"
+	"github.com/spacemeshos/go-spacemesh/genvm/templates/vault"
+	"github.com/spacemeshos/go-spacemesh/genvm/templates/vesting"
+
+	"github.com/spacemeshos/smcli/wallet"
+)
+
+// PartiallySignedVersion is the current version of the partially signed transaction file format.
+const PartiallySignedVersion = 1
+
+// MaxSigners is the maximum number of public keys of a multisig account, as limited by the template.
+const MaxSigners = 10
+
+// Kinds of transactions that can be partially signed.
+const (
+	KindSpawn      = "spawn"
+	KindSpend      = "spend"
+	KindSpawnVault = "spawn-vault"
+	KindDrainVault = "drain-vault"
+)
+
+// Templates of accounts that can be operated using partially signed transactions.
+const (
+	TemplateMultisig = "multisig"
+	TemplateVesting  = "vesting"
+)
+
+var (
+	// ErrNotASigner is returned when signing with a key that isn't one of the account's public keys.
+	ErrNotASigner = errors.New("key is not one of the signers of this account")
+
+	// ErrInvalidSignature is returned when a signature in a partially signed transaction doesn't verify.
+	ErrInvalidSignature = errors.New("invalid signature")
+
+	// ErrNotEnoughSignatures is returned when finalizing a transaction that is missing signatures.
+	ErrNotEnoughSignatures = errors.New("not enough signatures")
+)
+
+type hexBytes []byte
+
+func (b hexBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hex.EncodeToString(b))
+}
+
+func (b *hexBytes) UnmarshalJSON(data []byte) (err error) {
+	var hexString string
+	if err = json.Unmarshal(data, &hexString); err != nil {
+		return
+	}
+	*b, err = hex.DecodeString(hexString)
+	return
+}
+
+// Signature is a single signer's signature over a partially signed transaction.
+// Ref is the index of the signer's key in the account's list of public keys.
+type Signature struct {
+	Ref       uint8    `json:"ref"`
+	Signature hexBytes `json:"signature"`
+}
+
+// VaultParams are the spawn arguments of a vault owned by a vesting account. The owner isn't
+// included since it's always the vesting account that sends the transaction.
+type VaultParams struct {
+	TotalAmount         uint64 `json:"totalAmount"`
+	InitialUnlockAmount uint64 `json:"initialUnlockAmount"`
+	VestingStart        uint32 `json:"vestingStart"`
+	VestingEnd          uint32 `json:"vestingEnd"`
+}
+
+// PartiallySignedTx is a transaction for a multisig (or vesting) account that collects signatures
+// from several signers before it can be broadcast. It's meant to be passed around as a JSON file,
+// e.g., between air-gapped machines.
+//
+// The file stores the transaction fields rather than the encoded transaction, and the bytes to be
+// signed are always re-encoded from these fields. This way what a signer inspects is exactly what
+// they sign.
+type PartiallySignedTx struct {
+	Version    int          `json:"version"`
+	Kind       string       `json:"kind"`
+	Template   string       `json:"template"`
+	GenesisID  hexBytes     `json:"genesisID"`
+	Required   uint8        `json:"required"`
+	PublicKeys []hexBytes   `json:"publicKeys"`
+	Nonce      uint64       `json:"nonce"`
+	GasPrice   uint64       `json:"gasPrice"`
+	To         string       `json:"to,omitempty"`
+	Amount     uint64       `json:"amount,omitempty"`
+	Vault      *VaultParams `json:"vault,omitempty"`
+	Signatures []Signature  `json:"signatures"`
+}
+
+// NewMultisigSpawn returns an unsigned self-spawn transaction for the multisig or vesting account
+// defined by template, required and pubs.
+func NewMultisigSpawn(
+	template string,
+	required uint8,
+	pubs []core.PublicKey,
+	nonce uint64,
+	opts ...sdk.Opt,
+) (*PartiallySignedTx, error) {
+	p := newPartiallySigned(KindSpawn, template, required, pubs, nonce, opts...)
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// NewMultisigSpend returns an unsigned transaction that sends amount smidge from the multisig or
+// vesting account defined by template, required and pubs to the given address.
+func NewMultisigSpend(
+	template string,
+	required uint8,
+	pubs []core.PublicKey,
+	to types.Address,
+	amount, nonce uint64,
+	opts ...sdk.Opt,
+) (*PartiallySignedTx, error) {
+	p := newPartiallySigned(KindSpend, template, required, pubs, nonce, opts...)
+	p.To = to.String()
+	p.Amount = amount
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// NewVaultSpawn returns an unsigned transaction in which the vesting account defined by required
+// and pubs spawns a vault. The owner of the vault must be the vesting account.
+func NewVaultSpawn(
+	required uint8,
+	pubs []core.PublicKey,
+	args *vault.SpawnArguments,
+	nonce uint64,
+	opts ...sdk.Opt,
+) (*PartiallySignedTx, error) {
+	p := newPartiallySigned(KindSpawnVault, TemplateVesting, required, pubs, nonce, opts...)
+	if err := p.setVault(args); err != nil {
+		return nil, err
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// NewDrainVault returns an unsigned transaction in which the vesting account defined by required and
+// pubs drains amount smidge from its vault to the given address.
+func NewDrainVault(
+	required uint8,
+	pubs []core.PublicKey,
+	args *vault.SpawnArguments,
+	to types.Address,
+	amount, nonce uint64,
+	opts ...sdk.Opt,
+) (*PartiallySignedTx, error) {
+	p := newPartiallySigned(KindDrainVault, TemplateVesting, required, pubs, nonce, opts...)
+	if err := p.setVault(args); err != nil {
+		return nil, err
+	}
+	p.To = to.String()
+	p.Amount = amount
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *PartiallySignedTx) setVault(args *vault.SpawnArguments) error {
+	if args.Owner != p.Principal() {
+		return fmt.Errorf("vault owner %s is not the vesting account %s", args.Owner, p.Principal())
+	}
+	p.Vault = &VaultParams{
+		TotalAmount:         args.TotalAmount,
+		InitialUnlockAmount: args.InitialUnlockAmount,
+		VestingStart:        args.VestingStart.Uint32(),
+		VestingEnd:          args.VestingEnd.Uint32(),
+	}
+	return nil
+}
+
+func newPartiallySigned(
+	kind, template string,
+	required uint8,
+	pubs []core.PublicKey,
+	nonce uint64,
+	opts ...sdk.Opt,
+) *PartiallySignedTx {
+	options := sdk.Defaults()
+	for _, opt := range opts {
+		opt(options)
+	}
+	p := &PartiallySignedTx{
+		Version:    PartiallySignedVersion,
+		Kind:       kind,
+		Template:   template,
+		GenesisID:  options.GenesisID[:],
+		Required:   required,
+		PublicKeys: make([]hexBytes, 0, len(pubs)),
+		Nonce:      nonce,
+		GasPrice:   options.GasPrice,
+		Signatures: []Signature{},
+	}
+	for _, pub := range pubs {
+		p.PublicKeys = append(p.PublicKeys, pub[:])
+	}
+	return p
+}
+
+// ReadPartiallySigned reads and validates a partially signed transaction file.
+func ReadPartiallySigned(r io.Reader) (*PartiallySignedTx, error) {
+	p := &PartiallySignedTx{}
+	if err := json.NewDecoder(r).Decode(p); err != nil {
+		return nil, err
+	}
+	if p.Version != PartiallySignedVersion {
+		return nil, fmt.Errorf("unsupported partially signed transaction version %d", p.Version)
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	if err := p.Verify(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Write writes the partially signed transaction as JSON.
+func (p *PartiallySignedTx) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+func (p *PartiallySignedTx) validate() error {
+	if _, err := templateAddress(p.Template); err != nil {
+		return err
+	}
+	if len(p.GenesisID) != types.Hash20Length {
+		return fmt.Errorf("invalid genesis ID length %d", len(p.GenesisID))
+	}
+	if len(p.PublicKeys) == 0 || len(p.PublicKeys) > MaxSigners {
+		return fmt.Errorf("number of public keys must be between 1 and %d", MaxSigners)
+	}
+	for _, pub := range p.PublicKeys {
+		if len(pub) != ed25519.PublicKeySize {
+			return fmt.Errorf("invalid public key length %d", len(pub))
+		}
+	}
+	if p.Required == 0 || int(p.Required) > len(p.PublicKeys) {
+		return fmt.Errorf("required signatures must be between 1 and the number of public keys (%d)",
+			len(p.PublicKeys))
+	}
+	switch p.Kind {
+	case KindSpawn, KindSpend:
+	case KindSpawnVault, KindDrainVault:
+		if p.Template != TemplateVesting || p.Vault == nil {
+			return fmt.Errorf("%s transactions require a vesting account and vault parameters", p.Kind)
+		}
+	default:
+		return fmt.Errorf("unknown transaction kind %q", p.Kind)
+	}
+	if p.Kind == KindSpend || p.Kind == KindDrainVault {
+		if _, err := types.StringToAddress(p.To); err != nil {
+			return fmt.Errorf("invalid recipient address: %w", err)
+		}
+	}
+	return nil
+}
+
+func templateAddress(template string) (core.Address, error) {
+	switch template {
+	case TemplateMultisig:
+		return multisig.TemplateAddress, nil
+	case TemplateVesting:
+		return vesting.TemplateAddress, nil
+	default:
+		return core.Address{}, fmt.Errorf("unknown template %q", template)
+	}
+}
+
+func (p *PartiallySignedTx) spawnArgs() *multisig.SpawnArguments {
+	args := &multisig.SpawnArguments{
+		Required:   p.Required,
+		PublicKeys: make([]core.PublicKey, len(p.PublicKeys)),
+	}
+	for i, pub := range p.PublicKeys {
+		copy(args.PublicKeys[i][:], pub)
+	}
+	return args
+}
+
+// Principal returns the address of the account the transaction is sent from.
+func (p *PartiallySignedTx) Principal() types.Address {
+	template, _ := templateAddress(p.Template)
+	return core.ComputePrincipal(template, p.spawnArgs())
+}
+
+// VaultArgs returns the spawn arguments of the vault, for vault transactions.
+func (p *PartiallySignedTx) VaultArgs() *vault.SpawnArguments {
+	if p.Vault == nil {
+		return nil
+	}
+	return &vault.SpawnArguments{
+		Owner:               p.Principal(),
+		TotalAmount:         p.Vault.TotalAmount,
+		InitialUnlockAmount: p.Vault.InitialUnlockAmount,
+		VestingStart:        types.LayerID(p.Vault.VestingStart),
+		VestingEnd:          types.LayerID(p.Vault.VestingEnd),
+	}
+}
+
+// VaultAddress returns the address of the vault, for vault transactions.
+func (p *PartiallySignedTx) VaultAddress() types.Address {
+	return core.ComputePrincipal(vault.TemplateAddress, p.VaultArgs())
+}
+
+// Unsigned returns the encoded transaction without signatures.
+func (p *PartiallySignedTx) Unsigned() ([]byte, error) {
+	template, err := templateAddress(p.Template)
+	if err != nil {
+		return nil, err
+	}
+	principal := core.ComputePrincipal(template, p.spawnArgs())
+	payload := core.Payload{Nonce: p.Nonce, GasPrice: p.GasPrice}
+	switch p.Kind {
+	case KindSpawn:
+		return sdk.Encode(&sdk.TxVersion, &principal, &sdk.MethodSpawn, &template, &payload, p.spawnArgs()), nil
+	case KindSpend:
+		to, err := types.StringToAddress(p.To)
+		if err != nil {
+			return nil, err
+		}
+		args := &multisig.SpendArguments{Destination: to, Amount: p.Amount}
+		return sdk.Encode(&sdk.TxVersion, &principal, &sdk.MethodSpend, &payload, args), nil
+	case KindSpawnVault:
+		template := vault.TemplateAddress
+		return sdk.Encode(&sdk.TxVersion, &principal, &sdk.MethodSpawn, &template, &payload, p.VaultArgs()), nil
+	case KindDrainVault:
+		to, err := types.StringToAddress(p.To)
+		if err != nil {
+			return nil, err
+		}
+		args := &vesting.DrainVaultArguments{Vault: p.VaultAddress()}
+		args.Destination = to
+		args.Amount = p.Amount
+		method := scale.U8(vesting.MethodDrainVault)
+		return sdk.Encode(&sdk.TxVersion, &principal, &method, &payload, args), nil
+	default:
+		return nil, fmt.Errorf("unknown transaction kind %q", p.Kind)
+	}
+}
+
+func (p *PartiallySignedTx) signingBody() ([]byte, error) {
+	unsigned, err := p.Unsigned()
+	if err != nil {
+		return nil, err
+	}
+	return core.SigningBody(p.GenesisID, unsigned), nil
+}
+
+// Ref returns the index of the given public key among the account's signers.
+func (p *PartiallySignedTx) Ref(pub wallet.PublicKey) (uint8, error) {
+	for i, k := range p.PublicKeys {
+		if bytes.Equal(k, pub) {
+			return uint8(i), nil
+		}
+	}
+	return 0, ErrNotASigner
+}
+
+// Sign adds the signature of the given signer, replacing any earlier signature by the same key.
+func (p *PartiallySignedTx) Sign(signer wallet.Signer) error {
+	ref, err := p.Ref(signer.PublicKey())
+	if err != nil {
+		return err
+	}
+	unsigned, err := p.Unsigned()
+	if err != nil {
+		return err
+	}
+	var genesisID types.Hash20
+	copy(genesisID[:], p.GenesisID)
+	sig, err := signer.SignTx(genesisID, unsigned)
+	if err != nil {
+		return err
+	}
+	return p.AddSignature(ref, sig)
+}
+
+// AddSignature verifies and adds a signature by the signer with index ref, replacing any earlier
+// signature by the same signer.
+func (p *PartiallySignedTx) AddSignature(ref uint8, sig []byte) error {
+	if int(ref) >= len(p.PublicKeys) {
+		return fmt.Errorf("signer index %d out of range", ref)
+	}
+	body, err := p.signingBody()
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(ed25519.PublicKey(p.PublicKeys[ref]), body, sig) {
+		return ErrInvalidSignature
+	}
+	sigs := make([]Signature, 0, len(p.Signatures)+1)
+	for _, s := range p.Signatures {
+		if s.Ref != ref {
+			sigs = append(sigs, s)
+		}
+	}
+	sigs = append(sigs, Signature{Ref: ref, Signature: sig})
+	sort.Slice(sigs, func(i, j int) bool { return sigs[i].Ref < sigs[j].Ref })
+	p.Signatures = sigs
+	return nil
+}
+
+// Verify checks all signatures collected so far.
+func (p *PartiallySignedTx) Verify() error {
+	body, err := p.signingBody()
+	if err != nil {
+		return err
+	}
+	seen := make(map[uint8]bool, len(p.Signatures))
+	for _, s := range p.Signatures {
+		if int(s.Ref) >= len(p.PublicKeys) {
+			return fmt.Errorf("signer index %d out of range", s.Ref)
+		}
+		if seen[s.Ref] {
+			return fmt.Errorf("duplicate signature by signer %d", s.Ref)
+		}
+		seen[s.Ref] = true
+		if !ed25519.Verify(ed25519.PublicKey(p.PublicKeys[s.Ref]), body, s.Signature) {
+			return fmt.Errorf("%w by signer %d", ErrInvalidSignature, s.Ref)
+		}
+	}
+	return nil
+}
+
+// Signed reports whether the signer with index ref has signed.
+func (p *PartiallySignedTx) Signed(ref uint8) bool {
+	for _, s := range p.Signatures {
+		if s.Ref == ref {
+			return true
+		}
+	}
+	return false
+}
+
+// Complete reports whether enough signatures have been collected to finalize the transaction.
+func (p *PartiallySignedTx) Complete() bool {
+	return len(p.Signatures) >= int(p.Required)
+}
+
+// Finalize returns the raw transaction, ready to broadcast. The multisig template expects exactly
+// Required signatures ordered by signer index, so any extra signatures are dropped.
+func (p *PartiallySignedTx) Finalize() ([]byte, error) {
+	if err := p.Verify(); err != nil {
+		return nil, err
+	}
+	if !p.Complete() {
+		return nil, fmt.Errorf("%w: have %d, need %d", ErrNotEnoughSignatures, len(p.Signatures), p.Required)
+	}
+	unsigned, err := p.Unsigned()
+	if err != nil {
+		return nil, err
+	}
+	sigs := make([]Signature, len(p.Signatures))
+	copy(sigs, p.Signatures)
+	sort.Slice(sigs, func(i, j int) bool { return sigs[i].Ref < sigs[j].Ref })
+	agg := sdkMultisig.NewAggregator(unsigned)
+	for _, s := range sigs[:p.Required] {
+		part := multisig.Part{Ref: s.Ref}
+		copy(part.Sig[:], s.Signature)
+		agg.Add(part)
+	}
+	return agg.Raw(), nil
+}
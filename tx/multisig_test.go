@@ -0,0 +1,170 @@
+package tx
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/genvm/core"
+	"github.com/spacemeshos/go-spacemesh/genvm/sdk"
+	sdkMultisig "github.com/spacemeshos/go-spacemesh/genvm/sdk/multisig"
+	sdkVesting "github.com/spacemeshos/go-spacemesh/genvm/sdk/vesting"
+	"github.com/spacemeshos/go-spacemesh/genvm/templates/multisig"
+	"github.com/spacemeshos/go-spacemesh/genvm/templates/vault"
+	"github.com/spacemeshos/go-spacemesh/genvm/templates/vesting"
+	"github.com/stretchr/testify/require"
+
+	"github.com/spacemeshos/smcli/wallet"
+)
+
+func testSigners(t *testing.T, n int) ([]*wallet.EDKeyPair, []core.PublicKey) {
+	w, err := wallet.NewMultiWalletFromMnemonic(testMnemonic, "", n)
+	require.NoError(t, err)
+	pubs := make([]core.PublicKey, n)
+	for i, kp := range w.Secrets.Accounts {
+		copy(pubs[i][:], kp.Public)
+	}
+	return w.Secrets.Accounts, pubs
+}
+
+func TestMultisigSpendMatchesSDK(t *testing.T) {
+	signers, pubs := testSigners(t, 3)
+	opts := []sdk.Opt{sdk.WithGenesisID(testGenesisID()), sdk.WithGasPrice(2)}
+	to := types.GenerateAddress([]byte("recipient"))
+
+	p, err := NewMultisigSpend(TemplateMultisig, 2, pubs, to, 1000, 1, opts...)
+	require.NoError(t, err)
+	require.Equal(t, core.ComputePrincipal(multisig.TemplateAddress, p.spawnArgs()), p.Principal())
+
+	// signatures can be added in any order
+	require.NoError(t, p.Sign(testSigner(t, signers[2])))
+	require.False(t, p.Complete())
+	_, err = p.Finalize()
+	require.ErrorIs(t, err, ErrNotEnoughSignatures)
+	require.NoError(t, p.Sign(testSigner(t, signers[0])))
+	require.True(t, p.Complete())
+	raw, err := p.Finalize()
+	require.NoError(t, err)
+
+	agg := sdkMultisig.Spend(0, []byte(signers[0].Private), p.Principal(), to, 1000, 1, opts...)
+	other := sdkMultisig.Spend(2, []byte(signers[2].Private), p.Principal(), to, 1000, 1, opts...)
+	agg.Add(*other.Part(2))
+	require.Equal(t, agg.Raw(), raw)
+}
+
+func TestMultisigSpawnMatchesSDK(t *testing.T) {
+	signers, pubs := testSigners(t, 2)
+	opts := []sdk.Opt{sdk.WithGenesisID(testGenesisID())}
+
+	p, err := NewMultisigSpawn(TemplateMultisig, 1, pubs, 0, opts...)
+	require.NoError(t, err)
+	require.NoError(t, p.Sign(testSigner(t, signers[1])))
+	raw, err := p.Finalize()
+	require.NoError(t, err)
+
+	agg := sdkMultisig.Spawn(1, []byte(signers[1].Private), p.Principal(), multisig.TemplateAddress, p.spawnArgs(), 0,
+		opts...)
+	require.Equal(t, agg.Raw(), raw)
+}
+
+func TestMultisigFileRoundTrip(t *testing.T) {
+	signers, pubs := testSigners(t, 3)
+	p, err := NewMultisigSpend(TemplateVesting, 2, pubs, types.GenerateAddress([]byte("recipient")), 5, 0,
+		sdk.WithGenesisID(testGenesisID()))
+	require.NoError(t, err)
+	require.NoError(t, p.Sign(testSigner(t, signers[1])))
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, p.Write(buf))
+	p2, err := ReadPartiallySigned(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, p, p2)
+	require.True(t, p2.Signed(1))
+	require.False(t, p2.Signed(0))
+
+	// changing the transaction after it was signed invalidates the signature
+	p2.Amount++
+	buf.Reset()
+	require.NoError(t, p2.Write(buf))
+	_, err = ReadPartiallySigned(buf)
+	require.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestMultisigSignErrors(t *testing.T) {
+	signers, pubs := testSigners(t, 3)
+	p, err := NewMultisigSpawn(TemplateMultisig, 2, pubs[:2], 0)
+	require.NoError(t, err)
+	require.ErrorIs(t, p.Sign(testSigner(t, signers[2])), ErrNotASigner)
+	require.ErrorIs(t, p.AddSignature(0, make([]byte, ed25519.SignatureSize)), ErrInvalidSignature)
+
+	_, err = NewMultisigSpawn(TemplateMultisig, 3, pubs[:2], 0)
+	require.Error(t, err)
+	_, err = NewMultisigSpawn("wallet", 1, pubs, 0)
+	require.Error(t, err)
+}
+
+func testVaultArgs(owner types.Address) *vault.SpawnArguments {
+	return &vault.SpawnArguments{
+		Owner:               owner,
+		TotalAmount:         1000,
+		InitialUnlockAmount: 250,
+		VestingStart:        10,
+		VestingEnd:          20,
+	}
+}
+
+func TestVaultMatchesSDK(t *testing.T) {
+	signers, pubs := testSigners(t, 1)
+	opts := []sdk.Opt{sdk.WithGenesisID(testGenesisID())}
+	principal := core.ComputePrincipal(vesting.TemplateAddress, &multisig.SpawnArguments{Required: 1, PublicKeys: pubs})
+	vaultArgs := testVaultArgs(principal)
+	vaultAddress := core.ComputePrincipal(vault.TemplateAddress, vaultArgs)
+
+	spawn, err := NewVaultSpawn(1, pubs, vaultArgs, 1, opts...)
+	require.NoError(t, err)
+	require.Equal(t, principal, spawn.Principal())
+	require.Equal(t, vaultAddress, spawn.VaultAddress())
+	require.NoError(t, spawn.Sign(testSigner(t, signers[0])))
+	raw, err := spawn.Finalize()
+	require.NoError(t, err)
+	agg := sdkMultisig.Spawn(0, []byte(signers[0].Private), principal, vault.TemplateAddress, vaultArgs, 1, opts...)
+	require.Equal(t, agg.Raw(), raw)
+
+	drain, err := NewDrainVault(1, pubs, vaultArgs, principal, 100, 2, opts...)
+	require.NoError(t, err)
+	require.NoError(t, drain.Sign(testSigner(t, signers[0])))
+	raw, err = drain.Finalize()
+	require.NoError(t, err)
+	agg = sdkVesting.DrainVault(0, []byte(signers[0].Private), principal, vaultAddress, principal, 100, 2, opts...)
+	require.Equal(t, agg.Raw(), raw)
+
+	// the vault must be owned by the vesting account
+	_, err = NewVaultSpawn(1, pubs, testVaultArgs(types.GenerateAddress([]byte("other"))), 1, opts...)
+	require.Error(t, err)
+}
+
+// A co-signer can sign a partially signed transaction with a Ledger account, after the device shows
+// the vault, recipient and amount of the drain.
+func TestLedgerCoSigner(t *testing.T) {
+	device, signer := testLedgerSigner(t)
+	signers, pubs := testSigners(t, 1)
+	opts := []sdk.Opt{sdk.WithGenesisID(testGenesisID())}
+	principal := core.ComputePrincipal(vesting.TemplateAddress, &multisig.SpawnArguments{Required: 1, PublicKeys: pubs})
+	vaultArgs := testVaultArgs(principal)
+	vaultAddress := core.ComputePrincipal(vault.TemplateAddress, vaultArgs)
+
+	drain, err := NewDrainVault(1, pubs, vaultArgs, principal, 100, 2, opts...)
+	require.NoError(t, err)
+	require.NoError(t, drain.Sign(signer))
+	raw, err := drain.Finalize()
+	require.NoError(t, err)
+	agg := sdkVesting.DrainVault(0, []byte(signers[0].Private), principal, vaultAddress, principal, 100, 2, opts...)
+	require.Equal(t, agg.Raw(), raw)
+
+	require.Len(t, device.Txs, 1)
+	require.Equal(t, principal, device.Txs[0].Principal)
+	require.Equal(t, vaultAddress, device.Txs[0].Vault)
+	require.Equal(t, principal, device.Txs[0].To)
+	require.Equal(t, uint64(100), device.Txs[0].Amount)
+}
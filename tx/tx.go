@@ -0,0 +1,67 @@
+// Package tx builds and signs Spacemesh transactions without connecting to a node.
+// Transactions use the same encoding as the go-spacemesh genvm SDK, but the unsigned body is
+// produced separately from the signature so it can be signed offline or by several parties.
+package tx
+
+import (
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/genvm/core"
+	"github.com/spacemeshos/go-spacemesh/genvm/sdk"
+	walletTemplate "github.com/spacemeshos/go-spacemesh/genvm/templates/wallet"
+
+	"github.com/spacemeshos/smcli/wallet"
+)
+
+// WalletPrincipal returns the address of the wallet template account owned by the given public key.
+func WalletPrincipal(pub wallet.PublicKey) types.Address {
+	args := &walletTemplate.SpawnArguments{}
+	copy(args.PublicKey[:], pub)
+	return core.ComputePrincipal(walletTemplate.TemplateAddress, args)
+}
+
+// SelfSpawn returns a self-spawn transaction for the wallet template account owned by the signer,
+// signed by it.
+func SelfSpawn(signer wallet.Signer, nonce uint64, opts ...sdk.Opt) ([]byte, error) {
+	options := sdk.Defaults()
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	args := &walletTemplate.SpawnArguments{}
+	copy(args.PublicKey[:], signer.PublicKey())
+	principal := core.ComputePrincipal(walletTemplate.TemplateAddress, args)
+	template := walletTemplate.TemplateAddress
+
+	payload := core.Payload{Nonce: nonce, GasPrice: options.GasPrice}
+	unsigned := sdk.Encode(&sdk.TxVersion, &principal, &sdk.MethodSpawn, &template, &payload, args)
+	return sign(signer, options.GenesisID, unsigned)
+}
+
+// Spend returns a transaction that sends amount smidge from the wallet template account owned by
+// the signer to the given address, signed by it.
+func Spend(signer wallet.Signer, to types.Address, amount, nonce uint64, opts ...sdk.Opt) ([]byte, error) {
+	options := sdk.Defaults()
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	principal := WalletPrincipal(signer.PublicKey())
+	payload := core.Payload{Nonce: nonce, GasPrice: options.GasPrice}
+	args := &walletTemplate.SpendArguments{Destination: to, Amount: amount}
+	unsigned := sdk.Encode(&sdk.TxVersion, &principal, &sdk.MethodSpend, &payload, args)
+	return sign(signer, options.GenesisID, unsigned)
+}
+
+// ID returns the transaction ID of a raw, signed transaction.
+func ID(raw []byte) types.TransactionID {
+	return types.NewRawTx(raw).ID
+}
+
+// sign signs the unsigned transaction body, prefixed with the genesis ID, and appends the signature.
+func sign(signer wallet.Signer, genesisID types.Hash20, unsigned []byte) ([]byte, error) {
+	sig, err := signer.SignTx(genesisID, unsigned)
+	if err != nil {
+		return nil, err
+	}
+	return append(unsigned, sig...), nil
+}
@@ -0,0 +1,110 @@
+package tx
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/genvm/core"
+	"github.com/spacemeshos/go-spacemesh/genvm/sdk"
+	sdkWallet "github.com/spacemeshos/go-spacemesh/genvm/sdk/wallet"
+	"github.com/spacemeshos/go-spacemesh/signing"
+	"github.com/stretchr/testify/require"
+
+	"github.com/spacemeshos/smcli/wallet"
+)
+
+const testMnemonic = "film theme cheese broken kingdom destroy inch ready wear inspire shove pudding"
+
+func testAccount(t *testing.T) *wallet.EDKeyPair {
+	w, err := wallet.NewMultiWalletFromMnemonic(testMnemonic, "", 1)
+	require.NoError(t, err)
+	return w.Secrets.Accounts[0]
+}
+
+func testSigner(t *testing.T, kp *wallet.EDKeyPair) wallet.Signer {
+	signer, err := kp.Signer(nil)
+	require.NoError(t, err)
+	return signer
+}
+
+func testGenesisID() types.Hash20 {
+	var id types.Hash20
+	for i := range id {
+		id[i] = byte(i)
+	}
+	return id
+}
+
+// Our transactions must be byte-for-byte identical to those produced by the go-spacemesh SDK.
+func TestMatchesSDK(t *testing.T) {
+	kp := testAccount(t)
+	opts := []sdk.Opt{sdk.WithGenesisID(testGenesisID()), sdk.WithGasPrice(2)}
+
+	spawn, err := SelfSpawn(testSigner(t, kp), 0, opts...)
+	require.NoError(t, err)
+	require.Equal(t, sdkWallet.SelfSpawn(signing.PrivateKey(kp.Private), 0, opts...), spawn)
+
+	to := types.GenerateAddress([]byte("recipient"))
+	spend, err := Spend(testSigner(t, kp), to, 1000, 1, opts...)
+	require.NoError(t, err)
+	require.Equal(t, sdkWallet.Spend(signing.PrivateKey(kp.Private), to, 1000, 1, opts...), spend)
+}
+
+func TestSignatureVerifies(t *testing.T) {
+	kp := testAccount(t)
+	genesisID := testGenesisID()
+	raw, err := Spend(testSigner(t, kp), types.GenerateAddress([]byte("recipient")), 1, 0, sdk.WithGenesisID(genesisID))
+	require.NoError(t, err)
+
+	body, sig := raw[:len(raw)-ed25519.SignatureSize], raw[len(raw)-ed25519.SignatureSize:]
+	require.True(t, ed25519.Verify(ed25519.PublicKey(kp.Public), core.SigningBody(genesisID[:], body), sig))
+
+	// a signature made for one network must not be valid for another
+	require.False(t, ed25519.Verify(ed25519.PublicKey(kp.Public), core.SigningBody(make([]byte, 20), body), sig))
+}
+
+func TestPrincipal(t *testing.T) {
+	kp := testAccount(t)
+	require.Equal(t, wallet.PubkeyToAddress(kp.Public, types.NetworkHRP()), WalletPrincipal(kp.Public).String())
+}
+
+func TestNoPrivateKey(t *testing.T) {
+	kp := testAccount(t)
+	kp.Private = nil
+	_, err := kp.Signer(nil)
+	require.ErrorIs(t, err, wallet.ErrNoPrivateKey)
+}
+
+// testLedgerSigner returns a signer for the first account of a Ledger wallet created from a fake
+// device that holds the keys of testMnemonic.
+func testLedgerSigner(t *testing.T) (*wallet.FakeLedger, wallet.Signer) {
+	device, err := wallet.NewFakeLedgerFromMnemonic(testMnemonic)
+	require.NoError(t, err)
+	w, err := wallet.NewMultiWalletFromLedger(device, 1)
+	require.NoError(t, err)
+	signer, err := w.Secrets.Accounts[0].Signer(device)
+	require.NoError(t, err)
+	return device, signer
+}
+
+// A Ledger account signs the same transaction as a software account holding the same key, after
+// the device shows its recipient and amount.
+func TestLedgerSpend(t *testing.T) {
+	device, signer := testLedgerSigner(t)
+	kp := testAccount(t)
+	opts := []sdk.Opt{sdk.WithGenesisID(testGenesisID()), sdk.WithGasPrice(2)}
+	to := types.GenerateAddress([]byte("recipient"))
+
+	raw, err := Spend(signer, to, 1000, 1, opts...)
+	require.NoError(t, err)
+	require.Equal(t, sdkWallet.Spend(signing.PrivateKey(kp.Private), to, 1000, 1, opts...), raw)
+	require.Len(t, device.Txs, 1)
+	require.Equal(t, WalletPrincipal(kp.Public), device.Txs[0].Principal)
+	require.Equal(t, to, device.Txs[0].To)
+	require.Equal(t, uint64(1000), device.Txs[0].Amount)
+
+	device.Reject = true
+	_, err = Spend(signer, to, 1000, 2, opts...)
+	require.ErrorIs(t, err, wallet.ErrRejectedOnDevice)
+}
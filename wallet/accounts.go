@@ -0,0 +1,211 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/tyler-smith/go-bip39"
+
+	"github.com/spacemeshos/smcli/common"
+)
+
+var (
+	// ErrAccountNotFound is returned when the wallet doesn't contain an account with the given index.
+	ErrAccountNotFound = errors.New("account not found")
+
+	// ErrAmbiguousAccount is returned when selecting an account by an index that several accounts
+	// in different BIP-44 accounts or chains have.
+	ErrAmbiguousAccount = errors.New("ambiguous account index")
+
+	// ErrAccountExists is returned when adding an account with an index already in the wallet.
+	ErrAccountExists = errors.New("account already exists")
+
+	// ErrTooManyAccounts is returned when adding an account to a wallet that already contains
+	// common.MaxAccountsPerWallet accounts.
+	ErrTooManyAccounts = errors.New("too many accounts")
+
+	// ErrWrongPassphrase is returned when the seed derived from the wallet's mnemonic and a
+	// passphrase doesn't match the wallet's master key.
+	ErrWrongPassphrase = errors.New("wrong BIP-39 passphrase")
+)
+
+// Index returns the address index of the account, i.e., the last (hardened) segment of its HD
+// path without the hardened bit. Accounts are identified by their index within a wallet, which
+// stays the same when other accounts are removed.
+func (kp *EDKeyPair) Index() (uint32, bool) {
+	if len(kp.Path) <= HDIndexSegment {
+		return 0, false
+	}
+	return kp.Path.Index() &^ BIP32HardenedKeyStart, true
+}
+
+// IsLedger reports whether the wallet's keys are held by a Ledger device.
+func (w *Wallet) IsLedger() bool {
+	return w.Secrets.MasterKeypair != nil && w.Secrets.MasterKeypair.IsLedger()
+}
+
+// IsLedger reports whether the private key of the account is held by a Ledger device.
+func (kp *EDKeyPair) IsLedger() bool {
+	return kp.KeyType == typeLedger
+}
+
+// Seed returns the BIP-39 seed of the wallet, derived from its mnemonic and the given passphrase
+// (empty if the wallet was created without one). It returns ErrWrongPassphrase if the seed doesn't
+// derive the wallet's master key. Ledger wallets have no seed; nil is returned for them.
+func (w *Wallet) Seed(passphrase string) ([]byte, error) {
+	if w.IsLedger() {
+		return nil, nil
+	}
+	if w.Secrets.MasterKeypair == nil {
+		return nil, errors.New("wallet has no master key")
+	}
+	if _, err := ParseMnemonic(w.Secrets.Mnemonic); err != nil {
+		return nil, fmt.Errorf("wallet has no valid mnemonic: %w", err)
+	}
+	// wallets used to be created without normalizing the passphrase, which only makes a difference
+	// for passphrases that aren't ASCII
+	for _, seed := range [][]byte{
+		MnemonicSeed(w.Secrets.Mnemonic, passphrase),
+		bip39.NewSeed(w.Secrets.Mnemonic, passphrase),
+	} {
+		master, err := NewMasterKeyPair(seed)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(master.Public, w.Secrets.MasterKeypair.Public) {
+			return seed, nil
+		}
+	}
+	return nil, ErrWrongPassphrase
+}
+
+// Account returns the account with the given index. Accounts with the same index in different
+// BIP-44 accounts or chains can only be selected by path; ErrAmbiguousAccount is returned for them.
+func (w *Wallet) Account(index uint32) (*EDKeyPair, error) {
+	var found *EDKeyPair
+	for _, a := range w.Secrets.Accounts {
+		if i, ok := a.Index(); ok && i == index {
+			if found != nil {
+				return nil, fmt.Errorf("%w: %s and %s both have index %d, select one by path",
+					ErrAmbiguousAccount, found.Path.String(), a.Path.String(), index)
+			}
+			found = a
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: %d", ErrAccountNotFound, index)
+	}
+	return found, nil
+}
+
+// AccountByPath returns the account with the given HD path.
+func (w *Wallet) AccountByPath(path HDPath) (*EDKeyPair, error) {
+	for _, a := range w.Secrets.Accounts {
+		if slices.Equal(a.Path, path) {
+			return a, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrAccountNotFound, path.String())
+}
+
+// FindAccount returns the account selected by s, which is either an account index (see Account)
+// or a full HD path such as m/44'/540'/0'/0'/1'.
+func (w *Wallet) FindAccount(s string) (*EDKeyPair, error) {
+	if strings.HasPrefix(s, "m/") {
+		path, err := StringToHDPath(s)
+		if err != nil {
+			return nil, err
+		}
+		return w.AccountByPath(path)
+	}
+	index, err := strconv.ParseUint(s, 10, 31)
+	if err != nil {
+		return nil, fmt.Errorf("invalid account %q: expected an index or an HD path", s)
+	}
+	return w.Account(uint32(index))
+}
+
+// NextAccountIndex returns the index following the highest account index in the given chain (see
+// ChainPath), so that indices of removed accounts aren't reused unless requested explicitly.
+func (w *Wallet) NextAccountIndex(chain HDPath) uint32 {
+	next := uint32(0)
+	for _, a := range w.Secrets.Accounts {
+		if !slices.Equal(a.Path[:min(len(a.Path), len(chain))], chain) {
+			continue
+		}
+		if i, ok := a.Index(); ok && i >= next {
+			next = i + 1
+		}
+	}
+	return next
+}
+
+// AddAccount derives the account with the given path (see AccountPath) and adds it to the wallet,
+// keeping the accounts sorted by path. seed is the wallet's seed (see Seed); it's not used by
+// Ledger wallets, which read the public key from device instead. device isn't used by software
+// wallets and may be nil. If name is empty, a default name is used.
+func (w *Wallet) AddAccount(seed []byte, device LedgerDevice, path HDPath, name string) (*EDKeyPair, error) {
+	if len(w.Secrets.Accounts) >= common.MaxAccountsPerWallet {
+		return nil, fmt.Errorf("%w: a wallet may contain at most %d accounts",
+			ErrTooManyAccounts, common.MaxAccountsPerWallet)
+	}
+	if err := ValidateAccountPath(path); err != nil {
+		return nil, err
+	}
+	if _, err := w.AccountByPath(path); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrAccountExists, path.String())
+	}
+	if w.Secrets.MasterKeypair == nil {
+		return nil, errors.New("wallet has no master key")
+	}
+	var kp *EDKeyPair
+	var err error
+	if w.IsLedger() {
+		if device == nil {
+			return nil, ErrNoLedgerDevice
+		}
+		kp, err = NewLedgerKeyPairFromPath(device, path)
+	} else {
+		kp, err = NewKeyPairFromPath(seed, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if name != "" {
+		kp.DisplayName = name
+	}
+	w.Secrets.Accounts = append(w.Secrets.Accounts, kp)
+	slices.SortStableFunc(w.Secrets.Accounts, func(a, b *EDKeyPair) int {
+		return slices.Compare(a.Path, b.Path)
+	})
+	return kp, nil
+}
+
+// RemoveAccount removes the account with the given path from the wallet. The paths, and so the
+// indices, of the other accounts don't change.
+func (w *Wallet) RemoveAccount(path HDPath) error {
+	for i, a := range w.Secrets.Accounts {
+		if slices.Equal(a.Path, path) {
+			w.Secrets.Accounts = append(w.Secrets.Accounts[:i], w.Secrets.Accounts[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrAccountNotFound, path.String())
+}
+
+// RenameAccount sets the display name of the account with the given path.
+func (w *Wallet) RenameAccount(path HDPath, name string) error {
+	if name == "" {
+		return errors.New("name must not be empty")
+	}
+	a, err := w.AccountByPath(path)
+	if err != nil {
+		return err
+	}
+	a.DisplayName = name
+	return nil
+}
@@ -0,0 +1,145 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/spacemeshos/smcli/common"
+)
+
+func TestAccountIndex(t *testing.T) {
+	w, err := NewMultiWalletRandomMnemonic("", 3)
+	require.NoError(t, err)
+	for i, a := range w.Secrets.Accounts {
+		idx, ok := a.Index()
+		require.True(t, ok)
+		require.Equal(t, uint32(i), idx)
+	}
+	_, ok := w.Secrets.MasterKeypair.Index()
+	require.False(t, ok)
+}
+
+func TestSeed(t *testing.T) {
+	w, err := NewMultiWalletRandomMnemonic("secret", 1)
+	require.NoError(t, err)
+	seed, err := w.Seed("secret")
+	require.NoError(t, err)
+	master, err := NewMasterKeyPair(seed)
+	require.NoError(t, err)
+	require.Equal(t, w.Secrets.MasterKeypair.Public, master.Public)
+
+	_, err = w.Seed("")
+	require.ErrorIs(t, err, ErrWrongPassphrase)
+	_, err = w.Seed("Secret")
+	require.ErrorIs(t, err, ErrWrongPassphrase)
+}
+
+func TestAddRemoveRenameAccount(t *testing.T) {
+	w, err := NewMultiWalletRandomMnemonic("pass", 3)
+	require.NoError(t, err)
+	seed, err := w.Seed("pass")
+	require.NoError(t, err)
+
+	// removing an account keeps the indices of the others
+	require.NoError(t, w.RemoveAccount(AccountPath(0, 0, 1)))
+	require.Len(t, w.Secrets.Accounts, 2)
+	_, err = w.Account(1)
+	require.ErrorIs(t, err, ErrAccountNotFound)
+	a2, err := w.Account(2)
+	require.NoError(t, err)
+	require.Equal(t, "Child Key 2", a2.DisplayName)
+	require.ErrorIs(t, w.RemoveAccount(AccountPath(0, 0, 1)), ErrAccountNotFound)
+
+	// new accounts don't reuse removed indices
+	require.Equal(t, uint32(3), w.NextAccountIndex(DefaultPath()))
+	a3, err := w.AddAccount(seed, nil, AccountPath(0, 0, w.NextAccountIndex(DefaultPath())), "")
+	require.NoError(t, err)
+	require.Equal(t, "Child Key 3", a3.DisplayName)
+	require.Equal(t, uint32(4), w.NextAccountIndex(DefaultPath()))
+
+	// unless requested explicitly, in which case the same key is derived again
+	other, err := NewMultiWalletFromMnemonic(w.Mnemonic(), "pass", 2)
+	require.NoError(t, err)
+	a1, err := w.AddAccount(seed, nil, AccountPath(0, 0, 1), "savings")
+	require.NoError(t, err)
+	require.Equal(t, "savings", a1.DisplayName)
+	require.Equal(t, other.Secrets.Accounts[1].Public, a1.Public)
+	require.Equal(t, other.Secrets.Accounts[1].Private, a1.Private)
+	_, err = w.AddAccount(seed, nil, AccountPath(0, 0, 1), "")
+	require.ErrorIs(t, err, ErrAccountExists)
+	for i, idx := range []uint32{0, 1, 2, 3} {
+		got, _ := w.Secrets.Accounts[i].Index()
+		require.Equal(t, idx, got)
+	}
+
+	require.NoError(t, w.RenameAccount(AccountPath(0, 0, 3), "spending"))
+	require.Equal(t, "spending", a3.DisplayName)
+	require.Error(t, w.RenameAccount(AccountPath(0, 0, 3), ""))
+	require.ErrorIs(t, w.RenameAccount(AccountPath(0, 0, 5), "x"), ErrAccountNotFound)
+}
+
+func TestAddAccountLimit(t *testing.T) {
+	w, err := NewMultiWalletRandomMnemonic("", common.MaxAccountsPerWallet)
+	require.NoError(t, err)
+	seed, err := w.Seed("")
+	require.NoError(t, err)
+	_, err = w.AddAccount(seed, nil, AccountPath(0, 0, w.NextAccountIndex(DefaultPath())), "")
+	require.ErrorIs(t, err, ErrTooManyAccounts)
+
+	require.NoError(t, w.RemoveAccount(AccountPath(0, 0, 0)))
+	_, err = w.AddAccount(seed, nil, AccountPath(0, 0, w.NextAccountIndex(DefaultPath())), "")
+	require.NoError(t, err)
+}
+
+func TestAccountsInOtherChains(t *testing.T) {
+	w, err := NewMultiWalletRandomMnemonic("", 2)
+	require.NoError(t, err)
+	seed, err := w.Seed("")
+	require.NoError(t, err)
+
+	// accounts in other BIP-44 accounts and chains are derived from the same seed, and don't take
+	// the indices of the default chain
+	testnet := ChainPath(0, 1)
+	require.Equal(t, uint32(0), w.NextAccountIndex(testnet))
+	a, err := w.AddAccount(seed, nil, AccountPath(0, 1, 0), "testnet")
+	require.NoError(t, err)
+	require.Equal(t, "m/44'/540'/0'/1'/0'", a.Path.String())
+	require.Equal(t, uint32(1), w.NextAccountIndex(testnet))
+	require.Equal(t, uint32(2), w.NextAccountIndex(DefaultPath()))
+	b, err := w.AddAccount(seed, nil, AccountPath(3, 0, 1), "")
+	require.NoError(t, err)
+	require.NotEqual(t, w.Secrets.Accounts[1].Public, b.Public)
+
+	// accounts are sorted by path
+	paths := make([]string, 0, len(w.Secrets.Accounts))
+	for _, a := range w.Secrets.Accounts {
+		paths = append(paths, a.Path.String())
+	}
+	require.Equal(t, []string{
+		"m/44'/540'/0'/0'/0'",
+		"m/44'/540'/0'/0'/1'",
+		"m/44'/540'/0'/1'/0'",
+		"m/44'/540'/3'/0'/1'",
+	}, paths)
+
+	// indices are ambiguous across chains
+	_, err = w.Account(0)
+	require.ErrorIs(t, err, ErrAmbiguousAccount)
+	got, err := w.FindAccount("m/44'/540'/0'/1'/0'")
+	require.NoError(t, err)
+	require.Equal(t, a, got)
+	_, err = w.FindAccount("m/44'/540'/0'/1'/1'")
+	require.ErrorIs(t, err, ErrAccountNotFound)
+	_, err = w.FindAccount("x")
+	require.Error(t, err)
+	require.NoError(t, w.RemoveAccount(AccountPath(0, 0, 0)))
+	got, err = w.FindAccount("0")
+	require.NoError(t, err)
+	require.Equal(t, a, got)
+
+	_, err = w.AddAccount(seed, nil, AccountPath(0, 1, 0), "")
+	require.ErrorIs(t, err, ErrAccountExists)
+	_, err = w.AddAccount(seed, nil, HDPath{BIP44Purpose(), BIP44SpacemeshCoinType(), 0, 0, 0}, "")
+	require.ErrorIs(t, err, ErrInvalidPath)
+}
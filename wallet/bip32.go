@@ -8,7 +8,6 @@ import (
 	"fmt"
 
 	smbip32 "github.com/spacemeshos/smkeys/bip32"
-	ledger "github.com/spacemeshos/smkeys/remote-wallet"
 
 	"github.com/spacemeshos/smcli/common"
 )
@@ -25,6 +24,19 @@ const (
 	typeLedger
 )
 
+// String returns the name of the key type: "software" for keys derived from a mnemonic and
+// "ledger" for keys held by a Ledger device.
+func (k keyType) String() string {
+	switch k {
+	case typeSoftware:
+		return "software"
+	case typeLedger:
+		return "ledger"
+	default:
+		return fmt.Sprintf("unknown (%d)", int(k))
+	}
+}
+
 func (k *PublicKey) MarshalJSON() ([]byte, error) {
 	return json.Marshal(hex.EncodeToString(*k))
 }
@@ -78,39 +90,65 @@ func NewMasterKeyPair(seed []byte) (*EDKeyPair, error) {
 	}, nil
 }
 
+// NewChildKeyPair derives the child key with the given index of a software master key from the
+// seed. Child keys of Ledger master keys live on the device; use NewLedgerChildKeyPair for those.
 func (kp *EDKeyPair) NewChildKeyPair(seed []byte, childIdx int) (*EDKeyPair, error) {
-	path := kp.Path.Extend(BIP44HardenedAccountIndex(uint32(childIdx)))
 	switch kp.KeyType {
 	case typeLedger:
-		return pubkeyFromLedger(path, false)
+		return nil, ErrNoLedgerDevice
 	case typeSoftware:
-		key, err := smbip32.Derive(HDPathToString(path), seed)
-		if err != nil {
-			return nil, err
-		}
-		return &EDKeyPair{
-			DisplayName: fmt.Sprintf("Child Key %d", childIdx),
-			Created:     common.NowTimeString(),
-			Private:     key[:],
-			Public:      PublicKey(ed25519.PrivateKey(key).Public().(ed25519.PublicKey)),
-			Path:        path,
-		}, nil
+		return NewKeyPairFromPath(seed, kp.Path.Extend(BIP44HardenedAccountIndex(uint32(childIdx))))
 	default:
 		return nil, errors.New("unknown key type")
 	}
 }
 
-func NewMasterKeyPairFromLedger() (*EDKeyPair, error) {
-	return pubkeyFromLedger(DefaultPath(), true)
+// NewKeyPairFromPath derives the account key at path from the seed.
+func NewKeyPairFromPath(seed []byte, path HDPath) (*EDKeyPair, error) {
+	if err := ValidateAccountPath(path); err != nil {
+		return nil, err
+	}
+	key, err := smbip32.Derive(HDPathToString(path), seed)
+	if err != nil {
+		return nil, err
+	}
+	return &EDKeyPair{
+		DisplayName: fmt.Sprintf("Child Key %d", path.Index()&^BIP32HardenedKeyStart),
+		Created:     common.NowTimeString(),
+		Private:     key[:],
+		Public:      PublicKey(ed25519.PrivateKey(key).Public().(ed25519.PublicKey)),
+		Path:        path,
+	}, nil
 }
 
-func pubkeyFromLedger(path HDPath, master bool) (*EDKeyPair, error) {
-	// TODO: support multiple ledger devices (https://github.com/spacemeshos/smcli/issues/46)
+// NewLedgerChildKeyPair reads the child key with the given index of a Ledger master key from the
+// device.
+func (kp *EDKeyPair) NewLedgerChildKeyPair(device LedgerDevice, childIdx int) (*EDKeyPair, error) {
+	if kp.KeyType != typeLedger {
+		return nil, errors.New("not a Ledger key")
+	}
+	return NewLedgerKeyPairFromPath(device, kp.Path.Extend(BIP44HardenedAccountIndex(uint32(childIdx))))
+}
+
+// NewLedgerKeyPairFromPath reads the account key at path from the device, which asks the user to
+// confirm it.
+func NewLedgerKeyPairFromPath(device LedgerDevice, path HDPath) (*EDKeyPair, error) {
+	if err := ValidateAccountPath(path); err != nil {
+		return nil, err
+	}
+	return pubkeyFromLedger(device, path, false)
+}
+
+func NewMasterKeyPairFromLedger(device LedgerDevice) (*EDKeyPair, error) {
+	return pubkeyFromLedger(device, DefaultPath(), true)
+}
+
+func pubkeyFromLedger(device LedgerDevice, path HDPath, master bool) (*EDKeyPair, error) {
 	// don't bother confirming the master key; we only want the user to have to confirm a single key,
 	// the one they really care about, which is the first child key.
-	key, err := ledger.ReadPubkeyFromLedger("", HDPathToString(path), !master)
+	key, err := device.PublicKey(path, !master)
 	if err != nil {
-		return nil, fmt.Errorf("error reading pubkey from ledger: %w", err)
+		return nil, err
 	}
 
 	name := "Ledger Master Key"
@@ -23,6 +23,26 @@ func TestNonHardenedPath(t *testing.T) {
 	require.False(t, IsPathCompletelyHardened(path2Hd))
 }
 
+func TestValidatePath(t *testing.T) {
+	require.NoError(t, ValidateAccountPath(AccountPath(1, 2, 3)))
+	require.NoError(t, ValidateChainPath(ChainPath(1, 2)))
+	require.Equal(t, "m/44'/540'/1'/2'/3'", HDPathToString(AccountPath(1, 2, 3)))
+
+	for _, s := range []string{
+		"m/44'/540'/0'/0'",
+		"m/44'/540'/0'/0'/0",
+		"m/44'/540'/0/0'/0'",
+		"m/44'/60'/0'/0'/0'",
+		"m/49'/540'/0'/0'/0'",
+		"m/44'/540'/0'/0'/0'/0'",
+	} {
+		path, err := StringToHDPath(s)
+		require.NoError(t, err)
+		require.ErrorIs(t, ValidateAccountPath(path), ErrInvalidPath, s)
+	}
+	require.ErrorIs(t, ValidateChainPath(AccountPath(0, 0, 0)), ErrInvalidPath)
+}
+
 // Test that path string produces expected path and vice-versa.
 func TestPath(t *testing.T) {
 	path1Str := "m/44'/540'/0'/0'/0'"
@@ -2,6 +2,7 @@ package wallet
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -10,6 +11,9 @@ import (
 
 //lint:file-ignore SA4016 ignore ineffective bitwise operations to aid readability
 
+// ErrInvalidPath is returned for HD paths that can't be used for wallet accounts.
+var ErrInvalidPath = errors.New("invalid HD path")
+
 // BIP32HardenedKeyStart: keys with index >= this must be hardened as per BIP32.
 // https://github.com/bitcoin/bips/blob/master/bip-0032.mediawiki#extended-keys
 const BIP32HardenedKeyStart uint32 = 0x80000000
@@ -61,8 +65,9 @@ func (p *HDPath) Index() uint32 {
 	return (*p)[HDIndexSegment]
 }
 
+// Extend returns a new path with idx appended to p. p isn't modified.
 func (p *HDPath) Extend(idx uint32) HDPath {
-	return append(*p, idx)
+	return append((*p)[:len(*p):len(*p)], idx)
 }
 
 // Root of the path is m/purpose' (m/44')
@@ -79,7 +84,8 @@ func BIP44SpacemeshCoinType() uint32 {
 }
 
 // After the coin type comes the account (m/44'/540'/account')
-// For now we only support account 0'.
+// Accounts can be used to separate funds, e.g. of different business units. The
+// default account is 0'.
 func BIP44Account() uint32 {
 	//nolint:staticcheck // ignore ineffective bitwise operations to aid readability
 	return BIP32HardenedKeyStart | 0
@@ -95,7 +101,7 @@ func BIP44Account() uint32 {
 // used to describe a sequence of addresses that are related to each other even
 // after the account level.
 // (m/44'/540'/account'/chain')
-// For now we only support "chain" 0. We may want to use a different chain for testnet.
+// The default chain is 0'. A different chain can be used, e.g., for testnet.
 func BIP44HardenedChain() uint32 {
 	//nolint:staticcheck // ignore ineffective bitwise operations to aid readability
 	return BIP32HardenedKeyStart | 0
@@ -118,6 +124,52 @@ func DefaultPath() HDPath {
 	}
 }
 
+// ChainPath returns the path of the given (unhardened) BIP-44 account and chain numbers,
+// m/44'/540'/account'/chain'. Wallet accounts are derived as its hardened children.
+func ChainPath(account, chain uint32) HDPath {
+	return HDPath{
+		BIP44Purpose(),
+		BIP44SpacemeshCoinType(),
+		BIP32HardenedKeyStart | account,
+		BIP32HardenedKeyStart | chain,
+	}
+}
+
+// AccountPath returns the path of the wallet account with the given (unhardened) BIP-44 account,
+// chain and address index, m/44'/540'/account'/chain'/index'.
+func AccountPath(account, chain, index uint32) HDPath {
+	path := ChainPath(account, chain)
+	return path.Extend(BIP44HardenedAccountIndex(index))
+}
+
+// ValidateChainPath checks that path is a completely hardened Spacemesh BIP-44 chain path of the
+// form m/44'/540'/account'/chain'.
+func ValidateChainPath(path HDPath) error {
+	if len(path) != HDIndexSegment {
+		return fmt.Errorf("%w %s: expected m/44'/540'/account'/chain'", ErrInvalidPath, path.String())
+	}
+	return validatePath(path)
+}
+
+// ValidateAccountPath checks that path is a completely hardened Spacemesh BIP-44 account path of
+// the form m/44'/540'/account'/chain'/index'.
+func ValidateAccountPath(path HDPath) error {
+	if len(path) != HDIndexSegment+1 {
+		return fmt.Errorf("%w %s: expected m/44'/540'/account'/chain'/index'", ErrInvalidPath, path.String())
+	}
+	return validatePath(path)
+}
+
+func validatePath(path HDPath) error {
+	if path.Purpose() != BIP44Purpose() || path.CoinType() != BIP44SpacemeshCoinType() {
+		return fmt.Errorf("%w %s: must start with m/44'/540'", ErrInvalidPath, path.String())
+	}
+	if !IsPathCompletelyHardened(path) {
+		return fmt.Errorf("%w %s: all segments must be hardened", ErrInvalidPath, path.String())
+	}
+	return nil
+}
+
 func IsPathCompletelyHardened(path HDPath) bool {
 	for _, p := range path {
 		if p < BIP32HardenedKeyStart {
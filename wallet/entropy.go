@@ -0,0 +1,129 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Kinds of user-supplied entropy.
+const (
+	// EntropyDice is rolls of a six-sided die, entered as digits 1 to 6.
+	EntropyDice = "dice"
+	// EntropyCoins is coin flips, entered as h and t, or 1 and 0.
+	EntropyCoins = "coins"
+	// EntropyHex is hexadecimal digits, e.g. from a hardware random number generator.
+	EntropyHex = "hex"
+)
+
+// ErrNotEnoughEntropy is returned when generating a mnemonic from less user-supplied entropy than
+// the mnemonic encodes.
+var ErrNotEnoughEntropy = errors.New("not enough entropy")
+
+// UserEntropy collects entropy supplied by the user, such as dice rolls, to generate a mnemonic
+// without relying only on the operating system's random number generator.
+type UserEntropy struct {
+	kind    string
+	symbols []byte
+}
+
+// NewUserEntropy returns an empty collection of user-supplied entropy of the given kind.
+func NewUserEntropy(kind string) (*UserEntropy, error) {
+	switch kind {
+	case EntropyDice, EntropyCoins, EntropyHex:
+		return &UserEntropy{kind: kind}, nil
+	default:
+		return nil, fmt.Errorf("unknown entropy kind %q, expected %s, %s or %s", kind, EntropyDice, EntropyCoins,
+			EntropyHex)
+	}
+}
+
+// Kind returns the kind of entropy collected.
+func (e *UserEntropy) Kind() string {
+	return e.kind
+}
+
+// Add adds the dice rolls, coin flips or hex digits in s, ignoring whitespace. If s contains
+// anything else, nothing is added.
+func (e *UserEntropy) Add(s string) error {
+	symbols := make([]byte, 0, len(s))
+	for i, c := range []rune(strings.ToLower(s)) {
+		switch {
+		case unicode.IsSpace(c):
+			continue
+		case e.kind == EntropyDice && c >= '1' && c <= '6':
+		case e.kind == EntropyCoins && (c == '0' || c == '1'):
+		// coin flips are stored as bits
+		case e.kind == EntropyCoins && c == 'h':
+			c = '1'
+		case e.kind == EntropyCoins && c == 't':
+			c = '0'
+		case e.kind == EntropyHex && (c >= '0' && c <= '9' || c >= 'a' && c <= 'f'):
+		default:
+			return fmt.Errorf("invalid %s entropy %q at position %d", e.kind, c, i+1)
+		}
+		symbols = append(symbols, byte(c))
+	}
+	e.symbols = append(e.symbols, symbols...)
+	return nil
+}
+
+// Len returns the number of dice rolls, coin flips or hex digits collected.
+func (e *UserEntropy) Len() int {
+	return len(e.symbols)
+}
+
+// Bits returns the number of bits of entropy collected, assuming a fair die or coin.
+func (e *UserEntropy) Bits() float64 {
+	switch e.kind {
+	case EntropyDice:
+		return float64(len(e.symbols)) * math.Log2(6)
+	case EntropyCoins:
+		return float64(len(e.symbols))
+	default:
+		return float64(len(e.symbols)) * 4
+	}
+}
+
+// Mnemonic generates a mnemonic with the given number of words from the wordlist of the given
+// language, like NewMnemonic but from the collected entropy, which must be at least as much as the
+// mnemonic encodes. Hex digits are used as they are if there are exactly as many as needed;
+// otherwise the entropy is the SHA-256 hash of the rolls, flips or digits as entered. If mix is
+// set, the entropy is XORed with as many bytes from the operating system's random number generator,
+// so that the mnemonic is as secure as the better of the two sources.
+func (e *UserEntropy) Mnemonic(words int, language string, mix bool) (string, error) {
+	bits := MnemonicEntropyBits(words)
+	if bits == 0 {
+		return "", fmt.Errorf("%w: %d words, expected 12, 15, 18, 21 or 24", ErrMnemonicLength, words)
+	}
+	if e.Bits() < float64(bits) {
+		return "", fmt.Errorf("%w: %.1f bits collected, %d needed for %d words", ErrNotEnoughEntropy, e.Bits(),
+			bits, words)
+	}
+
+	var entropy []byte
+	if e.kind == EntropyHex && len(e.symbols) == bits/4 {
+		var err error
+		if entropy, err = hex.DecodeString(string(e.symbols)); err != nil {
+			return "", err
+		}
+	} else {
+		h := sha256.Sum256(e.symbols)
+		entropy = h[:bits/8]
+	}
+	if mix {
+		r := make([]byte, len(entropy))
+		if _, err := rand.Read(r); err != nil {
+			return "", err
+		}
+		for i := range entropy {
+			entropy[i] ^= r[i]
+		}
+	}
+	return MnemonicFromEntropy(entropy, language)
+}
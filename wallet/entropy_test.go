@@ -0,0 +1,80 @@
+package wallet
+
+import (
+	"crypto/sha256"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiceEntropy(t *testing.T) {
+	e, err := NewUserEntropy(EntropyDice)
+	require.NoError(t, err)
+	rolls := strings.Repeat("1625343", 14) // 98 rolls, 253.3 bits
+	require.NoError(t, e.Add(rolls))
+	require.InDelta(t, 253.3, e.Bits(), 0.1)
+	_, err = e.Mnemonic(24, DefaultMnemonicLanguage, false)
+	require.ErrorIs(t, err, ErrNotEnoughEntropy)
+
+	// invalid rolls aren't added
+	require.Error(t, e.Add("12 7"))
+	require.Equal(t, 98, e.Len())
+	require.NoError(t, e.Add(" 6\n6 "))
+	require.Equal(t, 100, e.Len())
+	require.GreaterOrEqual(t, e.Bits(), 256.0)
+
+	// the entropy is the hash of the rolls, so the mnemonic can be checked with other tools
+	h := sha256.Sum256([]byte(rolls + "66"))
+	expected, err := MnemonicFromEntropy(h[:], "english")
+	require.NoError(t, err)
+	m, err := e.Mnemonic(24, "english", false)
+	require.NoError(t, err)
+	require.Equal(t, expected, m)
+
+	// mixing in random bytes gives a different mnemonic every time
+	m1, err := e.Mnemonic(24, "english", true)
+	require.NoError(t, err)
+	m2, err := e.Mnemonic(24, "english", true)
+	require.NoError(t, err)
+	require.NotEqual(t, m, m1)
+	require.NotEqual(t, m1, m2)
+	_, err = ParseMnemonic(m1)
+	require.NoError(t, err)
+
+	// fewer rolls are needed for shorter mnemonics
+	e, err = NewUserEntropy(EntropyDice)
+	require.NoError(t, err)
+	require.NoError(t, e.Add(strings.Repeat("3", 50)))
+	m, err = e.Mnemonic(12, "spanish", false)
+	require.NoError(t, err)
+	require.Len(t, MnemonicWords(m), 12)
+}
+
+func TestCoinAndHexEntropy(t *testing.T) {
+	e, err := NewUserEntropy(EntropyCoins)
+	require.NoError(t, err)
+	require.NoError(t, e.Add(strings.Repeat("HT", 64)))
+	require.Equal(t, 128.0, e.Bits())
+	h := sha256.Sum256([]byte(strings.Repeat("10", 64)))
+	expected, err := MnemonicFromEntropy(h[:16], "english")
+	require.NoError(t, err)
+	m, err := e.Mnemonic(12, "english", false)
+	require.NoError(t, err)
+	require.Equal(t, expected, m)
+	require.Error(t, e.Add("x"))
+
+	// exactly as many hex digits as needed are used as they are
+	e, err = NewUserEntropy(EntropyHex)
+	require.NoError(t, err)
+	require.NoError(t, e.Add("00000000 00000000 00000000 0000000"))
+	_, err = e.Mnemonic(12, "english", false)
+	require.ErrorIs(t, err, ErrNotEnoughEntropy)
+	require.NoError(t, e.Add("0"))
+	m, err = e.Mnemonic(12, "english", false)
+	require.NoError(t, err)
+	require.Equal(t, "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", m)
+
+	_, err = NewUserEntropy("cards")
+	require.Error(t, err)
+}
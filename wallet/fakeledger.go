@@ -0,0 +1,164 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/spacemeshos/go-scale"
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/genvm/core"
+	"github.com/spacemeshos/go-spacemesh/genvm/templates/vesting"
+	walletTemplate "github.com/spacemeshos/go-spacemesh/genvm/templates/wallet"
+	smbip32 "github.com/spacemeshos/smkeys/bip32"
+)
+
+// FakeLedger is an in-memory LedgerDevice for tests. Like the Spacemesh app, it derives its keys
+// from a BIP-39 seed, so a fake created from a mnemonic holds the same keys as a software wallet
+// created from it. It records what the device shows for each request: the public keys it was
+// asked to confirm, and the transactions and messages it was asked to sign.
+type FakeLedger struct {
+	seed []byte
+
+	mu sync.Mutex
+	// Reject makes the device reject every request that needs confirmation, including signing.
+	Reject bool
+	// Confirms is the number of public keys the device was asked to confirm.
+	Confirms int
+	// Txs are the transactions the device was asked to sign, as it shows them, including the ones
+	// that were rejected.
+	Txs []FakeLedgerTx
+	// Messages are the messages the device was asked to sign, including the ones that were rejected.
+	Messages [][]byte
+}
+
+// FakeLedgerTx is a transaction that a FakeLedger was asked to sign, decoded as the Spacemesh app
+// shows it on the device before the user confirms it. Vault is only set for vault drains, and To
+// and Amount for spends and vault drains.
+type FakeLedgerTx struct {
+	Path      HDPath
+	GenesisID types.Hash20
+	Principal types.Address
+	Method    uint8
+	Vault     types.Address
+	To        types.Address
+	Amount    uint64
+}
+
+// NewFakeLedger returns a fake Ledger device that derives its keys from the seed.
+func NewFakeLedger(seed []byte) *FakeLedger {
+	return &FakeLedger{seed: seed}
+}
+
+// NewFakeLedgerFromMnemonic returns a fake Ledger device that holds the keys of the mnemonic, as a
+// Ledger device set up using it does.
+func NewFakeLedgerFromMnemonic(mnemonic string) (*FakeLedger, error) {
+	if _, err := ParseMnemonic(mnemonic); err != nil {
+		return nil, err
+	}
+	return NewFakeLedger(MnemonicSeed(mnemonic, "")), nil
+}
+
+func (l *FakeLedger) key(path HDPath) (ed25519.PrivateKey, error) {
+	if !IsPathCompletelyHardened(path) {
+		return nil, errors.New("the device only derives hardened paths")
+	}
+	key, err := smbip32.Derive(HDPathToString(path), l.seed)
+	if err != nil {
+		return nil, err
+	}
+	return ed25519.PrivateKey(key), nil
+}
+
+func (l *FakeLedger) PublicKey(path HDPath, confirm bool) ([]byte, error) {
+	key, err := l.key(path)
+	if err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if confirm {
+		if l.Reject {
+			return nil, ErrRejectedOnDevice
+		}
+		l.Confirms++
+	}
+	return key.Public().(ed25519.PublicKey), nil
+}
+
+// SignTx signs the transaction after decoding it. Like the Spacemesh app, it refuses to sign
+// transactions it can't decode, which it couldn't show to the user.
+func (l *FakeLedger) SignTx(path HDPath, genesisID types.Hash20, unsigned []byte) ([]byte, error) {
+	key, err := l.key(path)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := decodeFakeLedgerTx(unsigned)
+	if err != nil {
+		return nil, err
+	}
+	tx.Path = path
+	tx.GenesisID = genesisID
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Txs = append(l.Txs, tx)
+	if l.Reject {
+		return nil, ErrRejectedOnDevice
+	}
+	return ed25519.Sign(key, core.SigningBody(genesisID[:], unsigned)), nil
+}
+
+func (l *FakeLedger) SignMessage(path HDPath, msg []byte) ([]byte, error) {
+	key, err := l.key(path)
+	if err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Messages = append(l.Messages, bytes.Clone(msg))
+	if l.Reject {
+		return nil, ErrRejectedOnDevice
+	}
+	return ed25519.Sign(key, MessageSigningBody(msg)), nil
+}
+
+// decodeFakeLedgerTx decodes the fields of an unsigned transaction that the device shows.
+func decodeFakeLedgerTx(unsigned []byte) (FakeLedgerTx, error) {
+	dec := scale.NewDecoder(bytes.NewReader(unsigned))
+	var err error
+	decode := func(fields ...scale.Decodable) {
+		for _, f := range fields {
+			if err == nil {
+				_, err = f.DecodeScale(dec)
+			}
+		}
+	}
+	var (
+		tx              FakeLedgerTx
+		version, method scale.U8
+		payload         core.Payload
+	)
+	decode(&version, &tx.Principal, &method)
+	tx.Method = uint8(method)
+	switch tx.Method {
+	case core.MethodSpawn:
+		var template core.Address
+		decode(&template, &payload)
+	case core.MethodSpend:
+		var args walletTemplate.SpendArguments
+		decode(&payload, &args)
+		tx.To, tx.Amount = args.Destination, args.Amount
+	case vesting.MethodDrainVault:
+		var args vesting.DrainVaultArguments
+		decode(&payload, &args)
+		tx.Vault, tx.To, tx.Amount = args.Vault, args.Destination, args.Amount
+	default:
+		return tx, fmt.Errorf("the device can't show transactions with method %d", tx.Method)
+	}
+	if err != nil {
+		return tx, fmt.Errorf("the device can't decode the transaction: %w", err)
+	}
+	return tx, nil
+}
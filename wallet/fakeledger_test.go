@@ -0,0 +1,134 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/genvm/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeLedgerHoldsMnemonicKeys(t *testing.T) {
+	sw, err := NewMultiWalletRandomMnemonic("", 3)
+	require.NoError(t, err)
+	device, err := NewFakeLedgerFromMnemonic(sw.Mnemonic())
+	require.NoError(t, err)
+	lw, err := NewMultiWalletFromLedger(device, 3)
+	require.NoError(t, err)
+
+	require.Equal(t, sw.Secrets.MasterKeypair.Public, lw.Secrets.MasterKeypair.Public)
+	for i := range sw.Secrets.Accounts {
+		require.Equal(t, sw.Secrets.Accounts[i].Public, lw.Secrets.Accounts[i].Public)
+		require.Equal(t, sw.Secrets.Accounts[i].Path, lw.Secrets.Accounts[i].Path)
+	}
+
+	_, err = NewFakeLedgerFromMnemonic("not a mnemonic")
+	require.Error(t, err)
+}
+
+func TestFakeLedgerReject(t *testing.T) {
+	device := newFakeLedger(t)
+	device.Reject = true
+	_, err := NewMultiWalletFromLedger(device, 1)
+	require.ErrorIs(t, err, ErrRejectedOnDevice)
+	require.Zero(t, device.Confirms)
+}
+
+// A Ledger wallet can be created, written and read back without a device connected to the machine.
+func TestLedgerWalletRoundTrip(t *testing.T) {
+	device := newFakeLedger(t)
+	w, err := NewMultiWalletFromLedger(device, 2)
+	require.NoError(t, err)
+
+	key := newTestKey(t, WithRandomSalt(), WithKDFPassword(&PBKDF2{Iterations: 1000}, []byte("password")))
+	var buf bytes.Buffer
+	require.NoError(t, key.Export(&buf, w))
+	key = newTestKey(t, WithPasswordOnly([]byte("password")))
+	w2, err := key.Open(&buf, false)
+	require.NoError(t, err)
+	require.True(t, w2.IsLedger())
+	require.Equal(t, "(none)", w2.Mnemonic())
+	require.Len(t, w2.Secrets.Accounts, 2)
+	for i, a := range w2.Secrets.Accounts {
+		require.Equal(t, w.Secrets.Accounts[i].Public, a.Public)
+		require.Equal(t, w.Secrets.Accounts[i].Path, a.Path)
+		require.True(t, a.IsLedger())
+	}
+	require.Equal(t, w.Meta.LedgerFingerprint, w2.Meta.LedgerFingerprint)
+	require.NoError(t, w2.CheckLedger(device))
+}
+
+// A Ledger wallet can be created, written, read back and used to sign transactions and messages,
+// with the device showing what it signs.
+func TestLedgerWalletSignFlow(t *testing.T) {
+	// PubkeyToAddress sets the network HRP
+	hrp := types.NetworkHRP()
+	t.Cleanup(func() { types.SetNetworkHRP(hrp) })
+
+	device := newFakeLedger(t)
+	created, err := NewMultiWalletFromLedger(device, 2)
+	require.NoError(t, err)
+	key := newTestKey(t, WithRandomSalt(), WithKDFPassword(&PBKDF2{Iterations: 1000}, []byte("password")))
+	var buf bytes.Buffer
+	require.NoError(t, key.Export(&buf, created))
+	key = newTestKey(t, WithPasswordOnly([]byte("password")))
+	w, err := key.Open(&buf, false)
+	require.NoError(t, err)
+	require.NoError(t, w.CheckLedger(device))
+
+	kp := w.Secrets.Accounts[1]
+	signer, err := kp.Signer(device)
+	require.NoError(t, err)
+
+	genesisID := types.Hash20{1, 2, 3}
+	to := types.GenerateAddress([]byte("recipient"))
+	unsigned := unsignedSpend(kp.Public, to, 12345)
+	sig, err := signer.SignTx(genesisID, unsigned)
+	require.NoError(t, err)
+	require.True(t, ed25519.Verify(ed25519.PublicKey(kp.Public), core.SigningBody(genesisID[:], unsigned), sig))
+	require.Equal(t, []FakeLedgerTx{{
+		Path:      kp.Path,
+		GenesisID: genesisID,
+		Principal: walletPrincipal(kp.Public),
+		Method:    core.MethodSpend,
+		To:        to,
+		Amount:    12345,
+	}}, device.Txs)
+
+	m, err := SignMessage(signer, []byte("I control this address"), "stest")
+	require.NoError(t, err)
+	require.NoError(t, m.Verify())
+	require.NoError(t, m.CheckPublicKey(hex.EncodeToString(kp.Public)))
+	require.Equal(t, [][]byte{[]byte("I control this address")}, device.Messages)
+}
+
+// When the user rejects a request on the device, it was still shown, but nothing is signed.
+func TestLedgerWalletRejectSigning(t *testing.T) {
+	// PubkeyToAddress sets the network HRP
+	hrp := types.NetworkHRP()
+	t.Cleanup(func() { types.SetNetworkHRP(hrp) })
+
+	device := newFakeLedger(t)
+	w, err := NewMultiWalletFromLedger(device, 1)
+	require.NoError(t, err)
+	kp := w.Secrets.Accounts[0]
+	signer, err := kp.Signer(device)
+	require.NoError(t, err)
+
+	device.Reject = true
+	to := types.GenerateAddress([]byte("recipient"))
+	_, err = signer.SignTx(types.Hash20{}, unsignedSpend(kp.Public, to, 1))
+	require.ErrorIs(t, err, ErrRejectedOnDevice)
+	require.Len(t, device.Txs, 1)
+	require.Equal(t, to, device.Txs[0].To)
+	_, err = SignMessage(signer, []byte("msg"), "stest")
+	require.ErrorIs(t, err, ErrRejectedOnDevice)
+	require.Len(t, device.Messages, 1)
+
+	device.Reject = false
+	_, err = signer.SignTx(types.Hash20{}, unsignedSpend(kp.Public, to, 1))
+	require.NoError(t, err)
+}
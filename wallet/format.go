@@ -0,0 +1,165 @@
+package wallet
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Versions of the wallet file format.
+//
+// Version 1 files have no version field. They were written with the PBKDF2 hash recorded as SHA-256
+// and the derived key length recorded as 256, although the key was always derived using
+// HMAC-SHA512 as a 32-byte key. Version 2 files record the actual hash and key length.
+//
+// In version 3 files, the metadata and the cipher and KDF parameters are authenticated as
+// associated data of the encrypted secrets, so they can't be modified without detection. The file
+// also contains a key check value, which tells a wrong password apart from a modified file.
+const (
+	WalletFileVersion1 = 1
+	WalletFileVersion2 = 2
+	WalletFileVersion3 = 3
+
+	// WalletFileVersion is the version of the files written by Export.
+	WalletFileVersion = WalletFileVersion3
+)
+
+// Cipher is the cipher used to encrypt the wallet secrets, as stored in the cipher field of the wallet
+// file.
+const Cipher = "AES-GCM"
+
+// gcmNonceSize is the size of the nonce used with AES-GCM.
+const gcmNonceSize = 12
+
+// keyCheckLen is the length of the key check value.
+const keyCheckLen = 16
+
+// legacyPbkdf2Dklen is the derived key length recorded in version 1 files.
+const legacyPbkdf2Dklen = 256
+
+// ErrUnsupportedVersion is returned when opening a wallet file written in a newer format than this
+// version of smcli supports.
+var ErrUnsupportedVersion = errors.New("unsupported wallet file version")
+
+// ErrWrongPassword is returned when opening a wallet file with the wrong password.
+var ErrWrongPassword = errors.New("wrong password")
+
+// ErrTampered is returned when the metadata or parameters of a wallet file don't match those it was
+// encrypted with.
+var ErrTampered = errors.New("wallet file metadata or parameters have been modified")
+
+// pbkdf2HashName is the name of Pbkdf2HashFunc, as stored in the hash field of the wallet file.
+var pbkdf2HashName = crypto.SHA512.String()
+
+// FileVersion returns the format version of the wallet file. Files without a version field are
+// version 1.
+func (ew *EncryptedWalletFile) FileVersion() int {
+	if ew.Version == 0 {
+		return WalletFileVersion1
+	}
+	return ew.Version
+}
+
+// ReadFileVersion reads a wallet file and returns its format version. The file isn't decrypted, so
+// no password is needed.
+func ReadFileVersion(file io.Reader) (int, error) {
+	ew := &EncryptedWalletFile{}
+	if err := json.NewDecoder(file).Decode(ew); err != nil {
+		return 0, err
+	}
+	return ew.FileVersion(), nil
+}
+
+// validate checks the version, cipher and KDF fields of the wallet file before it's decrypted.
+func (ew *EncryptedWalletFile) validate() error {
+	version := ew.FileVersion()
+	if version < WalletFileVersion1 || version > WalletFileVersion {
+		return fmt.Errorf("%w %d: the newest version supported is %d, try upgrading smcli",
+			ErrUnsupportedVersion, ew.Version, WalletFileVersion)
+	}
+
+	s := &ew.Secrets
+	if s.Cipher != Cipher {
+		return fmt.Errorf("unsupported cipher %q in wallet file", s.Cipher)
+	}
+	if len(s.CipherParams.IV) != gcmNonceSize {
+		return fmt.Errorf("invalid IV length %d in wallet file", len(s.CipherParams.IV))
+	}
+	if len(s.CipherText) == 0 {
+		return errors.New("wallet file has no ciphertext")
+	}
+	switch {
+	case version >= WalletFileVersion3 && len(s.KeyCheck) != keyCheckLen:
+		return fmt.Errorf("invalid key check length %d in wallet file", len(s.KeyCheck))
+	case version < WalletFileVersion3 && len(s.KeyCheck) != 0:
+		// the version was downgraded to skip authentication
+		return fmt.Errorf("%w: key check in version %d file", ErrTampered, version)
+	}
+	if len(s.KDFParams.Salt) != Pbkdf2SaltBytesLen {
+		return fmt.Errorf("%w: invalid salt length %d in wallet file", ErrBadSalt, len(s.KDFParams.Salt))
+	}
+
+	p := &s.KDFParams
+	switch strings.ToUpper(s.KDF) {
+	case KDFPbkdf2, "":
+		if version == WalletFileVersion1 {
+			// version 1 files misreport the hash and key length, see above
+			if p.Hash != crypto.SHA256.String() && p.Hash != pbkdf2HashName {
+				return fmt.Errorf("unsupported PBKDF2 hash %q in wallet file", p.Hash)
+			}
+			if p.DKLen != legacyPbkdf2Dklen && p.DKLen != EncKeyLen {
+				return fmt.Errorf("unsupported derived key length %d in wallet file", p.DKLen)
+			}
+			return nil
+		}
+		if p.Hash != pbkdf2HashName {
+			return fmt.Errorf("unsupported PBKDF2 hash %q in wallet file", p.Hash)
+		}
+	case KDFArgon2id, KDFScrypt:
+		if p.Hash != "" {
+			return fmt.Errorf("unexpected hash %q for KDF %s in wallet file", p.Hash, s.KDF)
+		}
+	default:
+		return fmt.Errorf("%w %q in wallet file", ErrUnsupportedKDF, s.KDF)
+	}
+	if p.DKLen != EncKeyLen {
+		return fmt.Errorf("unsupported derived key length %d in wallet file", p.DKLen)
+	}
+	return nil
+}
+
+// authenticated reports whether the metadata and parameters of the wallet file are authenticated.
+func (ew *EncryptedWalletFile) authenticated() bool {
+	return ew.FileVersion() >= WalletFileVersion3
+}
+
+// associatedData returns the associated data that authenticates the metadata, cipher and KDF
+// parameters of the wallet file. It's the JSON encoding of these fields, which is deterministic.
+func (ew *EncryptedWalletFile) associatedData() ([]byte, error) {
+	return json.Marshal(&struct {
+		Version   int            `json:"version"`
+		Meta      walletMetadata `json:"meta"`
+		Cipher    string         `json:"cipher"`
+		KDF       string         `json:"kdf"`
+		KDFParams kdfParams      `json:"kdfparams"`
+	}{
+		Version:   ew.Version,
+		Meta:      ew.Meta,
+		Cipher:    ew.Secrets.Cipher,
+		KDF:       ew.Secrets.KDF,
+		KDFParams: ew.Secrets.KDFParams,
+	})
+}
+
+// keyCheck returns a value derived from the key, which is stored in the wallet file to check the
+// password before decrypting it.
+func keyCheck(key []byte) []byte {
+	mac := hmac.New(sha512.New, key)
+	mac.Write([]byte("smcli wallet key check"))
+	return mac.Sum(nil)[:keyCheckLen]
+}
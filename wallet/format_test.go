@@ -0,0 +1,167 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// exportMap exports a new wallet encrypted with password and returns the file as a generic map so
+// that tests can alter its fields.
+func exportMap(t *testing.T, password []byte) (*Wallet, map[string]any) {
+	t.Helper()
+	w, err := NewMultiWalletRandomMnemonic("", 2)
+	require.NoError(t, err)
+	wKey := newTestKey(t, WithRandomSalt(), WithKDFPassword(&PBKDF2{Iterations: 1000}, password))
+	buf := &bytes.Buffer{}
+	require.NoError(t, wKey.Export(buf, w))
+	m := map[string]any{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+	return w, m
+}
+
+func openMap(t *testing.T, m map[string]any, password []byte) (*Wallet, error) {
+	t.Helper()
+	b, err := json.Marshal(m)
+	require.NoError(t, err)
+	wKey := newTestKey(t, WithPasswordOnly(password))
+	return wKey.Open(bytes.NewReader(b), false)
+}
+
+func TestExportWritesCurrentVersion(t *testing.T) {
+	_, m := exportMap(t, []byte("password"))
+	require.EqualValues(t, WalletFileVersion, m["version"])
+	crypto := m["crypto"].(map[string]any)
+	require.Equal(t, Cipher, crypto["cipher"])
+	params := crypto["kdfparams"].(map[string]any)
+	require.Equal(t, "SHA-512", params["hash"])
+	require.EqualValues(t, EncKeyLen, params["dklen"])
+	require.EqualValues(t, 1000, params["iterations"])
+
+	b, err := json.Marshal(m)
+	require.NoError(t, err)
+	version, err := ReadFileVersion(bytes.NewReader(b))
+	require.NoError(t, err)
+	require.Equal(t, WalletFileVersion, version)
+}
+
+// exportLegacy writes w the way version 1 files were written: without a version field, with the
+// legacy hash and key length, and without associated data.
+func exportLegacy(t *testing.T, w *Wallet, password []byte) []byte {
+	t.Helper()
+	wKey := newTestKey(t, WithRandomSalt(), WithKDFPassword(&PBKDF2{Iterations: 1000}, password))
+	plaintext, err := json.Marshal(w.Secrets)
+	require.NoError(t, err)
+	ciphertext, nonce, err := wKey.encrypt(plaintext, nil)
+	require.NoError(t, err)
+	ew := &EncryptedWalletFile{
+		Meta: w.Meta,
+		Secrets: walletSecretsEncrypted{
+			Cipher:     Cipher,
+			CipherText: ciphertext,
+			KDF:        KDFPbkdf2,
+			KDFParams: kdfParams{
+				DKLen:      legacyPbkdf2Dklen,
+				Hash:       "SHA-256",
+				Salt:       wKey.salt,
+				Iterations: 1000,
+			},
+		},
+	}
+	ew.Secrets.CipherParams.IV = nonce
+	b, err := json.Marshal(ew)
+	require.NoError(t, err)
+	return b
+}
+
+func TestOpenLegacyFile(t *testing.T) {
+	password := []byte("password")
+	w, err := NewMultiWalletRandomMnemonic("", 2)
+	require.NoError(t, err)
+	b := exportLegacy(t, w, password)
+
+	version, err := ReadFileVersion(bytes.NewReader(b))
+	require.NoError(t, err)
+	require.Equal(t, WalletFileVersion1, version)
+
+	wKey := newTestKey(t, WithPasswordOnly(password))
+	w2, err := wKey.Open(bytes.NewReader(b), false)
+	require.NoError(t, err)
+	require.Equal(t, w.Secrets.Accounts, w2.Secrets.Accounts)
+
+	// the metadata of legacy files isn't authenticated
+	m := map[string]any{}
+	require.NoError(t, json.Unmarshal(b, &m))
+	m["meta"].(map[string]any)["displayName"] = "Modified"
+	w2, err = openMap(t, m, password)
+	require.NoError(t, err)
+	require.Equal(t, "Modified", w2.Meta.DisplayName)
+}
+
+func TestOpenDetectsTampering(t *testing.T) {
+	password := []byte("password")
+	for _, tc := range []struct {
+		name   string
+		modify func(m, crypto, params map[string]any)
+	}{
+		{"display name", func(m, _, _ map[string]any) { m["meta"].(map[string]any)["displayName"] = "Modified" }},
+		{"genesis id", func(m, _, _ map[string]any) { m["meta"].(map[string]any)["genesisID"] = "00" }},
+		{"passphrase", func(m, _, _ map[string]any) { m["meta"].(map[string]any)["hasPassphrase"] = true }},
+		{"version", func(m, _, _ map[string]any) { m["version"] = WalletFileVersion2 }},
+		{"no version", func(m, _, _ map[string]any) { delete(m, "version") }},
+		{"kdf case", func(_, crypto, _ map[string]any) { crypto["kdf"] = "pbkdf2" }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, m := exportMap(t, password)
+			crypto := m["crypto"].(map[string]any)
+			tc.modify(m, crypto, crypto["kdfparams"].(map[string]any))
+			_, err := openMap(t, m, password)
+			require.ErrorIs(t, err, ErrTampered)
+		})
+	}
+
+	// changing the KDF parameters changes the key, so it's detected as a wrong password
+	_, m := exportMap(t, password)
+	m["crypto"].(map[string]any)["kdfparams"].(map[string]any)["iterations"] = 1001
+	_, err := openMap(t, m, password)
+	require.ErrorIs(t, err, ErrWrongPassword)
+
+	_, m = exportMap(t, password)
+	_, err = openMap(t, m, []byte("wrong"))
+	require.ErrorIs(t, err, ErrWrongPassword)
+}
+
+func TestOpenValidatesFile(t *testing.T) {
+	password := []byte("password")
+	for _, tc := range []struct {
+		name   string
+		modify func(m, crypto, params map[string]any)
+	}{
+		{"newer version", func(m, _, _ map[string]any) { m["version"] = WalletFileVersion + 1 }},
+		{"cipher", func(_, crypto, _ map[string]any) { crypto["cipher"] = "AES-CBC" }},
+		{"iv", func(_, crypto, _ map[string]any) { crypto["cipherParams"] = map[string]any{"iv": "0102"} }},
+		{"kdf", func(_, crypto, _ map[string]any) { crypto["kdf"] = "BCRYPT" }},
+		{"hash", func(_, _, params map[string]any) { params["hash"] = "SHA-256" }},
+		{"dklen", func(_, _, params map[string]any) { params["dklen"] = legacyPbkdf2Dklen }},
+		{"salt", func(_, _, params map[string]any) { params["salt"] = "0102" }},
+		{"legacy hash", func(m, _, params map[string]any) {
+			delete(m, "version")
+			params["hash"] = "MD5"
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, m := exportMap(t, password)
+			crypto := m["crypto"].(map[string]any)
+			tc.modify(m, crypto, crypto["kdfparams"].(map[string]any))
+			_, err := openMap(t, m, password)
+			require.Error(t, err)
+		})
+	}
+
+	_, m := exportMap(t, password)
+	m["version"] = WalletFileVersion + 1
+	_, err := openMap(t, m, password)
+	require.ErrorIs(t, err, ErrUnsupportedVersion)
+}
@@ -0,0 +1,255 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/xdg-go/pbkdf2"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Names of the supported KDFs, as stored in the kdf field of the wallet file.
+const (
+	KDFPbkdf2   = "PBKDF2"
+	KDFArgon2id = "ARGON2ID"
+	KDFScrypt   = "SCRYPT"
+)
+
+// https://cheatsheetseries.owasp.org/cheatsheets/Password_Storage_Cheat_Sheet.html#argon2id
+// We use the second recommended option of RFC 9106, which uses more memory than OWASP's minimum.
+const (
+	Argon2idTime    = 3
+	Argon2idMemory  = 64 * 1024 // KiB
+	Argon2idThreads = 4
+)
+
+// https://cheatsheetseries.owasp.org/cheatsheets/Password_Storage_Cheat_Sheet.html#scrypt
+const (
+	ScryptN = 1 << 17
+	ScryptR = 8
+	ScryptP = 1
+)
+
+// Upper bounds on the cost parameters accepted when opening a wallet file, so that a crafted file
+// can't make us allocate an unreasonable amount of memory or spend hours deriving the key. They're
+// far above the recommended values.
+const (
+	maxPbkdf2Iterations = 100 * Pbkdf2Iterations
+	maxArgon2idTime     = 100 * Argon2idTime
+	maxArgon2idMemory   = 4 * 1024 * 1024 // KiB
+	maxScryptMemory     = 4 << 30         // bytes
+	maxScryptP          = 64
+)
+
+var (
+	// ErrUnsupportedKDF is returned for KDFs other than those listed above.
+	ErrUnsupportedKDF = errors.New("unsupported KDF")
+
+	// ErrInvalidKDFParams is returned for KDF cost parameters that are invalid or out of bounds.
+	ErrInvalidKDFParams = errors.New("invalid KDF parameters")
+)
+
+// KDF derives the key used to encrypt a wallet file from a password and salt. Its parameters are
+// stored in the kdfparams field of the wallet file so that the same key can be derived when opening
+// it.
+type KDF interface {
+	// Name returns the name of the KDF stored in the wallet file.
+	Name() string
+
+	// Validate checks the cost parameters, without deriving a key. It returns ErrInvalidKDFParams if
+	// they're invalid or out of bounds.
+	Validate() error
+
+	// DeriveKey derives a key of EncKeyLen bytes.
+	DeriveKey(password, salt []byte) ([]byte, error)
+
+	// params returns the parameters of the KDF stored in the wallet file, except for the salt.
+	params() kdfParams
+}
+
+// kdfParams are the KDF parameters stored in the wallet file. Only the fields used by the KDF
+// named in the file are set.
+type kdfParams struct {
+	DKLen int                  `json:"dklen"`
+	Hash  string               `json:"hash,omitempty"`
+	Salt  hexEncodedCiphertext `json:"salt"`
+
+	// PBKDF2
+	Iterations int `json:"iterations,omitempty"`
+
+	// Argon2id
+	Time    uint32 `json:"time,omitempty"`
+	Memory  uint32 `json:"memory,omitempty"`
+	Threads uint8  `json:"threads,omitempty"`
+
+	// scrypt
+	N int `json:"n,omitempty"`
+	R int `json:"r,omitempty"`
+	P int `json:"p,omitempty"`
+}
+
+// PBKDF2 derives keys using PBKDF2 with HMAC-SHA512.
+type PBKDF2 struct {
+	Iterations int
+}
+
+// NewPBKDF2 returns PBKDF2 with the recommended number of iterations.
+func NewPBKDF2() *PBKDF2 {
+	return &PBKDF2{Iterations: Pbkdf2Iterations}
+}
+
+func (k *PBKDF2) Name() string {
+	return KDFPbkdf2
+}
+
+func (k *PBKDF2) Validate() error {
+	if k.Iterations <= 0 || k.Iterations > maxPbkdf2Iterations {
+		return fmt.Errorf("%w: PBKDF2 iterations must be between 1 and %d", ErrInvalidKDFParams, maxPbkdf2Iterations)
+	}
+	return nil
+}
+
+func (k *PBKDF2) DeriveKey(password, salt []byte) ([]byte, error) {
+	if err := k.Validate(); err != nil {
+		return nil, err
+	}
+	return pbkdf2.Key(password, salt, k.Iterations, EncKeyLen, Pbkdf2HashFunc), nil
+}
+
+func (k *PBKDF2) params() kdfParams {
+	return kdfParams{
+		DKLen:      EncKeyLen,
+		Hash:       pbkdf2HashName,
+		Iterations: k.Iterations,
+	}
+}
+
+// Argon2id derives keys using Argon2id. Memory is given in KiB.
+type Argon2id struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+}
+
+// NewArgon2id returns Argon2id with the recommended cost parameters.
+func NewArgon2id() *Argon2id {
+	return &Argon2id{Time: Argon2idTime, Memory: Argon2idMemory, Threads: Argon2idThreads}
+}
+
+func (k *Argon2id) Name() string {
+	return KDFArgon2id
+}
+
+func (k *Argon2id) Validate() error {
+	switch {
+	case k.Time == 0 || k.Time > maxArgon2idTime:
+		return fmt.Errorf("%w: Argon2id time must be between 1 and %d", ErrInvalidKDFParams, maxArgon2idTime)
+	case k.Threads == 0:
+		return fmt.Errorf("%w: Argon2id threads must be at least 1", ErrInvalidKDFParams)
+	case k.Memory < 8*uint32(k.Threads) || k.Memory > maxArgon2idMemory:
+		return fmt.Errorf("%w: Argon2id memory must be between %d and %d KiB", ErrInvalidKDFParams,
+			8*uint32(k.Threads), maxArgon2idMemory)
+	}
+	return nil
+}
+
+func (k *Argon2id) DeriveKey(password, salt []byte) ([]byte, error) {
+	if err := k.Validate(); err != nil {
+		return nil, err
+	}
+	return argon2.IDKey(password, salt, k.Time, k.Memory, k.Threads, EncKeyLen), nil
+}
+
+func (k *Argon2id) params() kdfParams {
+	return kdfParams{
+		DKLen:   EncKeyLen,
+		Time:    k.Time,
+		Memory:  k.Memory,
+		Threads: k.Threads,
+	}
+}
+
+// Scrypt derives keys using scrypt.
+type Scrypt struct {
+	N int
+	R int
+	P int
+}
+
+// NewScrypt returns scrypt with the recommended cost parameters.
+func NewScrypt() *Scrypt {
+	return &Scrypt{N: ScryptN, R: ScryptR, P: ScryptP}
+}
+
+func (k *Scrypt) Name() string {
+	return KDFScrypt
+}
+
+func (k *Scrypt) Validate() error {
+	switch {
+	case k.N <= 1 || k.N&(k.N-1) != 0:
+		return fmt.Errorf("%w: scrypt N must be a power of 2 greater than 1", ErrInvalidKDFParams)
+	case k.R <= 0:
+		return fmt.Errorf("%w: scrypt r must be at least 1", ErrInvalidKDFParams)
+	case k.P <= 0 || k.P > maxScryptP:
+		return fmt.Errorf("%w: scrypt p must be between 1 and %d", ErrInvalidKDFParams, maxScryptP)
+	case int64(128)*int64(k.R)*(int64(k.N)+int64(k.P)) > maxScryptMemory:
+		return fmt.Errorf("%w: scrypt parameters require more than %d GiB of memory", ErrInvalidKDFParams,
+			maxScryptMemory>>30)
+	}
+	return nil
+}
+
+func (k *Scrypt) DeriveKey(password, salt []byte) ([]byte, error) {
+	if err := k.Validate(); err != nil {
+		return nil, err
+	}
+	return scrypt.Key(password, salt, k.N, k.R, k.P, EncKeyLen)
+}
+
+func (k *Scrypt) params() kdfParams {
+	return kdfParams{
+		DKLen: EncKeyLen,
+		N:     k.N,
+		R:     k.R,
+		P:     k.P,
+	}
+}
+
+// ParseKDF returns the KDF with the given name (case-insensitive) and its recommended cost
+// parameters.
+func ParseKDF(name string) (KDF, error) {
+	switch strings.ToUpper(name) {
+	case KDFPbkdf2:
+		return NewPBKDF2(), nil
+	case KDFArgon2id:
+		return NewArgon2id(), nil
+	case KDFScrypt:
+		return NewScrypt(), nil
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnsupportedKDF, name)
+	}
+}
+
+// kdfFromFile returns the KDF described by the kdf and kdfparams fields of a wallet file. Files
+// written before the KDF was recorded use PBKDF2. Files whose cost parameters are out of bounds are
+// rejected.
+func kdfFromFile(name string, params kdfParams) (KDF, error) {
+	var kdf KDF
+	switch strings.ToUpper(name) {
+	case KDFPbkdf2, "":
+		kdf = &PBKDF2{Iterations: params.Iterations}
+	case KDFArgon2id:
+		kdf = &Argon2id{Time: params.Time, Memory: params.Memory, Threads: params.Threads}
+	case KDFScrypt:
+		kdf = &Scrypt{N: params.N, R: params.R, P: params.P}
+	default:
+		return nil, fmt.Errorf("%w %q in wallet file", ErrUnsupportedKDF, name)
+	}
+	if err := kdf.Validate(); err != nil {
+		return nil, fmt.Errorf("wallet file: %w", err)
+	}
+	return kdf, nil
+}
@@ -0,0 +1,93 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKDFRoundTrip(t *testing.T) {
+	for _, kdf := range []KDF{
+		&PBKDF2{Iterations: 1000},
+		&Argon2id{Time: 1, Memory: 64, Threads: 1},
+		&Scrypt{N: 1 << 10, R: 8, P: 1},
+	} {
+		t.Run(kdf.Name(), func(t *testing.T) {
+			w, err := NewMultiWalletRandomMnemonic("", 1)
+			require.NoError(t, err)
+			wKey := newTestKey(t, WithRandomSalt(), WithKDFPassword(kdf, []byte("password")))
+
+			buf := &bytes.Buffer{}
+			require.NoError(t, wKey.Export(buf, w))
+
+			// the KDF and its parameters are stored in the file
+			ew := &EncryptedWalletFile{}
+			require.NoError(t, json.Unmarshal(buf.Bytes(), ew))
+			require.Equal(t, kdf.Name(), ew.Secrets.KDF)
+			stored, err := kdfFromFile(ew.Secrets.KDF, ew.Secrets.KDFParams)
+			require.NoError(t, err)
+			require.Equal(t, kdf, stored)
+
+			wKey2 := newTestKey(t, WithPasswordOnly([]byte("password")))
+			w2, err := wKey2.Open(bytes.NewReader(buf.Bytes()), false)
+			require.NoError(t, err)
+			require.Equal(t, w.Secrets.Accounts, w2.Secrets.Accounts)
+			require.Equal(t, kdf, wKey2.KDF())
+
+			wKey3 := newTestKey(t, WithPasswordOnly([]byte("wrong")))
+			_, err = wKey3.Open(bytes.NewReader(buf.Bytes()), false)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestKDFFromFile(t *testing.T) {
+	// files written before the KDF was recorded use PBKDF2
+	kdf, err := kdfFromFile("", kdfParams{Iterations: 5})
+	require.NoError(t, err)
+	require.Equal(t, &PBKDF2{Iterations: 5}, kdf)
+
+	_, err = kdfFromFile("bcrypt", kdfParams{})
+	require.ErrorIs(t, err, ErrUnsupportedKDF)
+
+	// unreasonable parameters are rejected rather than allocating lots of memory or spending hours
+	// deriving the key
+	for _, c := range []struct {
+		name   string
+		params kdfParams
+	}{
+		{KDFPbkdf2, kdfParams{Iterations: 0}},
+		{KDFPbkdf2, kdfParams{Iterations: maxPbkdf2Iterations + 1}},
+		{KDFArgon2id, kdfParams{Time: 1, Memory: 1 << 30, Threads: 1}},
+		{KDFArgon2id, kdfParams{Time: maxArgon2idTime + 1, Memory: Argon2idMemory, Threads: 1}},
+		{KDFScrypt, kdfParams{N: 1 << 30, R: 8, P: 1}},
+		{KDFScrypt, kdfParams{N: 1 << 10, R: 8, P: maxScryptP + 1}},
+		{KDFScrypt, kdfParams{N: 1000, R: 8, P: 1}},
+	} {
+		_, err = kdfFromFile(c.name, c.params)
+		require.ErrorIs(t, err, ErrInvalidKDFParams, c)
+	}
+	kdf, err = kdfFromFile(KDFArgon2id, kdfParams{Time: maxArgon2idTime, Memory: Argon2idMemory, Threads: 1})
+	require.NoError(t, err)
+	require.Equal(t, &Argon2id{Time: maxArgon2idTime, Memory: Argon2idMemory, Threads: 1}, kdf)
+
+	// the same checks are made before deriving a key
+	_, err = (&PBKDF2{Iterations: maxPbkdf2Iterations + 1}).DeriveKey([]byte("password"), []byte("salt"))
+	require.ErrorIs(t, err, ErrInvalidKDFParams)
+}
+
+func TestParseKDF(t *testing.T) {
+	for name, want := range map[string]KDF{
+		"pbkdf2":   NewPBKDF2(),
+		"argon2id": NewArgon2id(),
+		"SCRYPT":   NewScrypt(),
+	} {
+		kdf, err := ParseKDF(name)
+		require.NoError(t, err)
+		require.Equal(t, want, kdf)
+	}
+	_, err := ParseKDF("md5")
+	require.Error(t, err)
+}
@@ -0,0 +1,170 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	ledger "github.com/spacemeshos/smkeys/remote-wallet"
+)
+
+// LedgerVendorID is the USB vendor ID of Ledger devices.
+const LedgerVendorID = 0x2c97
+
+var (
+	// ErrWrongLedger is returned when the connected Ledger device doesn't hold the keys of the wallet.
+	ErrWrongLedger = errors.New("the Ledger device doesn't hold the keys of this wallet")
+
+	// ErrRejectedOnDevice is returned when the user rejects a request on the Ledger device.
+	ErrRejectedOnDevice = errors.New("request rejected on the device")
+)
+
+// LedgerInfo identifies a connected Ledger device.
+type LedgerInfo struct {
+	// Path is the HID device path, which selects the device when passed to SmkeysLedger. On Linux,
+	// it's the hidraw device node, e.g. /dev/hidraw1.
+	Path      string `json:"path" yaml:"path"`
+	Model     string `json:"model" yaml:"model"`
+	ProductID uint16 `json:"productID" yaml:"productID"`
+	Serial    string `json:"serial,omitempty" yaml:"serial,omitempty"`
+}
+
+// ListLedgers returns the connected Ledger devices. It's only supported on Linux, where the devices
+// are found using sysfs; elsewhere, it returns an error.
+func ListLedgers() ([]LedgerInfo, error) {
+	return listLedgers()
+}
+
+// ledgerModel returns the model name of a Ledger device given its USB product ID. Current firmware
+// uses the high byte of the product ID to identify the model; older firmware used small IDs.
+func ledgerModel(productID uint16) string {
+	switch productID {
+	case 0x0001:
+		return "Nano S"
+	case 0x0004:
+		return "Nano X"
+	case 0x0005:
+		return "Nano S Plus"
+	}
+	switch productID >> 8 {
+	case 0x10:
+		return "Nano S"
+	case 0x40:
+		return "Nano X"
+	case 0x50:
+		return "Nano S Plus"
+	case 0x60:
+		return "Stax"
+	case 0x70:
+		return "Flex"
+	default:
+		return "Unknown (0x" + strconv.FormatUint(uint64(productID), 16) + ")"
+	}
+}
+
+// LedgerFingerprint returns the fingerprint of a Ledger device, derived from the master public key
+// of the Spacemesh app on it. It's stored in the wallet file to tell whether the wallet's device is
+// connected, without revealing the master public key.
+func LedgerFingerprint(masterPublic []byte) string {
+	h := sha256.Sum256(masterPublic)
+	return hex.EncodeToString(h[:8])
+}
+
+// ReadLedgerFingerprint reads the master public key from the device and returns its fingerprint.
+func ReadLedgerFingerprint(device LedgerDevice) (string, error) {
+	pub, err := device.PublicKey(DefaultPath(), false)
+	if err != nil {
+		return "", err
+	}
+	return LedgerFingerprint(pub), nil
+}
+
+// CheckLedger checks that the device holds the keys of the wallet, by comparing its master public
+// key to the fingerprint stored in the wallet file, or to the wallet's master public key for files
+// written without a fingerprint. It returns ErrWrongLedger if it doesn't.
+func (w *Wallet) CheckLedger(device LedgerDevice) error {
+	if !w.IsLedger() {
+		return errors.New("not a Ledger wallet")
+	}
+	pub, err := device.PublicKey(DefaultPath(), false)
+	if err != nil {
+		return err
+	}
+	if w.Meta.LedgerFingerprint != "" {
+		if fp := LedgerFingerprint(pub); fp != w.Meta.LedgerFingerprint {
+			return fmt.Errorf("%w: device fingerprint %s, expected %s", ErrWrongLedger, fp, w.Meta.LedgerFingerprint)
+		}
+		return nil
+	}
+	if !bytes.Equal(pub, w.Secrets.MasterKeypair.Public) {
+		return ErrWrongLedger
+	}
+	return nil
+}
+
+// SmkeysLedger returns the Ledger device at the given HID path (see ListLedgers), or the first one
+// found if the path is empty. Public keys are read using the smkeys library, which can't sign, so
+// transactions and messages are signed by exchanging APDUs with the device over HID, which is only
+// supported on Linux.
+func SmkeysLedger(devicePath string) LedgerDevice {
+	return &smkeysLedger{path: devicePath}
+}
+
+// readLedgerPubkey reads a public key from the Ledger device at a HID path using smkeys. Tests
+// replace it to check which device path reaches smkeys.
+var readLedgerPubkey = ledger.ReadPubkeyFromLedger
+
+type smkeysLedger struct {
+	path string
+}
+
+func (l *smkeysLedger) PublicKey(path HDPath, confirm bool) ([]byte, error) {
+	key, err := readLedgerPubkey(l.path, HDPathToString(path), confirm)
+	if err != nil {
+		return nil, fmt.Errorf("error reading pubkey from ledger: %w", err)
+	}
+	return key, nil
+}
+
+func (l *smkeysLedger) SignTx(path HDPath, genesisID types.Hash20, unsigned []byte) ([]byte, error) {
+	var sig []byte
+	err := l.withApp(func(app LedgerDevice) (err error) {
+		sig, err = app.SignTx(path, genesisID, unsigned)
+		return err
+	})
+	return sig, err
+}
+
+func (l *smkeysLedger) SignMessage(path HDPath, msg []byte) ([]byte, error) {
+	var sig []byte
+	err := l.withApp(func(app LedgerDevice) (err error) {
+		sig, err = app.SignMessage(path, msg)
+		return err
+	})
+	return sig, err
+}
+
+// withApp opens the HID device and calls f with the Spacemesh app on it.
+func (l *smkeysLedger) withApp(f func(app LedgerDevice) error) error {
+	path := l.path
+	if path == "" {
+		devices, err := ListLedgers()
+		if err != nil {
+			return err
+		}
+		if len(devices) == 0 {
+			return errors.New("no Ledger device found")
+		}
+		path = devices[0].Path
+	}
+	dev, err := openLedgerHID(path)
+	if err != nil {
+		return err
+	}
+	defer dev.Close()
+	return f(NewLedgerApp(&ledgerHID{rw: dev}))
+}
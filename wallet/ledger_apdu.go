@@ -0,0 +1,203 @@
+package wallet
+
+import (
+	"crypto/ed25519"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/genvm/core"
+)
+
+// LedgerTransport exchanges APDUs with the Spacemesh app on a Ledger device. Tests and emulators
+// implement it to use the app without a device connected over USB.
+type LedgerTransport interface {
+	// Exchange sends a command APDU and returns the response APDU: the response data followed by
+	// the 2-byte status word.
+	Exchange(apdu []byte) ([]byte, error)
+}
+
+// Command APDUs of the Spacemesh app. It uses the command set of the Solana app, which smkeys'
+// remote wallet also talks to, and signs transactions and messages with the same instruction
+// layout: the number of signers (always 1), the HD path, then the data to sign, split into
+// chunks of at most ledgerMaxChunk bytes.
+const (
+	ledgerCLA            = 0xe0
+	ledgerInsGetPubkey   = 0x05
+	ledgerInsSignTx      = 0x06
+	ledgerInsSignMessage = 0x07
+
+	// ledgerP1Confirm asks the user to confirm the request on the device.
+	ledgerP1Confirm = 0x01
+	// ledgerP2Extend marks a chunk that continues the data of the previous one.
+	ledgerP2Extend = 0x01
+	// ledgerP2More marks a chunk that is followed by more data.
+	ledgerP2More = 0x02
+
+	ledgerMaxChunk = 255
+)
+
+// Status words returned by the Spacemesh app.
+const (
+	ledgerStatusOK       = 0x9000
+	ledgerStatusRejected = 0x6985
+)
+
+// NewLedgerApp returns the Spacemesh app on the Ledger device reached through transport.
+func NewLedgerApp(transport LedgerTransport) LedgerDevice {
+	return &ledgerApp{transport: transport}
+}
+
+type ledgerApp struct {
+	transport LedgerTransport
+}
+
+func (a *ledgerApp) PublicKey(path HDPath, confirm bool) ([]byte, error) {
+	var p1 byte
+	if confirm {
+		p1 = ledgerP1Confirm
+	}
+	key, err := a.exchange(ledgerInsGetPubkey, p1, 0, encodeLedgerPath(path))
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("the Ledger device returned a %d-byte public key", len(key))
+	}
+	return key, nil
+}
+
+// SignTx sends the signing body of the transaction, which the app decodes to show its principal,
+// recipient and amount.
+func (a *ledgerApp) SignTx(path HDPath, genesisID types.Hash20, unsigned []byte) ([]byte, error) {
+	return a.sign(ledgerInsSignTx, path, core.SigningBody(genesisID[:], unsigned))
+}
+
+// SignMessage sends the message without its prefix: the app adds it, as returned by
+// MessageSigningBody, so that the host can't have a transaction signed as a message.
+func (a *ledgerApp) SignMessage(path HDPath, msg []byte) ([]byte, error) {
+	return a.sign(ledgerInsSignMessage, path, msg)
+}
+
+func (a *ledgerApp) sign(ins byte, path HDPath, data []byte) ([]byte, error) {
+	chunk := append([]byte{1}, encodeLedgerPath(path)...)
+	n := min(len(data), ledgerMaxChunk-len(chunk))
+	chunk = append(chunk, data[:n]...)
+	data = data[n:]
+	var p2 byte
+	for {
+		if len(data) > 0 {
+			p2 |= ledgerP2More
+		}
+		sig, err := a.exchange(ins, ledgerP1Confirm, p2, chunk)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) == 0 {
+			if len(sig) != ed25519.SignatureSize {
+				return nil, fmt.Errorf("the Ledger device returned a %d-byte signature", len(sig))
+			}
+			return sig, nil
+		}
+		n = min(len(data), ledgerMaxChunk)
+		chunk, data = data[:n], data[n:]
+		p2 = ledgerP2Extend
+	}
+}
+
+// exchange sends a command to the app and returns the response data if it succeeded.
+func (a *ledgerApp) exchange(ins, p1, p2 byte, data []byte) ([]byte, error) {
+	apdu := append([]byte{ledgerCLA, ins, p1, p2, byte(len(data))}, data...)
+	resp, err := a.transport.Exchange(apdu)
+	if err != nil {
+		return nil, fmt.Errorf("error communicating with the Ledger device: %w", err)
+	}
+	if len(resp) < 2 {
+		return nil, errors.New("the Ledger device returned a truncated response")
+	}
+	switch status := binary.BigEndian.Uint16(resp[len(resp)-2:]); status {
+	case ledgerStatusOK:
+		return resp[:len(resp)-2], nil
+	case ledgerStatusRejected:
+		return nil, ErrRejectedOnDevice
+	default:
+		return nil, fmt.Errorf("the Ledger device returned status 0x%04x, "+
+			"make sure it's unlocked and the Spacemesh app is open", status)
+	}
+}
+
+// encodeLedgerPath encodes an HD path as the app expects it: the number of segments followed by
+// each segment as a big-endian uint32.
+func encodeLedgerPath(path HDPath) []byte {
+	b := []byte{byte(len(path))}
+	for _, s := range path {
+		b = binary.BigEndian.AppendUint32(b, s)
+	}
+	return b
+}
+
+// HID framing of APDUs: each APDU is sent in 64-byte reports that start with the channel, the tag
+// and a sequence number. The first report of an APDU also holds its length.
+const (
+	ledgerHIDChannel    = 0x0101
+	ledgerHIDTag        = 0x05
+	ledgerHIDReportSize = 64
+)
+
+// ledgerHID is a LedgerTransport over the HID interface of a Ledger device that carries APDUs,
+// e.g. a Linux hidraw device.
+type ledgerHID struct {
+	rw io.ReadWriter
+}
+
+func (h *ledgerHID) Exchange(apdu []byte) ([]byte, error) {
+	for _, report := range ledgerHIDReports(apdu) {
+		// the device doesn't use numbered reports, so each write starts with report ID 0
+		if _, err := h.rw.Write(append([]byte{0}, report...)); err != nil {
+			return nil, err
+		}
+	}
+	return readLedgerHID(h.rw)
+}
+
+// ledgerHIDReports splits an APDU into HID reports.
+func ledgerHIDReports(apdu []byte) [][]byte {
+	data := binary.BigEndian.AppendUint16(nil, uint16(len(apdu)))
+	data = append(data, apdu...)
+	var reports [][]byte
+	for seq := 0; len(data) > 0; seq++ {
+		report := make([]byte, ledgerHIDReportSize)
+		binary.BigEndian.PutUint16(report, ledgerHIDChannel)
+		report[2] = ledgerHIDTag
+		binary.BigEndian.PutUint16(report[3:], uint16(seq))
+		n := copy(report[5:], data)
+		data = data[n:]
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// readLedgerHID reads the HID reports of an APDU from r and returns the APDU.
+func readLedgerHID(r io.Reader) ([]byte, error) {
+	var apdu []byte
+	size := -1
+	for seq := 0; size < 0 || len(apdu) < size; seq++ {
+		report := make([]byte, ledgerHIDReportSize)
+		if _, err := io.ReadFull(r, report); err != nil {
+			return nil, err
+		}
+		if binary.BigEndian.Uint16(report) != ledgerHIDChannel || report[2] != ledgerHIDTag ||
+			binary.BigEndian.Uint16(report[3:]) != uint16(seq) {
+			return nil, errors.New("unexpected HID report from the Ledger device")
+		}
+		data := report[5:]
+		if seq == 0 {
+			size = int(binary.BigEndian.Uint16(data))
+			data = data[2:]
+		}
+		apdu = append(apdu, data[:min(len(data), size-len(apdu))]...)
+	}
+	return apdu, nil
+}
@@ -0,0 +1,107 @@
+package wallet
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// hidrawSysfs is where Linux lists hidraw devices.
+const hidrawSysfs = "/sys/class/hidraw"
+
+// ledgerUsagePage is the start of the report descriptor of the HID interface of a Ledger device that
+// carries APDUs: a Usage Page item for the vendor-defined page 0xffa0.
+var ledgerUsagePage = []byte{0x06, 0xa0, 0xff}
+
+func listLedgers() ([]LedgerInfo, error) {
+	return listHidrawLedgers(hidrawSysfs, "/dev")
+}
+
+// openLedgerHID opens the hidraw device of a Ledger device to exchange APDUs with it.
+func openLedgerHID(path string) (io.ReadWriteCloser, error) {
+	return os.OpenFile(path, os.O_RDWR, 0)
+}
+
+// listHidrawLedgers lists the Ledger devices among the hidraw devices in sysfs. A Ledger exposes
+// several HID interfaces; only the one that carries APDUs is listed.
+func listHidrawLedgers(sysfs, dev string) ([]LedgerInfo, error) {
+	entries, err := os.ReadDir(sysfs)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var devices []LedgerInfo
+	for _, e := range entries {
+		uevent, err := readUevent(filepath.Join(sysfs, e.Name(), "device", "uevent"))
+		if err != nil {
+			continue
+		}
+		vendor, product, ok := parseHIDID(uevent["HID_ID"])
+		if !ok || vendor != LedgerVendorID {
+			continue
+		}
+		if !isAPDUInterface(filepath.Join(sysfs, e.Name(), "device"), uevent) {
+			continue
+		}
+		devices = append(devices, LedgerInfo{
+			Path:      filepath.Join(dev, e.Name()),
+			Model:     ledgerModel(product),
+			ProductID: product,
+			Serial:    uevent["HID_UNIQ"],
+		})
+	}
+	return devices, nil
+}
+
+// isAPDUInterface reports whether the HID interface of a Ledger device, whose sysfs directory is dir,
+// carries APDUs. Like the HID layer of smkeys, it accepts the interface with the Ledger usage page,
+// and the first interface of the device.
+func isAPDUInterface(dir string, uevent map[string]string) bool {
+	if strings.HasSuffix(uevent["HID_PHYS"], "/input0") {
+		return true
+	}
+	desc, err := os.ReadFile(filepath.Join(dir, "report_descriptor"))
+	return err == nil && bytes.HasPrefix(desc, ledgerUsagePage)
+}
+
+// readUevent reads the KEY=value lines of a sysfs uevent file.
+func readUevent(fn string) (map[string]string, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if k, v, ok := strings.Cut(scanner.Text(), "="); ok {
+			values[k] = v
+		}
+	}
+	return values, scanner.Err()
+}
+
+// parseHIDID parses the HID_ID of a uevent, bus:vendor:product in hex, e.g. 0003:00002C97:00005011.
+func parseHIDID(id string) (vendor, product uint16, ok bool) {
+	parts := strings.Split(id, ":")
+	if len(parts) != 3 {
+		return 0, 0, false
+	}
+	v, err := strconv.ParseUint(parts[1], 16, 32)
+	if err != nil || v > 0xffff {
+		return 0, 0, false
+	}
+	p, err := strconv.ParseUint(parts[2], 16, 32)
+	if err != nil || p > 0xffff {
+		return 0, 0, false
+	}
+	return uint16(v), uint16(p), true
+}
@@ -0,0 +1,81 @@
+package wallet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeUevent(t *testing.T, sysfs, name, uevent string) {
+	t.Helper()
+	dir := filepath.Join(sysfs, name, "device")
+	require.NoError(t, os.MkdirAll(dir, 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "uevent"), []byte(uevent), 0o600))
+}
+
+func TestListHidrawLedgers(t *testing.T) {
+	sysfs := t.TempDir()
+	writeUevent(t, sysfs, "hidraw0", "DRIVER=hid-generic\nHID_ID=0003:0000046D:0000C52B\n"+
+		"HID_NAME=Logitech USB Receiver\nHID_PHYS=usb-0000:00:14.0-2/input0\nHID_UNIQ=\n")
+	writeUevent(t, sysfs, "hidraw1", "DRIVER=hid-generic\nHID_ID=0003:00002C97:00005011\n"+
+		"HID_NAME=Ledger Nano S Plus\nHID_PHYS=usb-0000:00:14.0-1/input0\nHID_UNIQ=0001\n")
+	// the second interface of the same device
+	writeUevent(t, sysfs, "hidraw2", "DRIVER=hid-generic\nHID_ID=0003:00002C97:00005011\n"+
+		"HID_NAME=Ledger Nano S Plus\nHID_PHYS=usb-0000:00:14.0-1/input1\nHID_UNIQ=0001\n")
+	writeUevent(t, sysfs, "hidraw3", "DRIVER=hid-generic\nHID_ID=0003:00002C97:00004011\n"+
+		"HID_NAME=Ledger Nano X\nHID_PHYS=usb-0000:00:14.0-3/input0\nHID_UNIQ=\n")
+	require.NoError(t, os.MkdirAll(filepath.Join(sysfs, "hidraw4"), 0o700))
+	// a device whose APDU interface isn't the first one is found by its usage page
+	writeUevent(t, sysfs, "hidraw5", "DRIVER=hid-generic\nHID_ID=0003:00002C97:00001015\n"+
+		"HID_NAME=Ledger Nano S\nHID_PHYS=usb-0000:00:14.0-4/input1\nHID_UNIQ=\n")
+	require.NoError(t, os.WriteFile(filepath.Join(sysfs, "hidraw5", "device", "report_descriptor"),
+		[]byte{0x06, 0xa0, 0xff, 0x09, 0x01, 0xa1, 0x01}, 0o600))
+	writeUevent(t, sysfs, "hidraw6", "DRIVER=hid-generic\nHID_ID=0003:00002C97:00001015\n"+
+		"HID_NAME=Ledger Nano S\nHID_PHYS=usb-0000:00:14.0-4/input2\nHID_UNIQ=\n")
+	require.NoError(t, os.WriteFile(filepath.Join(sysfs, "hidraw6", "device", "report_descriptor"),
+		[]byte{0x06, 0xd0, 0xf1, 0x09, 0x01, 0xa1, 0x01}, 0o600))
+
+	devices, err := listHidrawLedgers(sysfs, "/dev")
+	require.NoError(t, err)
+	require.Equal(t, []LedgerInfo{
+		{Path: "/dev/hidraw1", Model: "Nano S Plus", ProductID: 0x5011, Serial: "0001"},
+		{Path: "/dev/hidraw3", Model: "Nano X", ProductID: 0x4011},
+		{Path: "/dev/hidraw5", Model: "Nano S", ProductID: 0x1015},
+	}, devices)
+
+	devices, err = listHidrawLedgers(filepath.Join(sysfs, "missing"), "/dev")
+	require.NoError(t, err)
+	require.Empty(t, devices)
+}
+
+// The paths of listed devices are passed to smkeys as they are, to select the device.
+func TestListedLedgerReachesSmkeys(t *testing.T) {
+	sysfs := t.TempDir()
+	writeUevent(t, sysfs, "hidraw1", "DRIVER=hid-generic\nHID_ID=0003:00002C97:00005011\n"+
+		"HID_NAME=Ledger Nano S Plus\nHID_PHYS=usb-0000:00:14.0-1/input0\nHID_UNIQ=0001\n")
+	devices, err := listHidrawLedgers(sysfs, "/dev")
+	require.NoError(t, err)
+	require.Len(t, devices, 1)
+
+	device := newFakeLedger(t)
+	var paths []string
+	read := readLedgerPubkey
+	t.Cleanup(func() { readLedgerPubkey = read })
+	readLedgerPubkey = func(path, derivationPath string, confirm bool) ([]byte, error) {
+		paths = append(paths, path)
+		hdPath, err := StringToHDPath(derivationPath)
+		if err != nil {
+			return nil, err
+		}
+		return device.PublicKey(hdPath, confirm)
+	}
+
+	fp, err := ReadLedgerFingerprint(SmkeysLedger(devices[0].Path))
+	require.NoError(t, err)
+	expected, err := ReadLedgerFingerprint(device)
+	require.NoError(t, err)
+	require.Equal(t, expected, fp)
+	require.Equal(t, []string{"/dev/hidraw1"}, paths)
+}
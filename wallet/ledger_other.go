@@ -0,0 +1,20 @@
+//go:build !linux
+
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// listLedgers isn't supported: Ledger devices are found using Linux sysfs, and there's no HID library
+// to enumerate them elsewhere. Devices can still be selected by their HID path.
+func listLedgers() ([]LedgerInfo, error) {
+	return nil, errors.New("listing Ledger devices is only supported on Linux")
+}
+
+// openLedgerHID isn't supported: APDUs are exchanged using Linux hidraw devices.
+func openLedgerHID(string) (io.ReadWriteCloser, error) {
+	return nil, fmt.Errorf("%w: it's only supported on Linux", ErrLedgerSigningUnsupported)
+}
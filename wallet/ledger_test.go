@@ -0,0 +1,240 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/genvm/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLedgerModel(t *testing.T) {
+	require.Equal(t, "Nano S", ledgerModel(0x0001))
+	require.Equal(t, "Nano S", ledgerModel(0x1011))
+	require.Equal(t, "Nano X", ledgerModel(0x4011))
+	require.Equal(t, "Nano S Plus", ledgerModel(0x5011))
+	require.Equal(t, "Unknown (0xf011)", ledgerModel(0xf011))
+}
+
+func TestNewMultiWalletFromLedger(t *testing.T) {
+	device := newFakeLedger(t)
+	w, err := NewMultiWalletFromLedger(device, 2)
+	require.NoError(t, err)
+	require.True(t, w.IsLedger())
+	require.Len(t, w.Secrets.Accounts, 2)
+	require.Equal(t, LedgerFingerprint(w.Secrets.MasterKeypair.Public), w.Meta.LedgerFingerprint)
+	// the account keys are confirmed on the device, the master key isn't
+	require.Equal(t, 2, device.Confirms)
+	for _, a := range w.Secrets.Accounts {
+		require.True(t, a.IsLedger())
+		require.Empty(t, a.Private)
+	}
+
+	fp, err := ReadLedgerFingerprint(device)
+	require.NoError(t, err)
+	require.Equal(t, w.Meta.LedgerFingerprint, fp)
+
+	// accounts can be added using the same device
+	kp, err := w.AddAccount(nil, device, AccountPath(0, 0, 5), "")
+	require.NoError(t, err)
+	pub, err := device.PublicKey(kp.Path, false)
+	require.NoError(t, err)
+	require.Equal(t, PublicKey(pub), kp.Public)
+	_, err = w.AddAccount(nil, nil, AccountPath(0, 0, 6), "")
+	require.ErrorIs(t, err, ErrNoLedgerDevice)
+}
+
+func TestCheckLedger(t *testing.T) {
+	device := newFakeLedger(t)
+	other := newFakeLedger(t)
+	w, err := NewMultiWalletFromLedger(device, 1)
+	require.NoError(t, err)
+
+	require.NoError(t, w.CheckLedger(device))
+	require.ErrorIs(t, w.CheckLedger(other), ErrWrongLedger)
+
+	// wallets written without a fingerprint are checked against the master public key
+	w.Meta.LedgerFingerprint = ""
+	require.NoError(t, w.CheckLedger(device))
+	require.ErrorIs(t, w.CheckLedger(other), ErrWrongLedger)
+
+	sw, err := NewMultiWalletRandomMnemonic("", 1)
+	require.NoError(t, err)
+	require.Error(t, sw.CheckLedger(device))
+}
+
+// appTransport emulates the Spacemesh app on top of a FakeLedger, and records the APDUs sent to it.
+type appTransport struct {
+	device *FakeLedger
+	apdus  [][]byte
+
+	// the path and data of a signing request sent in several chunks
+	path HDPath
+	data []byte
+}
+
+func decodeLedgerPath(b []byte) (HDPath, []byte) {
+	n := int(b[0])
+	path := make(HDPath, n)
+	for i := range path {
+		path[i] = binary.BigEndian.Uint32(b[1+4*i:])
+	}
+	return path, b[1+4*n:]
+}
+
+func (a *appTransport) Exchange(apdu []byte) ([]byte, error) {
+	a.apdus = append(a.apdus, apdu)
+	if len(apdu) < 5 || apdu[0] != ledgerCLA || int(apdu[4]) != len(apdu)-5 {
+		return []byte{0x67, 0x00}, nil
+	}
+	ins, p1, p2, data := apdu[1], apdu[2], apdu[3], apdu[5:]
+	var resp []byte
+	var err error
+	switch ins {
+	case ledgerInsGetPubkey:
+		path, _ := decodeLedgerPath(data)
+		resp, err = a.device.PublicKey(path, p1 == ledgerP1Confirm)
+	case ledgerInsSignTx, ledgerInsSignMessage:
+		if p2&ledgerP2Extend == 0 {
+			a.path, a.data = decodeLedgerPath(data[1:])
+		} else {
+			a.data = append(a.data, data...)
+		}
+		if p2&ledgerP2More != 0 {
+			return []byte{0x90, 0x00}, nil
+		}
+		if ins == ledgerInsSignTx {
+			resp, err = a.device.SignTx(a.path, types.Hash20(a.data[:20]), a.data[20:])
+		} else {
+			resp, err = a.device.SignMessage(a.path, a.data)
+		}
+	default:
+		return []byte{0x6d, 0x00}, nil
+	}
+	if errors.Is(err, ErrRejectedOnDevice) {
+		return []byte{0x69, 0x85}, nil
+	}
+	if err != nil {
+		return []byte{0x6a, 0x80}, nil
+	}
+	return append(resp, 0x90, 0x00), nil
+}
+
+func TestLedgerApp(t *testing.T) {
+	device, kp := ledgerAccount(t)
+	transport := &appTransport{device: device}
+	app := NewLedgerApp(transport)
+
+	pub, err := app.PublicKey(kp.Path, false)
+	require.NoError(t, err)
+	require.Equal(t, PublicKey(pub), kp.Public)
+	require.Equal(t, append([]byte{ledgerCLA, ledgerInsGetPubkey, 0, 0, 21}, encodeLedgerPath(kp.Path)...),
+		transport.apdus[0])
+
+	signer, err := kp.Signer(app)
+	require.NoError(t, err)
+	var genesisID types.Hash20
+	genesisID[0] = 1
+	unsigned := unsignedSpend(kp.Public, types.GenerateAddress([]byte("recipient")), 100)
+	sig, err := signer.SignTx(genesisID, unsigned)
+	require.NoError(t, err)
+	require.True(t, ed25519.Verify(ed25519.PublicKey(kp.Public), core.SigningBody(genesisID[:], unsigned), sig))
+	require.Equal(t, byte(ledgerInsSignTx), transport.apdus[len(transport.apdus)-1][1])
+
+	// the first chunk holds the signer count and the path, and every chunk fits in an APDU
+	header := 2 + len(kp.Path)*4
+	for _, size := range []int{10, ledgerMaxChunk - header, ledgerMaxChunk - header + 1, 600} {
+		transport.apdus = nil
+		msg := bytes.Repeat([]byte{byte(size)}, size)
+		sig, err := signer.SignMessage(msg)
+		require.NoError(t, err)
+		require.True(t, ed25519.Verify(ed25519.PublicKey(kp.Public), MessageSigningBody(msg), sig))
+
+		require.Len(t, transport.apdus, (header+size+ledgerMaxChunk-1)/ledgerMaxChunk)
+		for i, apdu := range transport.apdus {
+			require.Equal(t, byte(ledgerInsSignMessage), apdu[1])
+			require.Equal(t, byte(ledgerP1Confirm), apdu[2])
+			more := i < len(transport.apdus)-1
+			require.Equal(t, more, apdu[3]&ledgerP2More != 0)
+			require.Equal(t, i > 0, apdu[3]&ledgerP2Extend != 0)
+			if more {
+				require.Len(t, apdu, 5+ledgerMaxChunk)
+			}
+		}
+	}
+
+	device.Reject = true
+	_, err = signer.SignTx(genesisID, unsigned)
+	require.ErrorIs(t, err, ErrRejectedOnDevice)
+	_, err = signer.SignMessage([]byte("msg"))
+	require.ErrorIs(t, err, ErrRejectedOnDevice)
+}
+
+func TestLedgerAppStatus(t *testing.T) {
+	for _, resp := range [][]byte{{0x6e, 0x01}, {0x90}, append(make([]byte, 10), 0x90, 0x00)} {
+		app := NewLedgerApp(transportFunc(func([]byte) ([]byte, error) { return resp, nil }))
+		_, err := app.PublicKey(DefaultPath(), false)
+		require.Error(t, err)
+		_, err = app.SignMessage(DefaultPath(), []byte("msg"))
+		require.Error(t, err)
+	}
+}
+
+type transportFunc func(apdu []byte) ([]byte, error)
+
+func (f transportFunc) Exchange(apdu []byte) ([]byte, error) {
+	return f(apdu)
+}
+
+// hidDevice is the HID interface of a Ledger device: it reads the APDUs written to it and answers
+// them using the app.
+type hidDevice struct {
+	t   *testing.T
+	app LedgerTransport
+	in  bytes.Buffer
+	out bytes.Buffer
+}
+
+func (d *hidDevice) Write(report []byte) (int, error) {
+	require.Len(d.t, report, 1+ledgerHIDReportSize)
+	require.Zero(d.t, report[0])
+	d.in.Write(report[1:])
+	// answer once the whole APDU was written
+	if apdu, err := readLedgerHID(bytes.NewReader(d.in.Bytes())); err == nil {
+		d.in.Reset()
+		resp, err := d.app.Exchange(apdu)
+		require.NoError(d.t, err)
+		for _, r := range ledgerHIDReports(resp) {
+			d.out.Write(r)
+		}
+	}
+	return len(report), nil
+}
+
+func (d *hidDevice) Read(b []byte) (int, error) {
+	return d.out.Read(b)
+}
+
+func TestLedgerHID(t *testing.T) {
+	device, kp := ledgerAccount(t)
+	app := NewLedgerApp(&ledgerHID{rw: &hidDevice{t: t, app: &appTransport{device: device}}})
+	pub, err := app.PublicKey(kp.Path, false)
+	require.NoError(t, err)
+	require.Equal(t, PublicKey(pub), kp.Public)
+
+	// APDUs and responses that span several reports
+	msg := bytes.Repeat([]byte{0xab}, 200)
+	sig, err := app.SignMessage(kp.Path, msg)
+	require.NoError(t, err)
+	require.True(t, ed25519.Verify(ed25519.PublicKey(kp.Public), MessageSigningBody(msg), sig))
+
+	reports := ledgerHIDReports(make([]byte, 60))
+	require.Len(t, reports, 2)
+	reports[1][4] = 5
+	_, err = readLedgerHID(bytes.NewReader(bytes.Join(reports, nil)))
+	require.Error(t, err)
+}
@@ -0,0 +1,170 @@
+package wallet
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+)
+
+// MessagePrefix is prepended to messages before they're signed, along with the length of the
+// message, so that a message signature can't be replayed as a transaction signature or a signature
+// on other data. Transactions are signed over the genesis ID followed by the transaction, and the
+// prefix can't be a genesis ID, which is a hash.
+const MessagePrefix = "\x19Spacemesh Signed Message:\n"
+
+// SignedMessageType identifies signed message envelopes.
+const SignedMessageType = "spacemesh-signed-message"
+
+// SignedMessageVersion is the version of the signed message envelope.
+const SignedMessageVersion = 1
+
+// Encodings of the message in a signed message envelope.
+const (
+	MessageEncodingText = "text"
+	MessageEncodingHex  = "hex"
+)
+
+// ErrInvalidSignature is returned when a message signature doesn't verify.
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// SignedMessage is a self-describing envelope of a signed message, which contains everything
+// needed to verify the signature.
+type SignedMessage struct {
+	Type      string `json:"type" yaml:"type"`
+	Version   int    `json:"version" yaml:"version"`
+	Scheme    string `json:"scheme" yaml:"scheme"`
+	Prefix    string `json:"prefix" yaml:"prefix"`
+	Encoding  string `json:"encoding" yaml:"encoding"`
+	Message   string `json:"message" yaml:"message"`
+	PublicKey string `json:"publicKey" yaml:"publicKey"`
+	Address   string `json:"address,omitempty" yaml:"address,omitempty"`
+	Signature string `json:"signature" yaml:"signature"`
+}
+
+// MessageSigningBody returns the bytes that are signed for the message: the message prefix, the
+// decimal length of the message and the message itself.
+func MessageSigningBody(msg []byte) []byte {
+	body := make([]byte, 0, len(MessagePrefix)+20+len(msg))
+	body = append(body, MessagePrefix...)
+	body = strconv.AppendInt(body, int64(len(msg)), 10)
+	return append(body, msg...)
+}
+
+// SignMessage signs the message with the signer's key and returns the envelope. The address of
+// the signer is computed using the given HRP.
+func SignMessage(signer Signer, msg []byte, hrp string) (*SignedMessage, error) {
+	sig, err := signer.SignMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+	m := &SignedMessage{
+		Type:      SignedMessageType,
+		Version:   SignedMessageVersion,
+		Scheme:    "ed25519",
+		Prefix:    MessagePrefix,
+		PublicKey: hex.EncodeToString(signer.PublicKey()),
+		Address:   PubkeyToAddress(signer.PublicKey(), hrp),
+		Signature: hex.EncodeToString(sig),
+	}
+	// keep text human-readable in the envelope, and hex-encode anything else
+	if utf8.Valid(msg) {
+		m.Encoding = MessageEncodingText
+		m.Message = string(msg)
+	} else {
+		m.Encoding = MessageEncodingHex
+		m.Message = hex.EncodeToString(msg)
+	}
+	return m, nil
+}
+
+// ReadSignedMessage decodes a signed message envelope.
+func ReadSignedMessage(data []byte) (*SignedMessage, error) {
+	m := &SignedMessage{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("decoding signed message: %w", err)
+	}
+	if m.Type != SignedMessageType {
+		return nil, fmt.Errorf("not a signed message: type %q", m.Type)
+	}
+	if m.Version != SignedMessageVersion {
+		return nil, fmt.Errorf("unsupported signed message version %d", m.Version)
+	}
+	return m, nil
+}
+
+// Bytes returns the decoded message.
+func (m *SignedMessage) Bytes() ([]byte, error) {
+	switch m.Encoding {
+	case MessageEncodingText:
+		return []byte(m.Message), nil
+	case MessageEncodingHex:
+		return hex.DecodeString(m.Message)
+	default:
+		return nil, fmt.Errorf("unsupported message encoding %q", m.Encoding)
+	}
+}
+
+// Verify checks the signature of the message, and that the address in the envelope, if any, is
+// the address of the public key. It returns ErrInvalidSignature if the signature doesn't verify.
+func (m *SignedMessage) Verify() error {
+	if m.Scheme != "ed25519" {
+		return fmt.Errorf("unsupported signature scheme %q", m.Scheme)
+	}
+	if m.Prefix != MessagePrefix {
+		return fmt.Errorf("unsupported message prefix %q", m.Prefix)
+	}
+	msg, err := m.Bytes()
+	if err != nil {
+		return err
+	}
+	pub, err := hex.DecodeString(m.PublicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid public key %q", m.PublicKey)
+	}
+	sig, err := hex.DecodeString(m.Signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return fmt.Errorf("%w: malformed signature", ErrInvalidSignature)
+	}
+	if !ed25519.Verify(pub, MessageSigningBody(msg), sig) {
+		return ErrInvalidSignature
+	}
+	if m.Address != "" {
+		return m.CheckAddress(m.Address)
+	}
+	return nil
+}
+
+// CheckAddress checks that the address is the wallet address of the envelope's public key, by
+// deriving the address from the public key using the address's HRP.
+func (m *SignedMessage) CheckAddress(address string) error {
+	pub, err := hex.DecodeString(m.PublicKey)
+	if err != nil {
+		return fmt.Errorf("invalid public key %q", m.PublicKey)
+	}
+	// the HRP is everything before the last separator of the bech32 address
+	i := strings.LastIndexByte(address, '1')
+	if i <= 0 {
+		return fmt.Errorf("invalid address %q", address)
+	}
+	// PubkeyToAddress sets the network HRP, restore it since the caller didn't choose this one
+	defer types.SetNetworkHRP(types.NetworkHRP())
+	if derived := PubkeyToAddress(pub, address[:i]); derived != address {
+		return fmt.Errorf("address %s does not belong to public key %s (derived %s)", address, m.PublicKey, derived)
+	}
+	return nil
+}
+
+// CheckPublicKey checks that the envelope was signed with the given hex-encoded public key.
+func (m *SignedMessage) CheckPublicKey(pub string) error {
+	if !strings.EqualFold(strings.TrimPrefix(pub, "0x"), m.PublicKey) {
+		return fmt.Errorf("message was signed by public key %s, not %s", m.PublicKey, pub)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package wallet
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageSigningBody(t *testing.T) {
+	require.Equal(t, []byte("\x19Spacemesh Signed Message:\n5hello"), MessageSigningBody([]byte("hello")))
+	require.Equal(t, []byte("\x19Spacemesh Signed Message:\n0"), MessageSigningBody(nil))
+}
+
+func TestSignAndVerifyMessage(t *testing.T) {
+	// PubkeyToAddress sets the network HRP
+	hrp := types.NetworkHRP()
+	t.Cleanup(func() { types.SetNetworkHRP(hrp) })
+
+	w, err := NewMultiWalletRandomMnemonic("", 2)
+	require.NoError(t, err)
+	kp := w.Secrets.Accounts[0]
+	signer, err := kp.Signer(nil)
+	require.NoError(t, err)
+
+	for _, msg := range [][]byte{[]byte("I control this address"), {0xff, 0x00, 0x01}} {
+		m, err := SignMessage(signer, msg, "stest")
+		require.NoError(t, err)
+		require.NoError(t, m.Verify())
+		require.NoError(t, m.CheckPublicKey(hex.EncodeToString(kp.Public)))
+		require.Equal(t, PubkeyToAddress(kp.Public, "stest"), m.Address)
+
+		// the envelope survives a round trip through JSON
+		b, err := json.Marshal(m)
+		require.NoError(t, err)
+		m2, err := ReadSignedMessage(b)
+		require.NoError(t, err)
+		require.Equal(t, m, m2)
+		decoded, err := m2.Bytes()
+		require.NoError(t, err)
+		require.Equal(t, msg, decoded)
+	}
+
+	// the signature is over the prefixed message, not the message itself
+	m, err := SignMessage(signer, []byte("hello"), "sm")
+	require.NoError(t, err)
+	sig, err := hex.DecodeString(m.Signature)
+	require.NoError(t, err)
+	require.False(t, ed25519.Verify(ed25519.PublicKey(kp.Public), []byte("hello"), sig))
+
+	// tampering with any part of the envelope is detected
+	modified := *m
+	modified.Message = "hellO"
+	require.ErrorIs(t, modified.Verify(), ErrInvalidSignature)
+	modified = *m
+	modified.PublicKey = hex.EncodeToString(w.Secrets.Accounts[1].Public)
+	require.ErrorIs(t, modified.Verify(), ErrInvalidSignature)
+	modified = *m
+	modified.Address = PubkeyToAddress(w.Secrets.Accounts[1].Public, "sm")
+	require.Error(t, modified.Verify())
+	modified = *m
+	modified.Prefix = ""
+	require.Error(t, modified.Verify())
+
+	require.Error(t, m.CheckPublicKey(hex.EncodeToString(w.Secrets.Accounts[1].Public)))
+	require.NoError(t, m.CheckAddress(PubkeyToAddress(kp.Public, "stest")))
+	require.Error(t, m.CheckAddress(PubkeyToAddress(w.Secrets.Accounts[1].Public, "stest")))
+}
+
+func TestReadSignedMessage(t *testing.T) {
+	_, err := ReadSignedMessage([]byte(`{"type":"something-else","version":1}`))
+	require.Error(t, err)
+	_, err = ReadSignedMessage([]byte(`{"type":"spacemesh-signed-message","version":2}`))
+	require.Error(t, err)
+	_, err = ReadSignedMessage([]byte(`not json`))
+	require.Error(t, err)
+}
@@ -0,0 +1,240 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/tyler-smith/go-bip39"
+	"github.com/tyler-smith/go-bip39/wordlists"
+	"golang.org/x/text/unicode/norm"
+)
+
+// DefaultMnemonicWords is the number of words of newly generated mnemonics, which encode 256 bits
+// of entropy.
+const DefaultMnemonicWords = 24
+
+// DefaultMnemonicLanguage is the wordlist language of newly generated mnemonics.
+const DefaultMnemonicLanguage = "english"
+
+var (
+	// ErrMnemonicLength is returned for mnemonics that don't have 12, 15, 18, 21 or 24 words.
+	ErrMnemonicLength = errors.New("invalid mnemonic length")
+
+	// ErrUnknownWords is returned for mnemonics that contain words that aren't in the wordlist.
+	ErrUnknownWords = errors.New("unknown mnemonic words")
+
+	// ErrMnemonicChecksum is returned for mnemonics whose checksum doesn't match their words.
+	ErrMnemonicChecksum = errors.New("invalid mnemonic checksum")
+
+	// ErrUnsupportedLanguage is returned for unknown mnemonic wordlist languages.
+	ErrUnsupportedLanguage = errors.New("unsupported mnemonic language")
+)
+
+// mnemonicLanguages are the BIP-39 wordlists, in the order in which they're tried when detecting
+// the language of a mnemonic.
+var mnemonicLanguages = []struct {
+	name  string
+	words []string
+}{
+	{"english", wordlists.English},
+	{"spanish", wordlists.Spanish},
+	{"french", wordlists.French},
+	{"italian", wordlists.Italian},
+	{"czech", wordlists.Czech},
+	{"japanese", wordlists.Japanese},
+	{"korean", wordlists.Korean},
+	{"chinese-simplified", wordlists.ChineseSimplified},
+	{"chinese-traditional", wordlists.ChineseTraditional},
+}
+
+var (
+	wordIndicesOnce sync.Once
+	// wordIndices maps each language to the indices of its NFKD-normalized words.
+	wordIndices map[string]map[string]int
+)
+
+func languageIndices(language string) (map[string]int, bool) {
+	wordIndicesOnce.Do(func() {
+		wordIndices = make(map[string]map[string]int, len(mnemonicLanguages))
+		for _, l := range mnemonicLanguages {
+			m := make(map[string]int, len(l.words))
+			for i, w := range l.words {
+				m[norm.NFKD.String(w)] = i
+			}
+			wordIndices[l.name] = m
+		}
+	})
+	m, ok := wordIndices[language]
+	return m, ok
+}
+
+// MnemonicLanguages returns the names of the supported BIP-39 wordlist languages.
+func MnemonicLanguages() []string {
+	names := make([]string, 0, len(mnemonicLanguages))
+	for _, l := range mnemonicLanguages {
+		names = append(names, l.name)
+	}
+	return names
+}
+
+// bip39Mu serializes the use of go-bip39, which keeps the wordlist it uses in a global.
+var bip39Mu sync.Mutex
+
+// withWordlist calls f with the go-bip39 wordlist set to list, and restores the previous wordlist
+// afterwards.
+func withWordlist(list []string, f func()) {
+	bip39Mu.Lock()
+	defer bip39Mu.Unlock()
+	prev := bip39.GetWordList()
+	bip39.SetWordList(list)
+	defer bip39.SetWordList(prev)
+	f()
+}
+
+func wordlist(language string) ([]string, error) {
+	for _, l := range mnemonicLanguages {
+		if l.name == language {
+			return l.words, nil
+		}
+	}
+	return nil, fmt.Errorf("%w %q, expected one of: %s", ErrUnsupportedLanguage, language,
+		strings.Join(MnemonicLanguages(), ", "))
+}
+
+// MnemonicEntropyBits returns the number of bits of entropy encoded by a mnemonic with the given
+// number of words, or 0 if it's not a valid length. Each word encodes 11 bits, one in 33 of which
+// is a checksum bit.
+func MnemonicEntropyBits(words int) int {
+	switch words {
+	case 12, 15, 18, 21, 24:
+		return words * 11 * 32 / 33
+	default:
+		return 0
+	}
+}
+
+// NewMnemonic generates a new, random mnemonic with the given number of words (12, 15, 18, 21 or
+// 24) from the wordlist of the given language.
+func NewMnemonic(words int, language string) (string, error) {
+	bits := MnemonicEntropyBits(words)
+	if bits == 0 {
+		return "", fmt.Errorf("%w: %d words, expected 12, 15, 18, 21 or 24", ErrMnemonicLength, words)
+	}
+	entropy := make([]byte, bits/8)
+	if _, err := rand.Read(entropy); err != nil {
+		return "", err
+	}
+	return MnemonicFromEntropy(entropy, language)
+}
+
+// MnemonicFromEntropy encodes 128 to 256 bits of entropy, in steps of 32 bits, as a mnemonic in
+// the given language.
+func MnemonicFromEntropy(entropy []byte, language string) (string, error) {
+	list, err := wordlist(language)
+	if err != nil {
+		return "", err
+	}
+	bits := len(entropy) * 8
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return "", fmt.Errorf("invalid entropy length %d bits, expected 128 to 256 in steps of 32", bits)
+	}
+	// words are separated by ASCII spaces in all languages, which is what the ideographic space
+	// used in Japanese normalizes to when deriving the seed
+	var m string
+	withWordlist(list, func() {
+		m, err = bip39.NewMnemonic(entropy)
+	})
+	return m, err
+}
+
+// ParseMnemonic checks that the mnemonic has a valid number of words, that all its words are in
+// the wordlist of one of the supported languages and that its checksum matches, and returns the
+// language. Words are compared after Unicode NFKD normalization, as BIP-39 requires. If the
+// mnemonic is invalid, the error lists the unknown words or describes the checksum failure.
+func ParseMnemonic(m string) (string, error) {
+	words := MnemonicWords(m)
+	if MnemonicEntropyBits(len(words)) == 0 {
+		return "", fmt.Errorf("%w: %d words, expected 12, 15, 18, 21 or 24", ErrMnemonicLength, len(words))
+	}
+
+	// the language is the first one that contains all words and whose checksum matches, since the
+	// Chinese wordlists share some words
+	var best string
+	var bestUnknown []int
+	checksumFailed := ""
+	for _, l := range mnemonicLanguages {
+		indices, _ := languageIndices(l.name)
+		var unknown []int
+		for i, w := range words {
+			if _, ok := indices[w]; !ok {
+				unknown = append(unknown, i)
+			}
+		}
+		if len(unknown) > 0 {
+			if best == "" || len(unknown) < len(bestUnknown) {
+				best, bestUnknown = l.name, unknown
+			}
+			continue
+		}
+		if _, err := mnemonicEntropy(words, l.words, indices); err == nil {
+			return l.name, nil
+		}
+		if checksumFailed == "" {
+			checksumFailed = l.name
+		}
+	}
+
+	if checksumFailed != "" {
+		return "", fmt.Errorf("%w: all words are in the %s wordlist, but the checksum in the last word (#%d) "+
+			"doesn't match the others; check for a mistyped, missing or swapped word",
+			ErrMnemonicChecksum, checksumFailed, len(words))
+	}
+	list := make([]string, 0, len(bestUnknown))
+	for _, i := range bestUnknown {
+		list = append(list, fmt.Sprintf("#%d %q", i+1, words[i]))
+	}
+	return "", fmt.Errorf("%w: %s (not in the %s wordlist, the closest match)", ErrUnknownWords,
+		strings.Join(list, ", "), best)
+}
+
+// MnemonicWords returns the NFKD-normalized words of the mnemonic, which is how words must be
+// compared.
+func MnemonicWords(m string) []string {
+	return strings.Fields(norm.NFKD.String(m))
+}
+
+// MnemonicEntropy returns the entropy encoded by a valid mnemonic in any supported language.
+func MnemonicEntropy(m string) ([]byte, error) {
+	language, err := ParseMnemonic(m)
+	if err != nil {
+		return nil, err
+	}
+	list, _ := wordlist(language)
+	indices, _ := languageIndices(language)
+	return mnemonicEntropy(MnemonicWords(m), list, indices)
+}
+
+// mnemonicEntropy decodes the NFKD-normalized words of a mnemonic in the wordlist list, whose
+// indices are given, and checks its checksum. All words must be in indices. The words are passed to
+// go-bip39 as they appear in the wordlist, which isn't always NFKD-normalized.
+func mnemonicEntropy(words, list []string, indices map[string]int) ([]byte, error) {
+	canonical := make([]string, len(words))
+	for i, w := range words {
+		canonical[i] = list[indices[w]]
+	}
+	var entropy []byte
+	var err error
+	withWordlist(list, func() {
+		entropy, err = bip39.EntropyFromMnemonic(strings.Join(canonical, " "))
+	})
+	return entropy, err
+}
+
+// MnemonicSeed returns the BIP-39 seed of the mnemonic and passphrase, both NFKD-normalized. For
+// ASCII mnemonics and passphrases, such as English ones, normalization has no effect.
+func MnemonicSeed(m, passphrase string) []byte {
+	return bip39.NewSeed(strings.Join(MnemonicWords(m), " "), norm.NFKD.String(passphrase))
+}
@@ -0,0 +1,172 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tyler-smith/go-bip39"
+	"github.com/tyler-smith/go-bip39/wordlists"
+	"golang.org/x/text/unicode/norm"
+)
+
+func TestNewMnemonicLengthsAndLanguages(t *testing.T) {
+	for _, language := range MnemonicLanguages() {
+		for _, words := range []int{12, 15, 18, 21, 24} {
+			t.Run(fmt.Sprintf("%s/%d", language, words), func(t *testing.T) {
+				m, err := NewMnemonic(words, language)
+				require.NoError(t, err)
+				require.Len(t, strings.Fields(m), words)
+
+				detected, err := ParseMnemonic(m)
+				require.NoError(t, err)
+				if strings.HasPrefix(language, "chinese") {
+					// the Chinese wordlists share many words, so a mnemonic may be valid in both
+					require.True(t, strings.HasPrefix(detected, "chinese"))
+				} else {
+					require.Equal(t, language, detected)
+				}
+
+				w, err := NewMultiWalletFromMnemonic(m, "", 1)
+				require.NoError(t, err)
+				require.Len(t, w.Secrets.Accounts, 1)
+			})
+		}
+	}
+
+	_, err := NewMnemonic(13, DefaultMnemonicLanguage)
+	require.ErrorIs(t, err, ErrMnemonicLength)
+	_, err = NewMnemonic(DefaultMnemonicWords, "klingon")
+	require.ErrorIs(t, err, ErrUnsupportedLanguage)
+}
+
+// Mnemonics, entropy and seeds must match those of go-bip39 in every language and length.
+func TestMnemonicMatchesBIP39(t *testing.T) {
+	t.Cleanup(func() { bip39.SetWordList(wordlists.English) })
+	for _, language := range MnemonicLanguages() {
+		list, err := wordlist(language)
+		require.NoError(t, err)
+		for _, bits := range []int{128, 160, 192, 224, 256} {
+			t.Run(fmt.Sprintf("%s/%d", language, bits), func(t *testing.T) {
+				entropy, err := bip39.NewEntropy(bits)
+				require.NoError(t, err)
+				bip39.SetWordList(list)
+				expected, err := bip39.NewMnemonic(entropy)
+				require.NoError(t, err)
+
+				m, err := MnemonicFromEntropy(entropy, language)
+				require.NoError(t, err)
+				require.Equal(t, expected, m)
+				decoded, err := MnemonicEntropy(m)
+				require.NoError(t, err)
+				require.Equal(t, entropy, decoded)
+				require.Equal(t, bip39.NewSeed(norm.NFKD.String(m), norm.NFKD.String("TREZOR")),
+					MnemonicSeed(m, "TREZOR"))
+				require.Equal(t, list, bip39.GetWordList())
+			})
+		}
+	}
+
+	_, err := MnemonicFromEntropy(make([]byte, 15), "english")
+	require.Error(t, err)
+}
+
+// Test vectors from the BIP-39 reference implementation.
+func TestMnemonicVectors(t *testing.T) {
+	for _, v := range []struct {
+		entropy, mnemonic, seed string
+	}{
+		{
+			"00000000000000000000000000000000",
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e5349553" +
+				"1f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+		},
+		{
+			"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+			"legal winner thank year wave sausage worth useful legal winner thank yellow",
+			"2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6f" +
+				"a457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+		},
+	} {
+		entropy, err := hex.DecodeString(v.entropy)
+		require.NoError(t, err)
+		m, err := MnemonicFromEntropy(entropy, "english")
+		require.NoError(t, err)
+		require.Equal(t, v.mnemonic, m)
+		require.Equal(t, v.seed, hex.EncodeToString(MnemonicSeed(m, "TREZOR")))
+	}
+}
+
+// Test vector from the Japanese BIP-39 test vectors, which exercise Unicode normalization of both
+// the mnemonic and the passphrase.
+func TestJapaneseMnemonic(t *testing.T) {
+	m := strings.Repeat("あいこくしん　", 11) + "あおぞら"
+	passphrase := "㍍ガバヴァぱばぐゞちぢ十人十色"
+	expected := "a262d6fb6122ecf45be09c50492b31f92e9beb7d9a845987a02cefda57a15f9c" +
+		"467a17872029a9e92299b5cbdf306e3a0ee620245cbd508959b6cb7ca637bd55"
+
+	language, err := ParseMnemonic(m)
+	require.NoError(t, err)
+	require.Equal(t, "japanese", language)
+	require.Equal(t, expected, hex.EncodeToString(MnemonicSeed(m, passphrase)))
+
+	entropy := make([]byte, 16)
+	generated, err := MnemonicFromEntropy(entropy, "japanese")
+	require.NoError(t, err)
+	require.Equal(t, norm.NFKD.String(strings.ReplaceAll(m, "　", " ")), norm.NFKD.String(generated))
+
+	// wallets accept the ideographic space as a separator
+	w, err := NewMultiWalletFromMnemonic(m, passphrase, 1)
+	require.NoError(t, err)
+	seed, err := w.Seed(passphrase)
+	require.NoError(t, err)
+	require.Equal(t, expected, hex.EncodeToString(seed))
+}
+
+func TestMnemonicNormalization(t *testing.T) {
+	// start with "élève", which has two accents
+	i := slices.IndexFunc(wordlists.French, func(w string) bool { return norm.NFC.String(w) == "élève" })
+	require.GreaterOrEqual(t, i, 0)
+	entropy := make([]byte, 16)
+	entropy[0], entropy[1] = byte(i>>3), byte(i<<5)
+	m, err := MnemonicFromEntropy(entropy, "french")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(norm.NFC.String(m), "élève "))
+	require.NotEqual(t, norm.NFC.String(m), norm.NFD.String(m))
+
+	// the same words in composed and decomposed form are the same mnemonic
+	for _, form := range []norm.Form{norm.NFC, norm.NFD} {
+		language, err := ParseMnemonic(form.String(m))
+		require.NoError(t, err)
+		require.Equal(t, "french", language)
+		require.Equal(t, MnemonicSeed(m, "é"), MnemonicSeed(form.String(m), norm.NFD.String("é")))
+	}
+}
+
+func TestInvalidMnemonicErrors(t *testing.T) {
+	valid := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+	_, err := ParseMnemonic("abandon abandon abandon")
+	require.ErrorIs(t, err, ErrMnemonicLength)
+	require.ErrorContains(t, err, "3 words")
+
+	_, err = ParseMnemonic(strings.Replace(valid, "about", "abuot", 1))
+	require.ErrorIs(t, err, ErrUnknownWords)
+	require.ErrorContains(t, err, `#12 "abuot"`)
+	require.ErrorContains(t, err, "english")
+
+	_, err = ParseMnemonic("abandon zzz abandon abandon abandon qqq abandon abandon abandon abandon abandon about")
+	require.ErrorIs(t, err, ErrUnknownWords)
+	require.ErrorContains(t, err, `#2 "zzz", #6 "qqq"`)
+
+	_, err = ParseMnemonic(strings.Replace(valid, "about", "abandon", 1))
+	require.ErrorIs(t, err, ErrMnemonicChecksum)
+	require.ErrorContains(t, err, "#12")
+
+	_, err = NewMultiWalletFromMnemonic(strings.Replace(valid, "about", "abandon", 1), "", 1)
+	require.ErrorIs(t, err, ErrMnemonicChecksum)
+}
@@ -0,0 +1,132 @@
+package wallet
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/genvm/core"
+)
+
+var (
+	// ErrNoPrivateKey is returned when signing with a software account that doesn't contain a
+	// private key.
+	ErrNoPrivateKey = errors.New("account does not contain a private key")
+
+	// ErrNoLedgerDevice is returned when a Ledger account is used without a Ledger device.
+	ErrNoLedgerDevice = errors.New("a Ledger device is required for this account")
+
+	// ErrLedgerSigningUnsupported is returned when signing with a Ledger device that smcli can't
+	// reach directly, e.g. on a platform other than Linux.
+	ErrLedgerSigningUnsupported = errors.New("signing with a Ledger device is not supported here")
+)
+
+// Signer signs transactions and messages with the key of an account.
+type Signer interface {
+	// PublicKey returns the public key of the account.
+	PublicKey() PublicKey
+
+	// SignTx signs the unsigned transaction for the network with the given genesis ID, and returns
+	// the signature.
+	SignTx(genesisID types.Hash20, unsigned []byte) ([]byte, error)
+
+	// SignMessage signs the message, prefixed as returned by MessageSigningBody, and returns the
+	// signature.
+	SignMessage(msg []byte) ([]byte, error)
+}
+
+// LedgerDevice is the Spacemesh app on a Ledger device, which holds the private keys of Ledger
+// accounts. The app decodes transactions and shows their principal, recipient and amount on the
+// device before the user confirms them.
+type LedgerDevice interface {
+	// PublicKey reads the public key at path from the device. If confirm is set, the device shows
+	// the key and asks the user to confirm it.
+	PublicKey(path HDPath, confirm bool) ([]byte, error)
+
+	// SignTx signs the unsigned transaction for the network with the given genesis ID with the key
+	// at path, after the user confirms it on the device.
+	SignTx(path HDPath, genesisID types.Hash20, unsigned []byte) ([]byte, error)
+
+	// SignMessage signs the message, prefixed as returned by MessageSigningBody, with the key at
+	// path, after the user confirms it on the device.
+	SignMessage(path HDPath, msg []byte) ([]byte, error)
+}
+
+// Signer returns a signer for the account. Software accounts sign with their private key; Ledger
+// accounts sign using device, which may be nil for software accounts.
+func (kp *EDKeyPair) Signer(device LedgerDevice) (Signer, error) {
+	switch kp.KeyType {
+	case typeSoftware:
+		if len(kp.Private) != ed25519.PrivateKeySize {
+			return nil, ErrNoPrivateKey
+		}
+		return &softwareSigner{kp: kp}, nil
+	case typeLedger:
+		if device == nil {
+			return nil, ErrNoLedgerDevice
+		}
+		return &ledgerSigner{kp: kp, device: device}, nil
+	default:
+		return nil, errors.New("unknown key type")
+	}
+}
+
+// softwareSigner signs with the private key of the account.
+type softwareSigner struct {
+	kp *EDKeyPair
+}
+
+func (s *softwareSigner) PublicKey() PublicKey {
+	return s.kp.Public
+}
+
+func (s *softwareSigner) SignTx(genesisID types.Hash20, unsigned []byte) ([]byte, error) {
+	return ed25519.Sign(ed25519.PrivateKey(s.kp.Private), core.SigningBody(genesisID[:], unsigned)), nil
+}
+
+func (s *softwareSigner) SignMessage(msg []byte) ([]byte, error) {
+	return ed25519.Sign(ed25519.PrivateKey(s.kp.Private), MessageSigningBody(msg)), nil
+}
+
+// ledgerSigner signs using the Spacemesh app on a Ledger device. Signatures returned by the device
+// are verified against the account's public key, so that signing with the wrong device or account
+// fails rather than producing an invalid transaction.
+type ledgerSigner struct {
+	kp     *EDKeyPair
+	device LedgerDevice
+}
+
+func (s *ledgerSigner) PublicKey() PublicKey {
+	return s.kp.Public
+}
+
+func (s *ledgerSigner) SignTx(genesisID types.Hash20, unsigned []byte) ([]byte, error) {
+	sig, err := s.device.SignTx(s.kp.Path, genesisID, unsigned)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verify(core.SigningBody(genesisID[:], unsigned), sig); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+func (s *ledgerSigner) SignMessage(msg []byte) ([]byte, error) {
+	sig, err := s.device.SignMessage(s.kp.Path, msg)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verify(MessageSigningBody(msg), sig); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+func (s *ledgerSigner) verify(body, sig []byte) error {
+	if !ed25519.Verify(ed25519.PublicKey(s.kp.Public), body, sig) {
+		return fmt.Errorf("%w: the Ledger signature doesn't match the account's public key, "+
+			"is the right device connected?", ErrInvalidSignature)
+	}
+	return nil
+}
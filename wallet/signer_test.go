@@ -0,0 +1,96 @@
+package wallet
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/genvm/core"
+	"github.com/spacemeshos/go-spacemesh/genvm/sdk"
+	walletTemplate "github.com/spacemeshos/go-spacemesh/genvm/templates/wallet"
+	"github.com/stretchr/testify/require"
+)
+
+// newFakeLedger returns a fake Ledger device holding the keys of a random mnemonic.
+func newFakeLedger(t *testing.T) *FakeLedger {
+	t.Helper()
+	w, err := NewMultiWalletRandomMnemonic("", 0)
+	require.NoError(t, err)
+	device, err := NewFakeLedgerFromMnemonic(w.Mnemonic())
+	require.NoError(t, err)
+	return device
+}
+
+// ledgerAccount returns a fake Ledger device and an account read from it.
+func ledgerAccount(t *testing.T) (*FakeLedger, *EDKeyPair) {
+	t.Helper()
+	device := newFakeLedger(t)
+	master, err := NewMasterKeyPairFromLedger(device)
+	require.NoError(t, err)
+	kp, err := master.NewLedgerChildKeyPair(device, 0)
+	require.NoError(t, err)
+	return device, kp
+}
+
+// walletPrincipal returns the address of the wallet template account owned by pub.
+func walletPrincipal(pub PublicKey) types.Address {
+	args := &walletTemplate.SpawnArguments{}
+	copy(args.PublicKey[:], pub)
+	return core.ComputePrincipal(walletTemplate.TemplateAddress, args)
+}
+
+// unsignedSpend returns an unsigned transaction that spends from the wallet template account owned
+// by pub.
+func unsignedSpend(pub PublicKey, to types.Address, amount uint64) []byte {
+	principal := walletPrincipal(pub)
+	payload := core.Payload{Nonce: 1, GasPrice: 1}
+	spend := &walletTemplate.SpendArguments{Destination: to, Amount: amount}
+	return sdk.Encode(&sdk.TxVersion, &principal, &sdk.MethodSpend, &payload, spend)
+}
+
+func TestSoftwareSigner(t *testing.T) {
+	w, err := NewMultiWalletRandomMnemonic("", 1)
+	require.NoError(t, err)
+	kp := w.Secrets.Accounts[0]
+	signer, err := kp.Signer(nil)
+	require.NoError(t, err)
+	require.Equal(t, kp.Public, signer.PublicKey())
+
+	var genesisID types.Hash20
+	sig, err := signer.SignTx(genesisID, []byte("tx"))
+	require.NoError(t, err)
+	require.True(t, ed25519.Verify(ed25519.PublicKey(kp.Public), core.SigningBody(genesisID[:], []byte("tx")), sig))
+
+	kp.Private = nil
+	_, err = kp.Signer(nil)
+	require.ErrorIs(t, err, ErrNoPrivateKey)
+}
+
+func TestLedgerSigner(t *testing.T) {
+	device, kp := ledgerAccount(t)
+	_, err := kp.Signer(nil)
+	require.ErrorIs(t, err, ErrNoLedgerDevice)
+
+	signer, err := kp.Signer(device)
+	require.NoError(t, err)
+	require.Equal(t, kp.Public, signer.PublicKey())
+
+	var genesisID types.Hash20
+	unsigned := unsignedSpend(kp.Public, types.GenerateAddress([]byte("recipient")), 100)
+	sig, err := signer.SignTx(genesisID, unsigned)
+	require.NoError(t, err)
+	require.True(t, ed25519.Verify(ed25519.PublicKey(kp.Public), core.SigningBody(genesisID[:], unsigned), sig))
+	sig, err = signer.SignMessage([]byte("msg"))
+	require.NoError(t, err)
+	require.True(t, ed25519.Verify(ed25519.PublicKey(kp.Public), MessageSigningBody([]byte("msg")), sig))
+
+	// the device refuses to sign what it can't show
+	_, err = signer.SignTx(genesisID, []byte("tx"))
+	require.Error(t, err)
+
+	// a device that doesn't hold the account's key can't sign for it
+	signer, err = kp.Signer(newFakeLedger(t))
+	require.NoError(t, err)
+	_, err = signer.SignTx(genesisID, unsigned)
+	require.ErrorIs(t, err, ErrInvalidSignature)
+}
@@ -0,0 +1,554 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// SLIP39MaxShares is the maximum number of groups, and of member shares in a group.
+const SLIP39MaxShares = 16
+
+const (
+	// slip39MetadataWords is the number of words of a share that aren't its value: 2 for the
+	// identifier and iteration exponent, 2 for the group and member parameters and 3 for the
+	// checksum.
+	slip39MetadataWords = 7
+
+	// slip39MinWords is the length of shares of a 128-bit secret, the shortest allowed.
+	slip39MinWords = slip39MetadataWords + (128+9)/10
+
+	slip39DigestLength   = 4
+	slip39BaseIterations = 10000
+	slip39Rounds         = 4
+
+	// slip39SecretIndex and slip39DigestIndex are the x coordinates of the shared secret and of its
+	// digest in Shamir's scheme.
+	slip39SecretIndex = 255
+	slip39DigestIndex = 254
+)
+
+var (
+	// ErrInvalidShare is returned for SLIP-39 share mnemonics that can't be decoded.
+	ErrInvalidShare = errors.New("invalid SLIP-39 share")
+
+	// ErrIncompatibleShares is returned when combining SLIP-39 shares that don't belong to the same
+	// set of shares.
+	ErrIncompatibleShares = errors.New("SLIP-39 shares don't belong together")
+
+	// ErrNotEnoughShares is returned when combining fewer SLIP-39 shares than needed.
+	ErrNotEnoughShares = errors.New("not enough SLIP-39 shares")
+
+	// ErrShareDigest is returned when a threshold of SLIP-39 shares doesn't recover a valid secret,
+	// which means that at least one of them is corrupted.
+	ErrShareDigest = errors.New("SLIP-39 shares don't recover a valid secret")
+)
+
+// slip39WordIndices maps SLIP-39 words to their index in the wordlist.
+var slip39WordIndices = func() map[string]int {
+	m := make(map[string]int, len(slip39Words))
+	for i, w := range slip39Words {
+		m[w] = i
+	}
+	return m
+}()
+
+// gfExp and gfLog are the exponent and logarithm tables of GF(256) with the Rijndael polynomial
+// x^8 + x^4 + x^3 + x + 1, using 3 as the generator.
+var gfExp, gfLog = func() (exp [255]int, log [256]int) {
+	poly := 1
+	for i := range exp {
+		exp[i] = poly
+		log[poly] = i
+		poly = (poly << 1) ^ poly
+		if poly&0x100 != 0 {
+			poly ^= 0x11b
+		}
+	}
+	return exp, log
+}()
+
+// SLIP39Group is the threshold and number of member shares of a SLIP-39 group.
+type SLIP39Group struct {
+	Threshold int
+	Count     int
+}
+
+// SLIP39Share is a decoded SLIP-39 share mnemonic.
+type SLIP39Share struct {
+	// Identifier is the random identifier common to all shares of a secret.
+	Identifier uint16
+	// Extendable indicates that more sets of shares may be created for the same secret, so the
+	// identifier isn't used to encrypt it.
+	Extendable bool
+	// IterationExponent sets the number of PBKDF2 iterations used to encrypt the secret.
+	IterationExponent uint8
+
+	GroupIndex      int
+	GroupThreshold  int
+	GroupCount      int
+	MemberIndex     int
+	MemberThreshold int
+
+	// Value is the share of the group's secret.
+	Value []byte
+}
+
+// ParseSLIP39Share decodes a SLIP-39 share mnemonic and checks its checksum.
+func ParseSLIP39Share(m string) (*SLIP39Share, error) {
+	fields := strings.Fields(strings.ToLower(m))
+	if len(fields) < slip39MinWords {
+		return nil, fmt.Errorf("%w: %d words, expected at least %d", ErrInvalidShare, len(fields), slip39MinWords)
+	}
+	words := make([]int, len(fields))
+	var unknown []string
+	for i, f := range fields {
+		idx, ok := slip39WordIndices[f]
+		if !ok {
+			unknown = append(unknown, fmt.Sprintf("#%d %q", i+1, f))
+		}
+		words[i] = idx
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("%w: unknown words %s", ErrInvalidShare, strings.Join(unknown, ", "))
+	}
+
+	// the value is padded to a whole number of words, with at most 8 bits
+	valueWords := len(words) - slip39MetadataWords
+	padding := valueWords * 10 % 16
+	if padding > 8 {
+		return nil, fmt.Errorf("%w: invalid length of %d words", ErrInvalidShare, len(words))
+	}
+
+	idExp := words[0]<<10 | words[1]
+	s := &SLIP39Share{
+		Identifier:        uint16(idExp >> 5),
+		Extendable:        idExp>>4&1 == 1,
+		IterationExponent: uint8(idExp & 0xf),
+	}
+	if !slip39VerifyChecksum(words, s.Extendable) {
+		return nil, fmt.Errorf("%w: checksum doesn't match; check for a mistyped, missing or swapped word",
+			ErrInvalidShare)
+	}
+
+	params := words[2]<<10 | words[3]
+	s.GroupIndex = params >> 16
+	s.GroupThreshold = params>>12&0xf + 1
+	s.GroupCount = params>>8&0xf + 1
+	s.MemberIndex = params >> 4 & 0xf
+	s.MemberThreshold = params&0xf + 1
+	if s.GroupCount < s.GroupThreshold {
+		return nil, fmt.Errorf("%w: group threshold %d is greater than the group count %d", ErrInvalidShare,
+			s.GroupThreshold, s.GroupCount)
+	}
+
+	v := new(big.Int)
+	for _, w := range words[4 : len(words)-3] {
+		v.Lsh(v, 10).Or(v, big.NewInt(int64(w)))
+	}
+	n := (valueWords*10 - padding) / 8
+	if v.BitLen() > n*8 {
+		return nil, fmt.Errorf("%w: invalid padding", ErrInvalidShare)
+	}
+	s.Value = v.FillBytes(make([]byte, n))
+	return s, nil
+}
+
+// Mnemonic encodes the share as a mnemonic.
+func (s *SLIP39Share) Mnemonic() string {
+	idExp := int(s.Identifier)<<5 | int(s.IterationExponent)
+	if s.Extendable {
+		idExp |= 1 << 4
+	}
+	params := s.GroupIndex<<16 | (s.GroupThreshold-1)<<12 | (s.GroupCount-1)<<8 | s.MemberIndex<<4 |
+		(s.MemberThreshold - 1)
+	words := []int{idExp >> 10, idExp & 0x3ff, params >> 10, params & 0x3ff}
+
+	v := new(big.Int).SetBytes(s.Value)
+	mask := big.NewInt(0x3ff)
+	for i := (len(s.Value)*8+9)/10 - 1; i >= 0; i-- {
+		w := new(big.Int).Rsh(v, uint(10*i))
+		words = append(words, int(w.And(w, mask).Int64()))
+	}
+	words = append(words, slip39Checksum(words, s.Extendable)...)
+
+	out := make([]string, len(words))
+	for i, w := range words {
+		out[i] = slip39Words[w]
+	}
+	return strings.Join(out, " ")
+}
+
+// SplitSLIP39 splits a secret of at least 128 bits, and an even number of bytes, into SLIP-39
+// shares, after encrypting it with the passphrase. groupThreshold of the groups are needed to
+// recover the secret, and each group is recovered from a threshold of its member shares. The
+// shares are returned by group. The secret is encrypted with 2^iterationExponent * 10000 PBKDF2
+// iterations.
+func SplitSLIP39(secret []byte, passphrase string, groupThreshold int, groups []SLIP39Group,
+	iterationExponent uint8,
+) ([][]string, error) {
+	if len(secret) < 16 || len(secret)%2 != 0 {
+		return nil, fmt.Errorf("invalid secret length %d bytes, expected an even number of at least 16", len(secret))
+	}
+	if err := checkSLIP39Passphrase(passphrase); err != nil {
+		return nil, err
+	}
+	if iterationExponent > 0xf {
+		return nil, fmt.Errorf("invalid iteration exponent %d, expected at most 15", iterationExponent)
+	}
+	if len(groups) > SLIP39MaxShares {
+		return nil, fmt.Errorf("too many groups %d, expected at most %d", len(groups), SLIP39MaxShares)
+	}
+	if groupThreshold < 1 || groupThreshold > len(groups) {
+		return nil, fmt.Errorf("invalid group threshold %d of %d groups", groupThreshold, len(groups))
+	}
+	for _, g := range groups {
+		if g.Threshold < 1 || g.Threshold > g.Count || g.Count > SLIP39MaxShares {
+			return nil, fmt.Errorf("invalid threshold of %d of %d shares: the threshold must be between 1 and "+
+				"the number of shares, which must be at most %d", g.Threshold, g.Count, SLIP39MaxShares)
+		}
+		if g.Threshold == 1 && g.Count > 1 {
+			return nil, fmt.Errorf("invalid threshold of 1 of %d shares: use a single share instead", g.Count)
+		}
+	}
+
+	var id [2]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+	template := SLIP39Share{
+		Identifier:        binary.BigEndian.Uint16(id[:]) >> 1,
+		Extendable:        true,
+		IterationExponent: iterationExponent,
+		GroupThreshold:    groupThreshold,
+		GroupCount:        len(groups),
+	}
+	encrypted := slip39Feistel(secret, passphrase, &template, true)
+
+	groupShares, err := splitSecret(groupThreshold, len(groups), encrypted)
+	if err != nil {
+		return nil, err
+	}
+	mnemonics := make([][]string, len(groups))
+	for i, g := range groups {
+		memberShares, err := splitSecret(g.Threshold, g.Count, groupShares[i].data)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range memberShares {
+			s := template
+			s.GroupIndex = int(groupShares[i].x)
+			s.MemberIndex = int(m.x)
+			s.MemberThreshold = g.Threshold
+			s.Value = m.data
+			mnemonics[i] = append(mnemonics[i], s.Mnemonic())
+		}
+	}
+	return mnemonics, nil
+}
+
+// CombineSLIP39 recovers the secret from a threshold of SLIP-39 share mnemonics, decrypting it
+// with the passphrase. Any passphrase recovers a secret, so a wrong passphrase can't be detected.
+func CombineSLIP39(mnemonics []string, passphrase string) ([]byte, error) {
+	if err := checkSLIP39Passphrase(passphrase); err != nil {
+		return nil, err
+	}
+	if len(mnemonics) == 0 {
+		return nil, ErrNotEnoughShares
+	}
+	shares := make([]*SLIP39Share, 0, len(mnemonics))
+	for i, m := range mnemonics {
+		s, err := ParseSLIP39Share(m)
+		if err != nil {
+			return nil, fmt.Errorf("share %d: %w", i+1, err)
+		}
+		shares = append(shares, s)
+	}
+
+	first := shares[0]
+	groups := make(map[int][]*SLIP39Share)
+	for i, s := range shares {
+		if s.Identifier != first.Identifier || s.Extendable != first.Extendable ||
+			s.IterationExponent != first.IterationExponent {
+			return nil, fmt.Errorf("%w: share %d doesn't begin with the same 2 words as share 1",
+				ErrIncompatibleShares, i+1)
+		}
+		if s.GroupThreshold != first.GroupThreshold || s.GroupCount != first.GroupCount {
+			return nil, fmt.Errorf("%w: share %d has a different group threshold or count than share 1",
+				ErrIncompatibleShares, i+1)
+		}
+		group := groups[s.GroupIndex]
+		if len(group) > 0 && s.MemberThreshold != group[0].MemberThreshold {
+			return nil, fmt.Errorf("%w: share %d has a different member threshold than the other shares "+
+				"of group %d", ErrIncompatibleShares, i+1, s.GroupIndex+1)
+		}
+		// the same share may be entered twice
+		if !slices.ContainsFunc(group, func(o *SLIP39Share) bool {
+			return o.MemberIndex == s.MemberIndex && bytes.Equal(o.Value, s.Value)
+		}) {
+			groups[s.GroupIndex] = append(group, s)
+		}
+	}
+
+	if len(groups) < first.GroupThreshold {
+		return nil, fmt.Errorf("%w: shares of %d groups are needed, got %d", ErrNotEnoughShares,
+			first.GroupThreshold, len(groups))
+	}
+	if len(groups) > first.GroupThreshold {
+		return nil, fmt.Errorf("%w: shares of exactly %d groups are needed, got %d", ErrIncompatibleShares,
+			first.GroupThreshold, len(groups))
+	}
+	indices := make([]int, 0, len(groups))
+	for i := range groups {
+		indices = append(indices, i)
+	}
+	slices.Sort(indices)
+
+	groupShares := make([]rawShare, 0, len(groups))
+	for _, i := range indices {
+		group := groups[i]
+		threshold := group[0].MemberThreshold
+		if len(group) < threshold {
+			return nil, fmt.Errorf("%w: %d shares of group %d are needed, got %d", ErrNotEnoughShares,
+				threshold, i+1, len(group))
+		}
+		if len(group) > threshold {
+			return nil, fmt.Errorf("%w: exactly %d shares of group %d are needed, got %d", ErrIncompatibleShares,
+				threshold, i+1, len(group))
+		}
+		members := make([]rawShare, 0, len(group))
+		for _, s := range group {
+			members = append(members, rawShare{x: byte(s.MemberIndex), data: s.Value})
+		}
+		secret, err := recoverSecret(threshold, members)
+		if err != nil {
+			return nil, err
+		}
+		groupShares = append(groupShares, rawShare{x: byte(i), data: secret})
+	}
+	encrypted, err := recoverSecret(first.GroupThreshold, groupShares)
+	if err != nil {
+		return nil, err
+	}
+	return slip39Feistel(encrypted, passphrase, first, false), nil
+}
+
+func checkSLIP39Passphrase(passphrase string) error {
+	for _, c := range passphrase {
+		if c < 32 || c > 126 {
+			return errors.New("the SLIP-39 passphrase must only contain printable ASCII characters")
+		}
+	}
+	return nil
+}
+
+// slip39Customization returns the customization string of the checksum, which also tells apart
+// extendable shares.
+func slip39Customization(extendable bool) []int {
+	s := "shamir"
+	if extendable {
+		s = "shamir_extendable"
+	}
+	values := make([]int, len(s))
+	for i := range s {
+		values[i] = int(s[i])
+	}
+	return values
+}
+
+// rs1024Polymod computes the Reed-Solomon checksum of SLIP-39 over GF(1024).
+func rs1024Polymod(values []int) int {
+	gen := [10]int{
+		0xe0e040, 0x1c1c080, 0x3838100, 0x7070200, 0xe0e0009,
+		0x1c0c2412, 0x38086c24, 0x3090fc48, 0x21b1f890, 0x3f3f120,
+	}
+	chk := 1
+	for _, v := range values {
+		b := chk >> 20
+		chk = (chk&0xfffff)<<10 ^ v
+		for i := range gen {
+			if b>>i&1 == 1 {
+				chk ^= gen[i]
+			}
+		}
+	}
+	return chk
+}
+
+func slip39Checksum(words []int, extendable bool) []int {
+	values := append(append(slip39Customization(extendable), words...), 0, 0, 0)
+	polymod := rs1024Polymod(values) ^ 1
+	return []int{polymod >> 20 & 0x3ff, polymod >> 10 & 0x3ff, polymod & 0x3ff}
+}
+
+func slip39VerifyChecksum(words []int, extendable bool) bool {
+	return rs1024Polymod(append(slip39Customization(extendable), words...)) == 1
+}
+
+// slip39Feistel encrypts or decrypts the secret with the passphrase using the four-round Feistel
+// network of SLIP-39, with the parameters of the share.
+func slip39Feistel(secret []byte, passphrase string, s *SLIP39Share, encrypt bool) []byte {
+	half := len(secret) / 2
+	l, r := slices.Clone(secret[:half]), slices.Clone(secret[half:])
+	var salt []byte
+	if !s.Extendable {
+		salt = binary.BigEndian.AppendUint16([]byte("shamir"), s.Identifier)
+	}
+	iterations := (slip39BaseIterations << s.IterationExponent) / slip39Rounds
+	for round := 0; round < slip39Rounds; round++ {
+		i := round
+		if !encrypt {
+			i = slip39Rounds - 1 - round
+		}
+		key := append([]byte{byte(i)}, passphrase...)
+		f := pbkdf2.Key(key, append(slices.Clone(salt), r...), iterations, len(r), sha256.New)
+		for j := range f {
+			f[j] ^= l[j]
+		}
+		l, r = r, f
+	}
+	return append(r, l...)
+}
+
+// rawShare is a point of a polynomial over GF(256) evaluated byte-wise.
+type rawShare struct {
+	x    byte
+	data []byte
+}
+
+// interpolate evaluates at x the polynomial that goes through the shares, using Lagrange
+// interpolation.
+func interpolate(shares []rawShare, x byte) ([]byte, error) {
+	for i, s := range shares {
+		if len(s.data) != len(shares[0].data) {
+			return nil, fmt.Errorf("%w: shares have different lengths", ErrIncompatibleShares)
+		}
+		for _, o := range shares[:i] {
+			if o.x == s.x {
+				return nil, fmt.Errorf("%w: more than one share has index %d", ErrIncompatibleShares, s.x)
+			}
+		}
+	}
+	for _, s := range shares {
+		if s.x == x {
+			return slices.Clone(s.data), nil
+		}
+	}
+
+	logProd := 0
+	for _, s := range shares {
+		logProd += gfLog[s.x^x]
+	}
+	result := make([]byte, len(shares[0].data))
+	for _, s := range shares {
+		logBasis := logProd - gfLog[s.x^x]
+		for _, o := range shares {
+			logBasis -= gfLog[s.x^o.x]
+		}
+		logBasis = (logBasis%255 + 255) % 255
+		for i, v := range s.data {
+			if v != 0 {
+				result[i] ^= byte(gfExp[(gfLog[v]+logBasis)%255])
+			}
+		}
+	}
+	return result, nil
+}
+
+func slip39Digest(random, secret []byte) []byte {
+	mac := hmac.New(sha256.New, random)
+	mac.Write(secret)
+	return mac.Sum(nil)[:slip39DigestLength]
+}
+
+// splitSecret splits the secret into count shares, threshold of which are needed to recover it.
+// The polynomial goes through threshold-2 random shares, the secret and a digest of it, which
+// lets recoverSecret detect corrupted shares.
+func splitSecret(threshold, count int, secret []byte) ([]rawShare, error) {
+	if threshold == 1 {
+		shares := make([]rawShare, count)
+		for i := range shares {
+			shares[i] = rawShare{x: byte(i), data: slices.Clone(secret)}
+		}
+		return shares, nil
+	}
+
+	shares := make([]rawShare, 0, count)
+	for i := 0; i < threshold-2; i++ {
+		data := make([]byte, len(secret))
+		if _, err := rand.Read(data); err != nil {
+			return nil, err
+		}
+		shares = append(shares, rawShare{x: byte(i), data: data})
+	}
+	random := make([]byte, len(secret)-slip39DigestLength)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	base := append(slices.Clone(shares),
+		rawShare{x: slip39DigestIndex, data: append(slip39Digest(random, secret), random...)},
+		rawShare{x: slip39SecretIndex, data: secret},
+	)
+	for i := threshold - 2; i < count; i++ {
+		data, err := interpolate(base, byte(i))
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, rawShare{x: byte(i), data: data})
+	}
+	return shares, nil
+}
+
+// recoverSecret recovers the secret from threshold shares, and checks its digest.
+func recoverSecret(threshold int, shares []rawShare) ([]byte, error) {
+	if threshold == 1 {
+		return shares[0].data, nil
+	}
+	secret, err := interpolate(shares, slip39SecretIndex)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := interpolate(shares, slip39DigestIndex)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(digest[:slip39DigestLength], slip39Digest(digest[slip39DigestLength:], secret)) {
+		return nil, ErrShareDigest
+	}
+	return secret, nil
+}
+
+// SplitMnemonicSLIP39 splits the entropy of a BIP-39 mnemonic into count SLIP-39 shares, threshold
+// of which are needed to recover the mnemonic with MnemonicFromSLIP39. The shares don't include the
+// mnemonic's language or BIP-39 passphrase, which are needed to derive the same keys from it.
+func SplitMnemonicSLIP39(m string, threshold, count int) ([]string, error) {
+	entropy, err := MnemonicEntropy(m)
+	if err != nil {
+		return nil, err
+	}
+	shares, err := SplitSLIP39(entropy, "", 1, []SLIP39Group{{Threshold: threshold, Count: count}}, 1)
+	if err != nil {
+		return nil, err
+	}
+	return shares[0], nil
+}
+
+// MnemonicFromSLIP39 recovers a BIP-39 mnemonic in the given language from a threshold of the
+// SLIP-39 shares created by SplitMnemonicSLIP39. Pass it to NewMultiWalletFromMnemonic, with the
+// original BIP-39 passphrase, to restore the wallet.
+func MnemonicFromSLIP39(shares []string, language string) (string, error) {
+	entropy, err := CombineSLIP39(shares, "")
+	if err != nil {
+		return "", err
+	}
+	return MnemonicFromEntropy(entropy, language)
+}
@@ -0,0 +1,373 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test vectors from https://github.com/trezor/python-shamir-mnemonic/blob/master/vectors.json.
+// All of them use the passphrase "TREZOR". An empty secret means that the shares are invalid.
+// The file has more vectors than these: the ones for mismatching group thresholds and counts are
+// covered by TestCombineSLIP39MismatchedGroups instead, and the combinations of the group shares
+// may differ from the file's.
+//
+//nolint:lll
+var slip39Vectors = []struct {
+	description string
+	mnemonics   []string
+	secret      string
+}{
+	{
+		"Valid mnemonic without sharing (128 bits)",
+		[]string{"duckling enlarge academic academic agency result length solution fridge kidney coal piece deal husband erode duke ajar critical decision keyboard"},
+		"bb54aac4b89dc868ba37d9cc21b2cece",
+	},
+	{
+		"Mnemonic with invalid checksum (128 bits)",
+		[]string{"duckling enlarge academic academic agency result length solution fridge kidney coal piece deal husband erode duke ajar critical decision kidney"},
+		"",
+	},
+	{
+		"Mnemonic with invalid padding (128 bits)",
+		[]string{"duckling enlarge academic academic email result length solution fridge kidney coal piece deal husband erode duke ajar music cargo fitness"},
+		"",
+	},
+	{
+		"Basic sharing 2-of-3 (128 bits)",
+		[]string{
+			"shadow pistol academic always adequate wildlife fancy gross oasis cylinder mustang wrist rescue view short owner flip making coding armed",
+			"shadow pistol academic acid actress prayer class unknown daughter sweater depict flip twice unkind craft early superior advocate guest smoking",
+		},
+		"b43ceb7e57a0ea8766221624d01b0864",
+	},
+	{
+		"Basic sharing 2-of-3 (128 bits)",
+		[]string{"shadow pistol academic always adequate wildlife fancy gross oasis cylinder mustang wrist rescue view short owner flip making coding armed"},
+		"",
+	},
+	{
+		"Mnemonics with different identifiers (128 bits)",
+		[]string{
+			"adequate smoking academic acid debut wine petition glen cluster slow rhyme slow simple epidemic rumor junk tracks treat olympic tolerate",
+			"adequate stay academic agency agency formal party ting frequent learn upstairs remember smear leaf damage anatomy ladle market hush corner",
+		},
+		"",
+	},
+	{
+		"Mnemonics with different iteration exponents (128 bits)",
+		[]string{
+			"peasant leaves academic acid desert exact olympic math alive axle trial tackle drug deny decent smear dominant desert bucket remind",
+			"peasant leader academic agency cultural blessing percent network envelope medal junk primary human pumps jacket fragment payroll ticket evoke voice",
+		},
+		"",
+	},
+	{
+		"Mnemonics with mismatching member thresholds (128 bits)",
+		[]string{
+			"hour painting academic academic device formal evoke guitar random modern justice filter withdraw trouble identify mailman insect general cover oven",
+			"hour painting academic agency artist again daisy capital beaver fiber much enjoy suitable symbolic identify photo editor romp float echo",
+		},
+		"",
+	},
+	{
+		"Mnemonics giving an invalid digest (128 bits)",
+		[]string{
+			"guilt walnut academic acid deliver remove equip listen vampire tactics nylon rhythm failure husband fatigue alive blind enemy teaspoon rebound",
+			"guilt walnut academic agency brave hamster hobo declare herd taste alpha slim criminal mild arcade formal romp branch pink ambition",
+		},
+		"",
+	},
+	{
+		"Insufficient number of groups (128 bits, case 1)",
+		[]string{"eraser senior beard romp adorn nuclear spill corner cradle style ancient family general leader ambition exchange unusual garlic promise voice"},
+		"",
+	},
+	{
+		"Insufficient number of groups (128 bits, case 2)",
+		[]string{
+			"eraser senior decision scared cargo theory device idea deliver modify curly include pancake both news skin realize vitamins away join",
+			"eraser senior decision smug corner ruin rescue cubic angel tackle skin skunk program roster trash rumor slush angel flea amazing",
+		},
+		"",
+	},
+	{
+		"Threshold number of groups, but insufficient number of members in one group (128 bits)",
+		[]string{
+			"eraser senior decision shadow artist work morning estate greatest pipeline plan ting petition forget hormone flexible general goat admit surface",
+			"eraser senior ceramic snake clay various huge numb argue hesitate auction category timber browser greatest hanger petition script leaf pickup",
+		},
+		"",
+	},
+	{
+		"Threshold number of groups and members in each group (128 bits, case 1)",
+		[]string{
+			"eraser senior ceramic snake clay various huge numb argue hesitate auction category timber browser greatest hanger petition script leaf pickup",
+			"eraser senior ceramic shaft dynamic become junior wrist silver peasant force math alto coal amazing segment yelp velvet image paces",
+			"eraser senior ceramic round column hawk trust auction smug shame alive greatest sheriff living perfect corner chest sled fumes adequate",
+			"eraser senior decision scared cargo theory device idea deliver modify curly include pancake both news skin realize vitamins away join",
+			"eraser senior decision shadow artist work morning estate greatest pipeline plan ting petition forget hormone flexible general goat admit surface",
+		},
+		"7c3397a292a5941682d7a4ae2d898d11",
+	},
+	{
+		"Threshold number of groups and members in each group (128 bits, case 2)",
+		[]string{
+			"eraser senior beard romp adorn nuclear spill corner cradle style ancient family general leader ambition exchange unusual garlic promise voice",
+			"eraser senior decision smug corner ruin rescue cubic angel tackle skin skunk program roster trash rumor slush angel flea amazing",
+			"eraser senior decision roster beard treat identify grumpy salt index fake aviation theater cubic bike cause research dragon emphasis counter",
+		},
+		"7c3397a292a5941682d7a4ae2d898d11",
+	},
+	{
+		"Threshold number of groups and members in each group (128 bits, case 3)",
+		[]string{
+			"eraser senior ceramic round column hawk trust auction smug shame alive greatest sheriff living perfect corner chest sled fumes adequate",
+			"eraser senior beard romp adorn nuclear spill corner cradle style ancient family general leader ambition exchange unusual garlic promise voice",
+			"eraser senior ceramic shaft dynamic become junior wrist silver peasant force math alto coal amazing segment yelp velvet image paces",
+			"eraser senior ceramic snake clay various huge numb argue hesitate auction category timber browser greatest hanger petition script leaf pickup",
+		},
+		"7c3397a292a5941682d7a4ae2d898d11",
+	},
+	{
+		"Mnemonics with greater group threshold than group counts (128 bits)",
+		[]string{
+			"music husband acrobat acid artist finance center either graduate swimming object bike medical clothes station aspect spider maiden bulb welcome",
+			"music husband acrobat agency advance hunting bike corner density careful material civil evil tactics remind hawk discuss hobo voice rainbow",
+		},
+		"",
+	},
+	{
+		"Mnemonics with duplicate member indices (128 bits)",
+		[]string{
+			"device stay academic always dive coal antenna adult black exceed stadium herald advance soldier busy dryer daughter evaluate minister laser",
+			"device stay academic always dwarf afraid robin gravity crunch adjust soul branch walnut coastal dream costume scholar mortgage mountain pumps",
+		},
+		"",
+	},
+	{
+		"Valid mnemonic without sharing (256 bits)",
+		[]string{"theory painting academic academic armed sweater year military elder discuss acne wildlife boring employer fused large satoshi bundle carbon diagnose anatomy hamster leaves tracks paces beyond phantom capital marvel lips brave detect luck"},
+		"989baf9dcaad5b10ca33dfd8cc75e42477025dce88ae83e75a230086a0e00e92",
+	},
+	{
+		"Basic sharing 2-of-3 (256 bits)",
+		[]string{
+			"humidity disease academic always aluminum jewelry energy woman receiver strategy amuse duckling lying evidence network walnut tactics forget hairy rebound impulse brother survive clothes stadium mailman rival ocean reward venture always armed unwrap",
+			"humidity disease academic agency actress jacket gross physics cylinder solution fake mortgage benefit public busy prepare sharp friar change work slow purchase ruler again tricycle involve viral wireless mixture anatomy desert cargo upgrade",
+		},
+		"c938b319067687e990e05e0da0ecce1278f75ff58d9853f19dcaeed5de104aae",
+	},
+	{
+		"Basic sharing 2-of-3 (256 bits)",
+		[]string{"humidity disease academic always aluminum jewelry energy woman receiver strategy amuse duckling lying evidence network walnut tactics forget hairy rebound impulse brother survive clothes stadium mailman rival ocean reward venture always armed unwrap"},
+		"",
+	},
+	{
+		"Insufficient number of groups (256 bits, case 1)",
+		[]string{"wildlife deal beard romp alcohol space mild usual clothes union nuclear testify course research heat listen task location thank hospital slice smell failure fawn helpful priest ambition average recover lecture process dough stadium"},
+		"",
+	},
+	{
+		"Insufficient number of groups (256 bits, case 2)",
+		[]string{
+			"wildlife deal decision scared acne fatal snake paces obtain election dryer dominant romp tactics railroad marvel trust helpful flip peanut theory theater photo luck install entrance taxi step oven network dictate intimate listen",
+			"wildlife deal decision smug ancestor genuine move huge cubic strategy smell game costume extend swimming false desire fake traffic vegan senior twice timber submit leader payroll fraction apart exact forward pulse tidy install",
+		},
+		"",
+	},
+	{
+		"Threshold number of groups and members in each group (256 bits)",
+		[]string{
+			"wildlife deal beard romp alcohol space mild usual clothes union nuclear testify course research heat listen task location thank hospital slice smell failure fawn helpful priest ambition average recover lecture process dough stadium",
+			"wildlife deal decision scared acne fatal snake paces obtain election dryer dominant romp tactics railroad marvel trust helpful flip peanut theory theater photo luck install entrance taxi step oven network dictate intimate listen",
+			"wildlife deal decision smug ancestor genuine move huge cubic strategy smell game costume extend swimming false desire fake traffic vegan senior twice timber submit leader payroll fraction apart exact forward pulse tidy install",
+		},
+		"5385577c8cfc6c1a8aa0f7f10ecde0a3318493262591e78b8c14c6686167123b",
+	},
+	{
+		"Valid extendable mnemonic without sharing (128 bits)",
+		[]string{"testify swimming academic academic column loyalty smear include exotic bedroom exotic wrist lobe cover grief golden smart junior estimate learn"},
+		"1679b4516e0ee5954351d288a838f45e",
+	},
+	{
+		"Extendable basic sharing 2-of-3 (128 bits)",
+		[]string{
+			"enemy favorite academic acid cowboy phrase havoc level response walnut budget painting inside trash adjust froth kitchen learn tidy punish",
+			"enemy favorite academic always academic sniff script carpet romp kind promise scatter center unfair training emphasis evening belong fake enforce",
+		},
+		"48b1a4b80b8c209ad42c33672bdaa428",
+	},
+	{
+		"Valid extendable mnemonic without sharing (256 bits)",
+		[]string{"impulse calcium academic academic alcohol sugar lyrics pajamas column facility finance tension extend space birthday rainbow swimming purple syndrome facility trial warn duration snapshot shadow hormone rhyme public spine counter easy hawk album"},
+		"8340611602fe91af634a5f4608377b5235fa2d757c51d720c0c7656249a3035f",
+	},
+	{
+		"Extendable basic sharing 2-of-3 (256 bits)",
+		[]string{
+			"western apart academic always artist resident briefing sugar woman oven coding club ajar merit pecan answer prisoner artist fraction amount desktop mild false necklace muscle photo wealthy alpha category unwrap spew losing making",
+			"western apart academic acid answer ancient auction flip image penalty oasis beaver multiple thunder problem switch alive heat inherit superior teaspoon explain blanket pencil numb lend punish endless aunt garlic humidity kidney observe",
+		},
+		"8dc652d6d6cd370d8c963141f6d79ba440300f25c467302c1d966bff8f62300d",
+	},
+	{
+		"Mnemonic with insufficient length",
+		[]string{"junk necklace academic academic acne isolate join hesitate lunar roster dough calcium chemical ladybug amount mobile glasses verify cylinder"},
+		"",
+	},
+	{
+		"Mnemonic with invalid master secret length",
+		[]string{"fraction necklace academic academic award teammate mouse regular testify coding building member verdict purchase blind camera duration email prepare spirit quarter"},
+		"",
+	},
+	{
+		"Valid mnemonics which can detect some errors in modular arithmetic",
+		[]string{
+			"herald flea academic cage avoid space trend estate dryer hairy evoke eyebrow improve airline artwork garlic premium duration prevent oven",
+			"herald flea academic client blue skunk class goat luxury deny presence impulse graduate clay join blanket bulge survive dish necklace",
+			"herald flea academic acne advance fused brother frozen broken game ranked ajar already believe check install theory angry exercise adult",
+		},
+		"ad6f2ad8b59bbbaa01369b9006208d9a",
+	},
+}
+
+func TestSLIP39Vectors(t *testing.T) {
+	for _, tv := range slip39Vectors {
+		t.Run(tv.description, func(t *testing.T) {
+			secret, err := CombineSLIP39(tv.mnemonics, "TREZOR")
+			if tv.secret == "" {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tv.secret, hex.EncodeToString(secret))
+
+			// decoding and encoding the shares is lossless
+			for _, m := range tv.mnemonics {
+				s, err := ParseSLIP39Share(m)
+				require.NoError(t, err)
+				require.Equal(t, m, s.Mnemonic())
+			}
+		})
+	}
+}
+
+// The vectors file has shares with mismatching group thresholds and counts, but these are derived
+// from valid shares instead: changing a parameter and encoding the share again keeps its checksum valid.
+func TestCombineSLIP39MismatchedGroups(t *testing.T) {
+	var mnemonics []string
+	for _, tv := range slip39Vectors {
+		if tv.description == "Threshold number of groups and members in each group (128 bits, case 2)" {
+			mnemonics = tv.mnemonics
+		}
+	}
+	require.Len(t, mnemonics, 3)
+	_, err := CombineSLIP39(mnemonics, "TREZOR")
+	require.NoError(t, err)
+
+	for _, change := range []func(s *SLIP39Share){
+		func(s *SLIP39Share) { s.GroupThreshold = 1 },
+		func(s *SLIP39Share) { s.GroupCount = 5 },
+	} {
+		s, err := ParseSLIP39Share(mnemonics[2])
+		require.NoError(t, err)
+		change(s)
+		_, err = ParseSLIP39Share(s.Mnemonic())
+		require.NoError(t, err)
+		_, err = CombineSLIP39([]string{mnemonics[0], mnemonics[1], s.Mnemonic()}, "TREZOR")
+		require.ErrorIs(t, err, ErrIncompatibleShares)
+	}
+}
+
+func TestSplitSLIP39Groups(t *testing.T) {
+	secret := []byte("a 256-bit secret, or 32 bytes...")
+	groups := []SLIP39Group{{1, 1}, {2, 3}, {3, 5}}
+	shares, err := SplitSLIP39(secret, "TREZOR", 2, groups, 0)
+	require.NoError(t, err)
+	require.Len(t, shares, 3)
+	for i, g := range groups {
+		require.Len(t, shares[i], g.Count)
+	}
+
+	for _, combination := range [][]string{
+		{shares[0][0], shares[1][0], shares[1][2]},
+		{shares[2][4], shares[2][1], shares[2][0], shares[1][1], shares[1][0]},
+		{shares[0][0], shares[2][1], shares[2][2], shares[2][3]},
+	} {
+		recovered, err := CombineSLIP39(combination, "TREZOR")
+		require.NoError(t, err)
+		require.Equal(t, secret, recovered)
+	}
+
+	// any passphrase recovers a secret, but not the original one
+	recovered, err := CombineSLIP39([]string{shares[0][0], shares[1][0], shares[1][2]}, "")
+	require.NoError(t, err)
+	require.NotEqual(t, secret, recovered)
+
+	_, err = CombineSLIP39([]string{shares[0][0], shares[1][0]}, "TREZOR")
+	require.ErrorIs(t, err, ErrNotEnoughShares)
+	_, err = CombineSLIP39([]string{shares[0][0]}, "TREZOR")
+	require.ErrorIs(t, err, ErrNotEnoughShares)
+	_, err = CombineSLIP39([]string{shares[0][0], shares[1][0], shares[1][1], shares[1][2]}, "TREZOR")
+	require.ErrorIs(t, err, ErrIncompatibleShares)
+
+	// a corrupted share that still has a valid checksum is detected by the digest
+	s, err := ParseSLIP39Share(shares[1][2])
+	require.NoError(t, err)
+	s.Value[0] ^= 1
+	_, err = CombineSLIP39([]string{shares[0][0], shares[1][0], s.Mnemonic()}, "TREZOR")
+	require.ErrorIs(t, err, ErrShareDigest)
+
+	_, err = SplitSLIP39(secret, "", 1, []SLIP39Group{{1, 2}}, 0)
+	require.Error(t, err)
+	_, err = SplitSLIP39(secret, "", 2, []SLIP39Group{{2, 3}}, 0)
+	require.Error(t, err)
+	_, err = SplitSLIP39(secret[:15], "", 1, []SLIP39Group{{2, 3}}, 0)
+	require.Error(t, err)
+}
+
+func TestWalletFromSLIP39(t *testing.T) {
+	for _, language := range []string{"english", "spanish"} {
+		m, err := NewMnemonic(DefaultMnemonicWords, language)
+		require.NoError(t, err)
+		w, err := NewMultiWalletFromMnemonic(m, "passphrase", 3)
+		require.NoError(t, err)
+
+		shares, err := SplitMnemonicSLIP39(w.Mnemonic(), 3, 5)
+		require.NoError(t, err)
+		require.Len(t, shares, 5)
+		// shares of 256 bits of entropy have 33 words
+		for _, s := range shares {
+			require.Len(t, MnemonicWords(s), 33)
+		}
+
+		restored, err := MnemonicFromSLIP39([]string{shares[4], shares[0], shares[2]}, language)
+		require.NoError(t, err)
+		require.Equal(t, m, restored)
+		w2, err := NewMultiWalletFromMnemonic(restored, "passphrase", 3)
+		require.NoError(t, err)
+		require.Equal(t, w.Secrets.MasterKeypair.Public, w2.Secrets.MasterKeypair.Public)
+		for i := range w.Secrets.Accounts {
+			require.Equal(t, w.Secrets.Accounts[i].Public, w2.Secrets.Accounts[i].Public)
+			require.Equal(t, w.Secrets.Accounts[i].Private, w2.Secrets.Accounts[i].Private)
+		}
+
+		_, err = MnemonicFromSLIP39(shares[:2], language)
+		require.ErrorIs(t, err, ErrNotEnoughShares)
+	}
+}
+
+func TestMnemonicEntropy(t *testing.T) {
+	for _, words := range []int{12, 15, 18, 21, 24} {
+		m, err := NewMnemonic(words, "french")
+		require.NoError(t, err)
+		entropy, err := MnemonicEntropy(m)
+		require.NoError(t, err)
+		require.Len(t, entropy, words*11*32/33/8)
+		m2, err := MnemonicFromEntropy(entropy, "french")
+		require.NoError(t, err)
+		require.Equal(t, m, m2)
+	}
+}
@@ -2,7 +2,6 @@ package wallet
 
 import (
 	"bytes"
-	"crypto"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/hmac"
@@ -10,11 +9,11 @@ import (
 	"crypto/sha512"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log"
-
-	"github.com/spf13/cobra"
-	"github.com/xdg-go/pbkdf2"
+	"os"
+	"path/filepath"
 )
 
 const EncKeyLen = 32
@@ -22,112 +21,154 @@ const EncKeyLen = 32
 // https://cheatsheetseries.owasp.org/cheatsheets/Password_Storage_Cheat_Sheet.html#pbkdf2
 const (
 	Pbkdf2Iterations   = 210000
-	Pbkdf2Dklen        = 256
 	Pbkdf2SaltBytesLen = 16
 )
 
+// Pbkdf2Dklen is the derived key length recorded in the kdfparams of version 1 wallet files. The
+// key actually used is EncKeyLen bytes long.
+//
+// Deprecated: new wallet files record EncKeyLen.
+const Pbkdf2Dklen = legacyPbkdf2Dklen
+
 var Pbkdf2HashFunc = sha512.New
 
+var (
+	// ErrInvalidKeyOptions is returned by NewKey when the options are missing or conflict.
+	ErrInvalidKeyOptions = errors.New("invalid wallet key options")
+
+	// ErrBadSalt is returned when the salt of a wallet file is invalid or doesn't match the salt of
+	// the wallet key.
+	ErrBadSalt = errors.New("bad salt")
+)
+
 type (
-	WalletKeyOpt func(*WalletKey)
+	// WalletKeyOpt is an option of NewKey.
+	WalletKeyOpt func(*WalletKey) error
 	WalletKey    struct {
-		key        []byte
-		pw         []byte
-		salt       []byte
-		iterations int
+		key  []byte
+		pw   []byte
+		salt []byte
+		kdf  KDF
 	}
 )
 
-func NewKey(opts ...WalletKeyOpt) WalletKey {
+// NewKey returns a wallet key configured by the given options. One of the password options must be
+// given.
+func NewKey(opts ...WalletKeyOpt) (WalletKey, error) {
 	w := &WalletKey{}
 	for _, opt := range opts {
-		opt(w)
+		if err := opt(w); err != nil {
+			return WalletKey{}, err
+		}
 	}
 	if w.key == nil && w.pw == nil {
-		log.Fatalf("Some form of key generation method must be provided. Try WithXXXPassword.")
+		return WalletKey{}, fmt.Errorf("%w: some form of key generation method must be provided, try WithXXXPassword",
+			ErrInvalidKeyOptions)
 	}
 
-	return *w
+	return *w, nil
 }
 
 func WithRandomSalt() WalletKeyOpt {
-	return func(k *WalletKey) {
+	return func(k *WalletKey) error {
 		if k.salt != nil {
-			log.Fatalf("Can only set salt once.")
+			return fmt.Errorf("%w: can only set salt once", ErrInvalidKeyOptions)
 		}
 		k.salt = make([]byte, Pbkdf2SaltBytesLen)
 		_, err := rand.Read(k.salt)
-		cobra.CheckErr(err)
+		return err
 	}
 }
 
 func WithSalt(salt [Pbkdf2SaltBytesLen]byte) WalletKeyOpt {
-	return func(k *WalletKey) {
+	return func(k *WalletKey) error {
 		if k.salt != nil {
-			log.Fatalf("Can only set salt once.")
+			return fmt.Errorf("%w: can only set salt once", ErrInvalidKeyOptions)
 		}
 		k.salt = salt[:]
 
 		// if password is set, set the key as well
 		if k.pw != nil {
-			WithPbkdf2Password(k.pw)(k)
+			return k.deriveKey()
 		}
+		return nil
 	}
 }
 
+// WithIterations sets the number of PBKDF2 iterations.
 func WithIterations(iterations int) WalletKeyOpt {
-	return func(k *WalletKey) {
-		k.iterations = iterations
+	return func(k *WalletKey) error {
+		k.kdf = &PBKDF2{Iterations: iterations}
 		if k.key != nil {
 			// regenerate
-			k.key = nil
-			WithPbkdf2Password(k.pw)(k)
+			return k.deriveKey()
 		}
+		return nil
 	}
 }
 
 // WithPasswordOnly is used for reading a stored file. The stored wallet file contains
 // a salt, so it does not need to be set before reading the file.
 func WithPasswordOnly(password []byte) WalletKeyOpt {
-	return func(k *WalletKey) {
+	return func(k *WalletKey) error {
 		if k.salt != nil {
-			log.Fatalf("Salt must not be set.")
+			return fmt.Errorf("%w: salt must not be set", ErrInvalidKeyOptions)
 		}
 		if k.key != nil {
-			log.Fatalf("Can only generate key once.")
+			return fmt.Errorf("%w: can only generate key once", ErrInvalidKeyOptions)
 		}
 		if k.pw != nil {
-			log.Fatalf("Password can only be set once.")
+			return fmt.Errorf("%w: password can only be set once", ErrInvalidKeyOptions)
 		}
 		k.pw = password
+		return nil
 	}
 }
 
 func WithPbkdf2Password(password []byte) WalletKeyOpt {
-	return func(k *WalletKey) {
+	return func(k *WalletKey) error {
+		kdf, ok := k.kdf.(*PBKDF2)
+		if !ok {
+			kdf = NewPBKDF2()
+		}
+		return WithKDFPassword(kdf, password)(k)
+	}
+}
+
+// WithKDFPassword derives the key from the password using the given KDF. The salt must be set.
+func WithKDFPassword(kdf KDF, password []byte) WalletKeyOpt {
+	return func(k *WalletKey) error {
 		if k.salt == nil {
-			log.Fatalf("Salt must be set.")
+			return fmt.Errorf("%w: salt must be set", ErrInvalidKeyOptions)
 		}
 		if k.key != nil {
-			log.Fatalf("Can only generate key once.")
-		}
-		iterations := k.iterations
-		if iterations == 0 {
-			iterations = Pbkdf2Iterations
+			return fmt.Errorf("%w: can only generate key once", ErrInvalidKeyOptions)
 		}
-		k.key = pbkdf2.Key(
-			password,
-			k.salt,
-			iterations,
-			EncKeyLen,
-			Pbkdf2HashFunc,
-		)
+		k.kdf = kdf
 		k.pw = password
+		return k.deriveKey()
 	}
 }
 
+// KDF returns the KDF used to derive the key. It defaults to PBKDF2.
+func (k *WalletKey) KDF() KDF {
+	if k.kdf == nil {
+		return NewPBKDF2()
+	}
+	return k.kdf
+}
+
+func (k *WalletKey) deriveKey() error {
+	key, err := k.KDF().DeriveKey(k.pw, k.salt)
+	if err != nil {
+		return fmt.Errorf("deriving key: %w", err)
+	}
+	k.key = key
+	return nil
+}
+
 // https://cheatsheetseries.owasp.org/cheatsheets/Secrets_Management_Cheat_Sheet.html#71-encryption-types-to-use
-func (k *WalletKey) encrypt(plaintext []byte) (ciphertext, nonce []byte, err error) {
+func (k *WalletKey) encrypt(plaintext, additionalData []byte) (ciphertext, nonce []byte, err error) {
 	block, err := aes.NewCipher(k.key)
 	if err != nil {
 		return
@@ -140,14 +181,20 @@ func (k *WalletKey) encrypt(plaintext []byte) (ciphertext, nonce []byte, err err
 	if err != nil {
 		return
 	}
-	hash := hmac.New(sha512.New, k.key)
-	nonce = hash.Sum(plaintext)[:aesgcm.NonceSize()]
+	// The nonce must never repeat for the same key, so use a random one: a wallet file is encrypted
+	// only a handful of times with the same key, far from the 2^32 limit for random GCM nonces.
+	// Files written by older versions used the start of the plaintext as the nonce, which repeats;
+	// they're still decrypted using the nonce stored in the file.
+	nonce = make([]byte, aesgcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
 
-	ciphertext = aesgcm.Seal(nil, nonce, plaintext, nil)
+	ciphertext = aesgcm.Seal(nil, nonce, plaintext, additionalData)
 	return
 }
 
-func (k *WalletKey) decrypt(ciphertext, nonce []byte) (plaintext []byte, err error) {
+func (k *WalletKey) decrypt(ciphertext, nonce, additionalData []byte) (plaintext []byte, err error) {
 	block, err := aes.NewCipher(k.key)
 	if err != nil {
 		return
@@ -157,7 +204,7 @@ func (k *WalletKey) decrypt(ciphertext, nonce []byte) (plaintext []byte, err err
 		return
 	}
 
-	plaintext, err = aesgcm.Open(nil, nonce, ciphertext, nil)
+	plaintext, err = aesgcm.Open(nil, nonce, ciphertext, additionalData)
 	return
 }
 
@@ -166,30 +213,55 @@ func (k *WalletKey) Open(file io.Reader, debugMode bool) (*Wallet, error) {
 	if err := json.NewDecoder(file).Decode(ew); err != nil {
 		return nil, err
 	}
+	if err := ew.validate(); err != nil {
+		return nil, err
+	}
 
-	// set the salt, and warn if it's different
+	kdf, err := kdfFromFile(ew.Secrets.KDF, ew.Secrets.KDFParams)
+	if err != nil {
+		return nil, err
+	}
+	if pbkdf2, ok := kdf.(*PBKDF2); ok && pbkdf2.Iterations < Pbkdf2Iterations {
+		log.Println("Warning: wallet file iterations count lower than recommended")
+	}
+
+	// set the salt, or check that it matches if it was set
 	if k.salt == nil {
-		var salt [Pbkdf2SaltBytesLen]byte
-		copy(salt[:], ew.Secrets.KDFParams.Salt)
-		if !bytes.Equal(salt[:], ew.Secrets.KDFParams.Salt) {
-			return nil, errors.New("error reading encrypted wallet file salt, check salt length")
-		}
-		WithSalt(salt)(k)
+		k.salt = bytes.Clone(ew.Secrets.KDFParams.Salt)
 	} else if !bytes.Equal(ew.Secrets.KDFParams.Salt, k.salt) {
-		log.Printf("wallet key salt does not match wallet file salt")
+		return nil, fmt.Errorf("%w: wallet key salt does not match wallet file salt", ErrBadSalt)
 	}
-	WithIterations(ew.Secrets.KDFParams.Iterations)(k)
-	if ew.Secrets.KDFParams.Iterations < Pbkdf2Iterations {
-		log.Println("Warning: wallet file iterations count lower than recommended")
+
+	// derive the key using the KDF and parameters in the file
+	k.kdf = kdf
+	if k.key, err = kdf.DeriveKey(k.pw, k.salt); err != nil {
+		return nil, err
+	}
+
+	// check the password, then decrypt the secrets and authenticate the rest of the file
+	var additionalData []byte
+	if ew.authenticated() {
+		if !hmac.Equal(keyCheck(k.key), ew.Secrets.KeyCheck) {
+			return nil, ErrWrongPassword
+		}
+		if additionalData, err = ew.associatedData(); err != nil {
+			return nil, err
+		}
+	} else {
+		log.Printf("Warning: wallet file version %d does not authenticate its metadata, "+
+			"upgrade it with smcli wallet migrate", ew.FileVersion())
 	}
 
 	nonce := ew.Secrets.CipherParams.IV
 	encWallet := ew.Secrets.CipherText
 
-	// TODO: before decrypting, check that other meta params match
-	plaintext, err := k.decrypt(encWallet, nonce)
+	plaintext, err := k.decrypt(encWallet, nonce, additionalData)
 	if err != nil {
-		return nil, err
+		if ew.authenticated() {
+			return nil, ErrTampered
+		}
+		// without a key check, a wrong password can't be told apart from a corrupted file
+		return nil, fmt.Errorf("%w (or the wallet file is corrupted)", ErrWrongPassword)
 	}
 	if debugMode {
 		log.Println("Decrypted JSON data:", string(plaintext))
@@ -213,33 +285,57 @@ func (k *WalletKey) Export(file io.Writer, w *Wallet) error {
 	if err != nil {
 		return err
 	}
-	ciphertext, nonce, err := k.encrypt(plaintext)
-	if err != nil {
-		return err
-	}
+	kdf := k.KDF()
+	params := kdf.params()
+	params.Salt = k.salt
 	ew := &EncryptedWalletFile{
-		Meta: w.Meta,
+		Version: WalletFileVersion,
+		Meta:    w.Meta,
 		Secrets: walletSecretsEncrypted{
-			Cipher:     "AES-GCM",
-			CipherText: ciphertext,
-			CipherParams: struct {
-				IV hexEncodedCiphertext `json:"iv"`
-			}{
-				IV: nonce,
-			},
-			KDF: "PBKDF2",
-			KDFParams: struct {
-				DKLen      int                  `json:"dklen"`
-				Hash       string               `json:"hash"`
-				Salt       hexEncodedCiphertext `json:"salt"`
-				Iterations int                  `json:"iterations"`
-			}{
-				DKLen:      Pbkdf2Dklen,
-				Hash:       crypto.SHA256.String(),
-				Salt:       k.salt,
-				Iterations: Pbkdf2Iterations,
-			},
+			Cipher:    Cipher,
+			KDF:       kdf.Name(),
+			KDFParams: params,
+			KeyCheck:  keyCheck(k.key),
 		},
 	}
+
+	// authenticate the metadata and parameters along with the secrets
+	additionalData, err := ew.associatedData()
+	if err != nil {
+		return err
+	}
+	ciphertext, nonce, err := k.encrypt(plaintext, additionalData)
+	if err != nil {
+		return err
+	}
+	ew.Secrets.CipherText = ciphertext
+	ew.Secrets.CipherParams.IV = nonce
 	return json.NewEncoder(file).Encode(ew)
 }
+
+// ExportFile encrypts the wallet and writes it to the file at path, replacing any existing file
+// atomically: the wallet is first written to a temporary file in the same directory, which is then
+// renamed to path, so that the file is never left partially written.
+func (k *WalletKey) ExportFile(path string, w *Wallet) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // no-op once renamed
+	if err := f.Chmod(0o600); err != nil {
+		f.Close()
+		return err
+	}
+	if err := k.Export(f, w); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -1,28 +1,41 @@
 package wallet
 
 import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 )
 
+// newTestKey is like NewKey, but fails the test on error.
+func newTestKey(t *testing.T, opts ...WalletKeyOpt) WalletKey {
+	t.Helper()
+	k, err := NewKey(opts...)
+	require.NoError(t, err)
+	return k
+}
+
 func TestStoreAndRetrieveWalletToFromFile(t *testing.T) {
 	saltSlice, _ := hex.DecodeString("0102030405060708090a0b0c0d0e0f10")
 	password, _ := hex.DecodeString("70617373776f7264")
 	var salt, salt2 [Pbkdf2SaltBytesLen]byte
 	copy(salt[:], saltSlice)
 
-	wKey := NewKey(
+	wKey := newTestKey(t,
 		WithSalt(salt),
 		WithPbkdf2Password(password),
 	)
 
-	w, err := NewMultiWalletRandomMnemonic(1)
+	w, err := NewMultiWalletRandomMnemonic("", 1)
 	require.NoError(t, err)
 
 	file, err := os.CreateTemp("./", "test_wallet.*.json")
@@ -42,7 +55,7 @@ func TestStoreAndRetrieveWalletToFromFile(t *testing.T) {
 	require.Equal(t, w.Secrets.Mnemonic, w2.Secrets.Mnemonic)
 
 	// trying to open with a different wallet key, same pw and nonce, should work
-	wKey = NewKey(
+	wKey = newTestKey(t,
 		WithSalt(salt),
 		WithPbkdf2Password(password),
 	)
@@ -57,31 +70,106 @@ func TestStoreAndRetrieveWalletToFromFile(t *testing.T) {
 	password2[0]++
 
 	// right salt, wrong password
-	wKey = NewKey(
+	wKey = newTestKey(t,
 		WithSalt(salt),
 		WithPbkdf2Password(password2),
 	)
 	file.Seek(0, io.SeekStart)
 	_, err = wKey.Open(file, false)
-	require.Error(t, err)
+	require.ErrorIs(t, err, ErrWrongPassword)
 
 	// right password, wrong salt
 	copy(salt2[:], saltSlice)
 	salt2[0]++
-	wKey = NewKey(
+	wKey = newTestKey(t,
 		WithSalt(salt2),
 		WithPbkdf2Password(password),
 	)
 	file.Seek(0, io.SeekStart)
 	_, err = wKey.Open(file, false)
-	require.Error(t, err)
+	require.ErrorIs(t, err, ErrBadSalt)
 
 	// both wrong
-	wKey = NewKey(
+	wKey = newTestKey(t,
 		WithSalt(salt2),
 		WithPbkdf2Password(password2),
 	)
 	file.Seek(0, io.SeekStart)
 	_, err = wKey.Open(file, false)
+	require.ErrorIs(t, err, ErrBadSalt)
+}
+
+func TestExportFileReplacesWallet(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "wallet.json")
+	require.NoError(t, os.WriteFile(fn, []byte("old contents"), 0o600))
+
+	w, err := NewMultiWalletRandomMnemonic("", 2)
+	require.NoError(t, err)
+	wKey := newTestKey(t, WithRandomSalt(), WithPbkdf2Password([]byte("password")))
+	require.NoError(t, wKey.ExportFile(fn, w))
+
+	// no temporary files are left behind
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	info, err := os.Stat(fn)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	f, err := os.Open(fn)
+	require.NoError(t, err)
+	defer f.Close()
+	wKey = newTestKey(t, WithPasswordOnly([]byte("password")))
+	w2, err := wKey.Open(f, false)
+	require.NoError(t, err)
+	require.Equal(t, w.Secrets.Accounts, w2.Secrets.Accounts)
+}
+
+func TestExportUsesRandomNonces(t *testing.T) {
+	w, err := NewMultiWalletRandomMnemonic("", 2)
+	require.NoError(t, err)
+	wKey := newTestKey(t, WithRandomSalt(), WithKDFPassword(&PBKDF2{Iterations: 1000}, []byte("password")))
+
+	// exporting the same wallet twice with the same key must not reuse the nonce
+	var files [2]EncryptedWalletFile
+	for i := range files {
+		buf := &bytes.Buffer{}
+		require.NoError(t, wKey.Export(buf, w))
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &files[i]))
+	}
+	require.NotEqual(t, files[0].Secrets.CipherParams.IV, files[1].Secrets.CipherParams.IV)
+	require.NotEqual(t, files[0].Secrets.CipherText, files[1].Secrets.CipherText)
+}
+
+func TestDecryptLegacyNonce(t *testing.T) {
+	wKey := newTestKey(t, WithRandomSalt(), WithKDFPassword(&PBKDF2{Iterations: 1000}, []byte("password")))
+	plaintext := []byte(`{"mnemonic":"abandon"}`)
+
+	// older versions used the start of the plaintext as the nonce
+	block, err := aes.NewCipher(wKey.key)
+	require.NoError(t, err)
+	aesgcm, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	nonce := plaintext[:aesgcm.NonceSize()]
+	ciphertext := aesgcm.Seal(nil, nonce, plaintext, nil)
+
+	decrypted, err := wKey.decrypt(ciphertext, nonce, nil)
+	require.NoError(t, err)
+	require.Equal(t, plaintext, decrypted)
+}
+
+func TestNewKeyOptionErrors(t *testing.T) {
+	_, err := NewKey()
+	require.ErrorIs(t, err, ErrInvalidKeyOptions)
+	_, err = NewKey(WithRandomSalt(), WithRandomSalt(), WithPbkdf2Password([]byte("password")))
+	require.ErrorIs(t, err, ErrInvalidKeyOptions)
+	_, err = NewKey(WithPbkdf2Password([]byte("password")))
+	require.ErrorIs(t, err, ErrInvalidKeyOptions)
+	_, err = NewKey(WithRandomSalt(), WithPasswordOnly([]byte("password")))
+	require.ErrorIs(t, err, ErrInvalidKeyOptions)
+	_, err = NewKey(WithPasswordOnly([]byte("password")), WithPasswordOnly([]byte("password")))
+	require.ErrorIs(t, err, ErrInvalidKeyOptions)
+	_, err = NewKey(WithRandomSalt(), WithKDFPassword(&PBKDF2{}, []byte("password")))
 	require.Error(t, err)
 }
@@ -11,7 +11,6 @@ import (
 	"github.com/spacemeshos/go-spacemesh/common/types"
 	"github.com/spacemeshos/go-spacemesh/genvm/core"
 	walletTemplate "github.com/spacemeshos/go-spacemesh/genvm/templates/wallet"
-	"github.com/tyler-smith/go-bip39"
 
 	"github.com/spacemeshos/smcli/common"
 )
@@ -29,6 +28,9 @@ type Wallet struct {
 
 // EncryptedWalletFile is the encrypted representation of the wallet on the filesystem.
 type EncryptedWalletFile struct {
+	// Version is the format version of the file, see WalletFileVersion. It's not set in version 1
+	// files.
+	Version int                    `json:"version,omitempty"`
 	Meta    walletMetadata         `json:"meta"`
 	Secrets walletSecretsEncrypted `json:"crypto"`
 }
@@ -37,6 +39,12 @@ type walletMetadata struct {
 	DisplayName string `json:"displayName"`
 	Created     string `json:"created"`
 	GenesisID   string `json:"genesisID"`
+	// HasPassphrase records whether a BIP-39 passphrase was used to derive the seed.
+	// The passphrase itself is never stored.
+	HasPassphrase bool `json:"hasPassphrase,omitempty"`
+	// LedgerFingerprint identifies the Ledger device holding the keys of a Ledger wallet, see
+	// LedgerFingerprint.
+	LedgerFingerprint string `json:"ledgerFingerprint,omitempty"`
 	// NetID       int    `json:"netId"`
 
 	// is this needed?
@@ -65,13 +73,11 @@ type walletSecretsEncrypted struct {
 	CipherParams struct {
 		IV hexEncodedCiphertext `json:"iv"`
 	} `json:"cipherParams"`
-	KDF       string `json:"kdf"`
-	KDFParams struct {
-		DKLen      int                  `json:"dklen"`
-		Hash       string               `json:"hash"`
-		Salt       hexEncodedCiphertext `json:"salt"`
-		Iterations int                  `json:"iterations"`
-	} `json:"kdfparams"`
+	KDF       string    `json:"kdf"`
+	KDFParams kdfParams `json:"kdfparams"`
+	// KeyCheck is used to check the password, see keyCheck. It's only set in version 3 files and
+	// later.
+	KeyCheck hexEncodedCiphertext `json:"keyCheck,omitempty"`
 }
 
 type walletSecrets struct {
@@ -80,39 +86,40 @@ type walletSecrets struct {
 	Accounts      []*EDKeyPair `json:"accounts"`
 }
 
-func NewMultiWalletRandomMnemonic(n int) (*Wallet, error) {
+// NewMultiWalletRandomMnemonic generates a new wallet with n accounts from a new, random 24-word
+// English mnemonic. The optional BIP-39 passphrase is mixed into the seed; pass an empty string for
+// none. Use NewMnemonic and NewMultiWalletFromMnemonic for other lengths and languages.
+func NewMultiWalletRandomMnemonic(passphrase string, n int) (*Wallet, error) {
 	// generate a new, random mnemonic
-	e, err := bip39.NewEntropy(ed25519.SeedSize * 8)
-	if err != nil {
-		return nil, err
-	}
-	m, err := bip39.NewMnemonic(e)
+	m, err := NewMnemonic(DefaultMnemonicWords, DefaultMnemonicLanguage)
 	if err != nil {
 		return nil, err
 	}
 
-	return NewMultiWalletFromMnemonic(m, n)
+	return NewMultiWalletFromMnemonic(m, passphrase, n)
 }
 
-func NewMultiWalletFromMnemonic(m string, n int) (*Wallet, error) {
+// NewMultiWalletFromMnemonic generates a wallet with n accounts from an existing mnemonic and optional
+// BIP-39 passphrase (the so-called "25th word"). Pass an empty string if no passphrase is used. The
+// mnemonic may be in any supported wordlist language, which is detected (see ParseMnemonic).
+func NewMultiWalletFromMnemonic(m, passphrase string, n int) (*Wallet, error) {
 	if n < 0 || n > common.MaxAccountsPerWallet {
 		return nil, errors.New("invalid number of accounts")
 	}
 
-	// bip39 lib doesn't properly validate whitespace so we have to do that manually.
+	// words may be separated by single ideographic spaces, as is customary for Japanese mnemonics,
+	// but we don't accept any other whitespace.
+	m = strings.ReplaceAll(m, "\u3000", " ")
 	if expected := strings.Join(strings.Fields(m), " "); m != expected {
 		return nil, errWhitespace
 	}
 
-	// this checks the number of words and the checksum.
-	if !bip39.IsMnemonicValid(m) {
-		return nil, errors.New("invalid mnemonic")
+	// this checks the words, their number and the checksum.
+	if _, err := ParseMnemonic(m); err != nil {
+		return nil, err
 	}
 
-	// TODO: add option for user to provide passphrase
-	// https://github.com/spacemeshos/smcli/issues/18
-
-	seed := bip39.NewSeed(m, "")
+	seed := MnemonicSeed(m, passphrase)
 	masterKeyPair, err := NewMasterKeyPair(seed)
 	if err != nil {
 		return nil, err
@@ -121,25 +128,38 @@ func NewMultiWalletFromMnemonic(m string, n int) (*Wallet, error) {
 	if err != nil {
 		return nil, err
 	}
-	return walletFromMnemonicAndAccounts(m, masterKeyPair, accounts)
+	w, err := walletFromMnemonicAndAccounts(m, masterKeyPair, accounts)
+	if err != nil {
+		return nil, err
+	}
+	w.Meta.HasPassphrase = passphrase != ""
+	return w, nil
 }
 
-func NewMultiWalletFromLedger(n int) (*Wallet, error) {
+// NewMultiWalletFromLedger generates a wallet with n accounts whose keys are held by the given
+// Ledger device. The fingerprint of the device is stored in the wallet, see CheckLedger.
+func NewMultiWalletFromLedger(device LedgerDevice, n int) (*Wallet, error) {
 	if n < 0 || n > common.MaxAccountsPerWallet {
 		return nil, errors.New("invalid number of accounts")
 	}
-	masterKeyPair, err := NewMasterKeyPairFromLedger()
+	masterKeyPair, err := NewMasterKeyPairFromLedger(device)
 	if err != nil {
-		fmt.Println("Error: ", err)
-		fmt.Println("Are you sure the ledger is connected, unlocked, and the Spacemesh app is open?")
-		return nil, err
+		return nil, fmt.Errorf("%w (is the Ledger connected, unlocked, and the Spacemesh app open?)", err)
+	}
+	accounts := make([]*EDKeyPair, 0, n)
+	for i := 0; i < n; i++ {
+		acct, err := masterKeyPair.NewLedgerChildKeyPair(device, i)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, acct)
 	}
-	// seed is not used in case of ledger
-	accounts, err := accountsFromMaster(masterKeyPair, []byte{}, n)
+	w, err := walletFromMnemonicAndAccounts("(none)", masterKeyPair, accounts)
 	if err != nil {
 		return nil, err
 	}
-	return walletFromMnemonicAndAccounts("(none)", masterKeyPair, accounts)
+	w.Meta.LedgerFingerprint = LedgerFingerprint(masterKeyPair.Public)
+	return w, nil
 }
 
 func walletFromMnemonicAndAccounts(m string, masterKp *EDKeyPair, kp []*EDKeyPair) (*Wallet, error) {
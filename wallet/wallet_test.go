@@ -17,12 +17,12 @@ func TestRandomAndMnemonic(t *testing.T) {
 	n := 3
 
 	// generate a wallet with a random mnemonic
-	w1, err := NewMultiWalletRandomMnemonic(n)
+	w1, err := NewMultiWalletRandomMnemonic("", n)
 	require.NoError(t, err)
 	require.Len(t, w1.Secrets.Accounts, n)
 
 	// now use that mnemonic to generate a new wallet
-	w2, err := NewMultiWalletFromMnemonic(w1.Mnemonic(), n)
+	w2, err := NewMultiWalletFromMnemonic(w1.Mnemonic(), "", n)
 	require.NoError(t, err)
 	require.Len(t, w2.Secrets.Accounts, n)
 
@@ -66,7 +66,7 @@ func TestAccountFromSeed(t *testing.T) {
 func TestWalletFromNewMnemonic(t *testing.T) {
 	entropy, _ := bip39.NewEntropy(256)
 	mnemonic, _ := bip39.NewMnemonic(entropy)
-	w, err := NewMultiWalletFromMnemonic(mnemonic, 1)
+	w, err := NewMultiWalletFromMnemonic(mnemonic, "", 1)
 
 	require.NoError(t, err)
 	require.NotNil(t, w)
@@ -75,7 +75,7 @@ func TestWalletFromNewMnemonic(t *testing.T) {
 
 func TestWalletFromGivenMnemonic(t *testing.T) {
 	mnemonic := "film theme cheese broken kingdom destroy inch ready wear inspire shove pudding"
-	w, err := NewMultiWalletFromMnemonic(mnemonic, 1)
+	w, err := NewMultiWalletFromMnemonic(mnemonic, "", 1)
 	require.NoError(t, err)
 	expPubKey := "de30fc9b812248583da6259433626fcdd2cb5ce589b00047b81e127950b9bca6"
 	//nolint:lll
@@ -104,7 +104,7 @@ func TestWalletFromGivenMnemonic(t *testing.T) {
 
 func TestKeysInWalletMaintainExpectedPath(t *testing.T) {
 	n := 100
-	w, err := NewMultiWalletRandomMnemonic(n)
+	w, err := NewMultiWalletRandomMnemonic("", n)
 	require.NoError(t, err)
 
 	for i := 0; i < n; i++ {
@@ -125,7 +125,64 @@ func TestMnemonicWhitespace(t *testing.T) {
 		"film  theme  cheese  broken  kingdom  destroy  inch  ready  wear  inspire  shove  pudding",
 	}
 	for _, m := range mnemonics {
-		_, err := NewMultiWalletFromMnemonic(m, 1)
+		_, err := NewMultiWalletFromMnemonic(m, "", 1)
 		require.Equal(t, errWhitespace, err, "expected whitespace error in mnemonic")
 	}
 }
+
+// Test vectors from https://github.com/trezor/python-mnemonic/blob/master/vectors.json.
+// All of them use the passphrase "TREZOR".
+func TestMnemonicWithPassphrase(t *testing.T) {
+	testVectors := []struct {
+		mnemonic string
+		seed     string
+	}{
+		{
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			//nolint:lll
+			"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+		},
+		{
+			"legal winner thank year wave sausage worth useful legal winner thank yellow",
+			//nolint:lll
+			"2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+		},
+		{
+			"letter advice cage absurd amount doctor acoustic avoid letter advice cage above",
+			//nolint:lll
+			"d71de856f81a8acc65e6fc851a38d4d7ec216fd0796d0a6827a3ad6ed5511a30fa280f12eb2e47ed2ac03b5c462a0358d18d69fe4f985ec81778c1b370b652a8",
+		},
+		{
+			"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+			//nolint:lll
+			"ac27495480225222079d7be181583751e86f571027b0497b5b5d11218e0a8a13332572917f0f8e5a589620c6f15b11c61dee327651a14c34e18231052e48c069",
+		},
+	}
+
+	for _, tv := range testVectors {
+		t.Run(tv.mnemonic, func(t *testing.T) {
+			seed, err := hex.DecodeString(tv.seed)
+			require.NoError(t, err)
+			master, err := NewMasterKeyPair(seed)
+			require.NoError(t, err)
+			accts, err := accountsFromMaster(master, seed, 2)
+			require.NoError(t, err)
+
+			w, err := NewMultiWalletFromMnemonic(tv.mnemonic, "TREZOR", 2)
+			require.NoError(t, err)
+			require.True(t, w.Meta.HasPassphrase)
+			require.Equal(t, master.Public, w.Secrets.MasterKeypair.Public)
+			require.Equal(t, master.Private, w.Secrets.MasterKeypair.Private)
+			for i := range accts {
+				require.Equal(t, accts[i].Public, w.Secrets.Accounts[i].Public)
+				require.Equal(t, accts[i].Private, w.Secrets.Accounts[i].Private)
+			}
+
+			// the same mnemonic without a passphrase must produce different keys
+			w2, err := NewMultiWalletFromMnemonic(tv.mnemonic, "", 2)
+			require.NoError(t, err)
+			require.False(t, w2.Meta.HasPassphrase)
+			require.NotEqual(t, w.Secrets.Accounts[0].Public, w2.Secrets.Accounts[0].Public)
+		})
+	}
+}